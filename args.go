@@ -0,0 +1,361 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrArgs = errors.New("linha de comando inválida")
+
+func parseEngine(bin string) (EngineArch, bool) {
+	for e := Qemu_system_x86_64; e <= Qemu_system_aarch64; e++ {
+		if e.String() == path.Base(bin) {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+func parseNicType(s string) (NicType, bool) {
+	for t := Tap; t <= Socket; t++ {
+		if t.String() == s {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// flags taking no value
+var argFlags = map[string]bool{
+	"-daemonize":    true,
+	"-nographic":    true,
+	"-mem-prealloc": true,
+}
+
+/*
+usage:
+
+	g, rest, err := virt.ParseArgs(strings.Fields(script))
+	if len(rest) > 0 {
+		log.Println("ignored:", rest)
+	}
+	err = virt.CreateGuest(g)
+
+build a Guest from a QEMU command line, the inverse of Guest.ToArgs. args[0]
+may be the QEMU binary. Arguments that have no place in Guest (unknown flags,
+unknown keys, a second -netdev of the same type ...) are returned unchanged
+in rest, each flag followed by its value.
+*/
+func ParseArgs(args []string) (*Guest, []string, error) {
+	g := &Guest{}
+	rest := []string{}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if e, ok := parseEngine(args[0]); ok {
+			g.Engine = e
+		} else {
+			rest = append(rest, args[0])
+		}
+		args = args[1:]
+	}
+
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		if !strings.HasPrefix(flag, "-") {
+			rest = append(rest, flag)
+			continue
+		}
+		if strings.HasPrefix(flag, "--") {
+			flag = flag[1:]
+		}
+		if argFlags[flag] {
+			switch flag {
+			case "-daemonize":
+				g.Daemonize = true
+			case "-nographic":
+				g.NoGraphic = true
+			case "-mem-prealloc":
+				g.MemPrealloc = "on"
+			}
+			continue
+		}
+
+		// unknown flags take the next argument as value unless it is a flag
+		if i+1 >= len(args) {
+			if _, known := argParsers[flag]; known {
+				return nil, nil, fmt.Errorf("%w: %s sem valor", ErrArgs, flag)
+			}
+			rest = append(rest, args[i])
+			continue
+		}
+		parse, known := argParsers[flag]
+		if !known {
+			rest = append(rest, args[i])
+			if !strings.HasPrefix(args[i+1], "-") {
+				rest = append(rest, args[i+1])
+				i++
+			}
+			continue
+		}
+		ok, err := parse(g, args[i+1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %s: %w", flag, args[i+1], err)
+		}
+		if !ok {
+			rest = append(rest, args[i], args[i+1])
+		}
+		i++
+	}
+	return g, rest, nil
+}
+
+// parsers of flags with a value, false when the value has no place in g
+var argParsers = map[string]func(g *Guest, v string) (bool, error){
+	"-name": func(g *Guest, v string) (bool, error) {
+		opts := parseOpts(v, "guest")
+		if g.Name != "" || len(opts) != 1 || opts[0].Key != "guest" {
+			return false, nil
+		}
+		g.Name = opts[0].Value
+		return true, nil
+	},
+	"-uuid": func(g *Guest, v string) (bool, error) {
+		g.UUID = v
+		return true, nil
+	},
+	"-k": func(g *Guest, v string) (bool, error) {
+		g.K = v
+		return true, nil
+	},
+	"-mem-path": func(g *Guest, v string) (bool, error) {
+		g.MemPath = v
+		return true, nil
+	},
+	"-m":        parseMemoryArg,
+	"-smp":      parseSmpArg,
+	"-fda":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Fda }),
+	"-fdb":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Fdb }),
+	"-hda":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Hda }),
+	"-hdb":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Hdb }),
+	"-hdc":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Hdc }),
+	"-hdd":      diskArg(func(b *BlockDevicesOptions) *string { return &b.Hdd }),
+	"-cdrom":    parseCdromArg,
+	"-drive":    parseDriveArg,
+	"-blockdev": parseBlockdevArg,
+	"-qmp":      parseQmpArg,
+	"-nic":      parseNicArg,
+	"-netdev":   parseNetdevArg,
+	"-device":   parseDeviceArg,
+	"-object":   parseObjectArg,
+}
+
+func blockDevices(g *Guest) *BlockDevicesOptions {
+	if g.BlockDevices == nil {
+		g.BlockDevices = &BlockDevicesOptions{}
+	}
+	return g.BlockDevices
+}
+
+// -hda file ..., a single file per flag
+func diskArg(field func(*BlockDevicesOptions) *string) func(g *Guest, v string) (bool, error) {
+	return func(g *Guest, v string) (bool, error) {
+		f := field(blockDevices(g))
+		if *f != "" {
+			return false, nil
+		}
+		*f = v
+		return true, nil
+	}
+}
+
+// decode opts into a new *T stored in slot, false when slot is taken or a key is unknown
+func decodeArg[T any](slot **T, opts []qemuOpt) (bool, error) {
+	return decodeArgWith(slot, func(v any) error { return decodeQemuOpts(opts, v) })
+}
+
+// as decodeArg for the JSON form, g switches to JSONSyntax
+func decodeJSONArg[T any](g *Guest, slot **T, data string) (bool, error) {
+	ok, err := decodeArgWith(slot, func(v any) error { return UnmarshalQemuJSON([]byte(data), v) })
+	if ok {
+		g.JSONSyntax = true
+	}
+	return ok, err
+}
+
+func decodeArgWith[T any](slot **T, decode func(v any) error) (bool, error) {
+	if *slot != nil {
+		return false, nil
+	}
+	n := new(T)
+	if err := decode(n); errors.Is(err, ErrUnknownOpt) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	*slot = n
+	return true, nil
+}
+
+func parseMemoryArg(g *Guest, v string) (bool, error) {
+	return decodeArg(&g.Memory, parseOpts(v, "size"))
+}
+
+func parseSmpArg(g *Guest, v string) (bool, error) {
+	return decodeArg(&g.Smp, parseOpts(v, "cpus"))
+}
+
+func parseCdromArg(g *Guest, v string) (bool, error) {
+	b := blockDevices(g)
+	b.Cdrom = append(b.Cdrom, &CdromOptions{File: v})
+	return true, nil
+}
+
+// the file of a -drive written by CdromOptions.ToArgs
+func cdromFile(opts []qemuOpt) (string, bool) {
+	if len(opts) != 3 {
+		return "", false
+	}
+	m := map[string]string{}
+	for _, o := range opts {
+		m[o.Key] = o.Value
+	}
+	return m["file"], m["file"] != "" && m["index"] == "2" && m["media"] == "cdrom"
+}
+
+func parseDriveArg(g *Guest, v string) (bool, error) {
+	var d *DriveOptions
+	opts := parseOpts(v, "")
+	if f, ok := cdromFile(opts); ok {
+		b := blockDevices(g)
+		b.Cdrom = append(b.Cdrom, &CdromOptions{File: f})
+		return true, nil
+	}
+	for i, o := range opts {
+		if k, ok := legacyThrottleKeys[o.Key]; ok {
+			opts[i].Key = k
+		}
+	}
+	if ok, err := decodeArg(&d, opts); !ok {
+		return false, err
+	}
+	b := blockDevices(g)
+	b.Drive = append(b.Drive, d)
+	return true, nil
+}
+
+func parseBlockdevArg(g *Guest, v string) (bool, error) {
+	if ok, err := parseBlockNodeArg(g, v); ok || err != nil {
+		return ok, err
+	}
+	if g.BlockDevices != nil && g.BlockDevices.BlockDev != nil {
+		return false, nil
+	}
+	var d *BlockDev
+	var ok bool
+	var err error
+	if strings.HasPrefix(v, "{") {
+		ok, err = decodeJSONArg(g, &d, v)
+	} else {
+		ok, err = decodeArg(&d, parseOpts(v, "driver"))
+	}
+	if !ok {
+		return false, err
+	}
+	blockDevices(g).BlockDev = d
+	return true, nil
+}
+
+// -blockdev of a driver with typed options, see BlockNode
+func parseBlockNodeArg(g *Guest, v string) (bool, error) {
+	opts := parseOpts(v, "driver")
+	if strings.HasPrefix(v, "{") {
+		var err error
+		if opts, err = jsonOpts([]byte(v)); err != nil {
+			return false, err
+		}
+	}
+	n := &BlockNode{}
+	if err := decodeBlockNode(opts, n); errors.Is(err, ErrUnknownOpt) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if strings.HasPrefix(v, "{") {
+		g.JSONSyntax = true
+	}
+	b := blockDevices(g)
+	b.Nodes = append(b.Nodes, n)
+	return true, nil
+}
+
+func parseQmpArg(g *Guest, v string) (bool, error) {
+	if g.Qmp != nil {
+		return false, nil
+	}
+	q := &QmpOptions{}
+	opts := parseOpts(v, "path")
+	q.ProtoPath = opts[0].Value
+	for _, o := range opts[1:] {
+		switch o.Key {
+		case "server":
+			q.Serve = o.Value == "on"
+		case "wait":
+			q.Wait = o.Value == "on"
+		case "nowait":
+			q.Wait = false
+		default:
+			return false, nil
+		}
+	}
+	g.Qmp = q
+	return true, nil
+}
+
+func parseNicArg(g *Guest, v string) (bool, error) {
+	opts := parseOpts(v, "type")
+	if _, ok := parseNicType(opts[0].Value); !ok || opts[0].Key != "type" {
+		return false, nil
+	}
+	return decodeArg(&g.Nic, opts)
+}
+
+func parseNetdevArg(g *Guest, v string) (bool, error) {
+	opts := parseOpts(v, "type")
+	kind, opts := opts[0].Value, opts[1:]
+	switch kind {
+	case "user":
+		return decodeArg(&g.Netdev_User, opts)
+	case "tap":
+		return decodeArg(&g.Netdev_Tap, opts)
+	case "passt":
+		return decodeArg(&g.Netdev_Passt, opts)
+	case "bridge":
+		return decodeArg(&g.NetDev_Bridge, opts)
+	case "vhost-user":
+		return decodeArg(&g.Netdev_Vhost_user, opts)
+	case "vhost-vdpa":
+		return decodeArg(&g.Netdev_Vhost_vdpa, opts)
+	case "hubport":
+		return decodeArg(&g.Netdev_Hubport, opts)
+	case "vde":
+		return decodeArg(&g.Netdev_Vde, opts)
+	}
+	return false, nil
+}
+
+func parseDeviceArg(g *Guest, v string) (bool, error) {
+	var d *DeviceOptions
+	var ok bool
+	var err error
+	if strings.HasPrefix(v, "{") {
+		ok, err = decodeJSONArg(g, &d, v)
+	} else {
+		ok, err = decodeArg(&d, parseOpts(v, "driver"))
+	}
+	if !ok {
+		return false, err
+	}
+	g.Devices = append(g.Devices, d)
+	return true, nil
+}
@@ -0,0 +1,730 @@
+package virt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var ErrBackup = errors.New("backup inválido")
+
+type BackupKind int
+
+const (
+	BackupFull        BackupKind = iota // every block of the disks, starts a chain
+	BackupIncremental                   // the blocks written since the previous backup of the chain
+)
+
+func (k BackupKind) String() string {
+	if k == BackupIncremental {
+		return "incremental"
+	}
+	return "full"
+}
+
+func (k BackupKind) MarshalYAML() (any, error) {
+	return k.String(), nil
+}
+
+func (k *BackupKind) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Value {
+	default:
+		return fmt.Errorf("backup inválido: %s", value.Value)
+	case "full":
+		*k = BackupFull
+	case "incremental":
+		*k = BackupIncremental
+	}
+	return nil
+}
+
+// a disk in a backup
+type BackupDisk struct {
+	Node       string // format node of the disk
+	File       string `yaml:",omitempty"` // qcow2 backed by the image of the parent backup, empty when pulled over NBD
+	Bitmap     string // dirty bitmap started by this backup
+	Generation int    // 0 for a full backup, +1 for each incremental one
+}
+
+type Backup struct {
+	Name    string
+	Parent  string `yaml:",omitempty"` // previous backup of the chain
+	Kind    BackupKind
+	Created time.Time
+	Disks   map[string]*BackupDisk // by disk name, see SnapshotTree
+}
+
+// the dirty bitmap of a disk: the writes since the last backup
+type BackupBitmap struct {
+	Node       string
+	Name       string
+	Generation int    // of the last backup
+	Last       string // the last backup
+}
+
+/*
+the backups of a guest, kept in <VmDataPath>/<name>.backups.yaml. Each backup
+starts a dirty bitmap on the disks, the next incremental backup copies the
+blocks it marked and replaces it by a new one.
+*/
+type BackupSet struct {
+	Bitmaps map[string]*BackupBitmap `yaml:",omitempty"` // by disk name
+	Export  *BackupExport            `yaml:",omitempty"` // NBD export in progress, see ExportBackup
+	Backups []*Backup                `yaml:",omitempty"`
+}
+
+func (s *BackupSet) Get(name string) *Backup {
+	for _, b := range s.Backups {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// the backups restored by name, from the full backup to name
+func (s *BackupSet) Chain(name string) []*Backup {
+	chain := []*Backup{}
+	for b := s.Get(name); b != nil && len(chain) <= len(s.Backups); b = s.Get(b.Parent) {
+		chain = append([]*Backup{b}, chain...)
+		if b.Kind == BackupFull {
+			break
+		}
+	}
+	return chain
+}
+
+func (m *Manager) backupsPath(name string) string {
+	return path.Join(m.dataPath(), fmt.Sprintf("%s.backups.yaml", name))
+}
+
+func (m *Manager) loadBackups(name string) (*BackupSet, error) {
+	s := &BackupSet{}
+	data, err := os.ReadFile(m.backupsPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *Manager) saveBackups(name string, s *BackupSet) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.backupsPath(name), data)
+}
+
+type BackupOptions struct {
+	Name        string // default: the time, 20060102-150405
+	Incremental bool   // needs the bitmaps of a previous backup on the current nodes of the disks
+	Speed       int64  // BackupGuest, bytes per second, 0 for unlimited
+	Compress    bool   // BackupGuest
+}
+
+// the running guest, its disks and its backups, with the guest locked
+type backupRun struct {
+	g     *Guest
+	c     *Client
+	set   *BackupSet
+	disks []guestDisk
+	name  string // of the new backup
+	kind  BackupKind
+	prev  *Backup
+}
+
+func (m *Manager) startBackup(ctx context.Context, name string, opts *BackupOptions) (*backupRun, func(), error) {
+	if opts == nil {
+		opts = &BackupOptions{}
+	}
+	r := &backupRun{name: opts.Name}
+	if r.name == "" {
+		r.name = time.Now().Format("20060102-150405")
+	}
+	if !validSnapshotName(r.name) {
+		return nil, nil, fmt.Errorf("%w: nome %q", ErrBackup, r.name)
+	}
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	fail := func(err error) (*backupRun, func(), error) {
+		unlock()
+		return nil, nil, err
+	}
+
+	if r.g, err = m.Store().Get(name); err != nil {
+		return fail(err)
+	}
+	if !m.guestRunning(name) {
+		return fail(ErrNotRunning)
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return fail(err)
+	}
+	if r.set, err = m.loadBackups(name); err != nil {
+		return fail(err)
+	}
+	if r.set.Export != nil {
+		return fail(fmt.Errorf("%w: export NBD de %s em andamento", ErrBackup, r.set.Export.Backup))
+	}
+	if r.set.Get(r.name) != nil {
+		return fail(fmt.Errorf("%w: backup %s", os.ErrExist, r.name))
+	}
+	if r.disks = r.g.snapshotDisks(t); len(r.disks) == 0 {
+		return fail(fmt.Errorf("%w: guest sem discos em BlockDevices.Nodes", ErrBackup))
+	}
+
+	if opts.Incremental {
+		r.kind = BackupIncremental
+		for _, d := range r.disks {
+			bm := r.set.Bitmaps[d.name]
+			if bm == nil || bm.Node != d.node.NodeName {
+				return fail(fmt.Errorf("%w: disco %s sem bitmap do último backup, faça um backup completo", ErrBackup, d.name))
+			}
+			if r.prev == nil {
+				r.prev = r.set.Get(bm.Last)
+			}
+			if r.prev == nil || r.prev.Name != bm.Last {
+				return fail(fmt.Errorf("%w: bitmaps de backups diferentes, faça um backup completo", ErrBackup))
+			}
+		}
+	}
+
+	if r.c, err = dialGuest(ctx, r.g); err != nil {
+		return fail(err)
+	}
+	return r, func() { r.c.Close(); unlock() }, nil
+}
+
+// the bitmap of the new backup, recording the writes from now on
+func (r *backupRun) addBitmap(ctx context.Context, d guestDisk) (string, error) {
+	bm := r.newBitmap(d)
+	return bm.Name, r.c.BlockDirtyBitmapAdd(ctx, bm)
+}
+
+func (r *backupRun) newBitmap(d guestDisk) *BlockDirtyBitmapAdd {
+	persistent := d.node.Driver == BlockdevDriverQcow2
+	return &BlockDirtyBitmapAdd{Node: d.node.NodeName, Name: "backup-" + r.name, Persistent: &persistent}
+}
+
+// record a finished backup, the bitmaps of the previous one are removed
+func (m *Manager) commitBackup(ctx context.Context, r *backupRun, b *Backup) error {
+	if r.set.Bitmaps == nil {
+		r.set.Bitmaps = map[string]*BackupBitmap{}
+	}
+	for disk, bd := range b.Disks {
+		if old := r.set.Bitmaps[disk]; old != nil {
+			if err := r.c.BlockDirtyBitmapRemove(ctx, &BlockDirtyBitmap{Node: old.Node, Name: old.Name}); err != nil {
+				m.log.Warn("bitmap not removed", "guest", r.g.Name, "node", old.Node, "bitmap", old.Name, "err", err)
+			}
+		}
+		r.set.Bitmaps[disk] = &BackupBitmap{Node: bd.Node, Name: bd.Bitmap, Generation: bd.Generation, Last: b.Name}
+	}
+	r.set.Backups = append(r.set.Backups, b)
+	return m.saveBackups(r.g.Name, r.set)
+}
+
+func (r *backupRun) newBackup() *Backup {
+	b := &Backup{Name: r.name, Kind: r.kind, Created: time.Now(), Disks: map[string]*BackupDisk{}}
+	if r.prev != nil {
+		b.Parent = r.prev.Name
+	}
+	for _, d := range r.disks {
+		bd := &BackupDisk{Node: d.node.NodeName}
+		if r.prev != nil {
+			bd.Generation = r.prev.Disks[d.name].Generation + 1
+		}
+		b.Disks[d.name] = bd
+	}
+	return b
+}
+
+// qcow2 node over file, opened by blockdev-add
+func qcow2Node(node, file string, backing *BlockdevRefOrNull) *BlockdevOptions {
+	return &BlockdevOptions{
+		Driver:   BlockdevDriverQcow2,
+		NodeName: node,
+		Qcow2: &BlockdevOptionsQcow2{
+			File: &BlockdevRef{Definition: &BlockdevOptions{
+				Driver: BlockdevDriverFile,
+				File:   &BlockdevOptionsFile{Filename: file},
+			}},
+			Backing: backing,
+		},
+	}
+}
+
+/*
+usage:
+
+	b, err := m.BackupGuest(ctx, "web01", &virt.BackupOptions{Incremental: true})
+
+push backup of a running guest with blockdev-backup, one qcow2 per disk in
+<VmBackupPath>/<name>/<backup>/. A full backup copies the whole disks, an
+incremental one only the blocks marked by the dirty bitmaps of the previous
+backup, over an image backed by the previous one. The new bitmaps and the
+copies of all disks start in one transaction, at the same point in time. The
+bitmaps of the previous backup are kept until every copy is done.
+*/
+func (m *Manager) BackupGuest(ctx context.Context, name string, opts *BackupOptions) (*Backup, error) {
+	if opts == nil {
+		opts = &BackupOptions{}
+	}
+	r, done, err := m.startBackup(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if r.prev != nil {
+		for _, d := range r.disks {
+			if r.prev.Disks[d.name].File == "" {
+				return nil, fmt.Errorf("%w: %s foi copiado por NBD, faça um backup completo", ErrBackup, r.prev.Name)
+			}
+		}
+	}
+
+	dir := path.Join(m.backupPath(), name, r.name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	b := r.newBackup()
+	targets := []string{}
+	added := []BlockDirtyBitmap{}
+	rollback := func(err error) (*Backup, error) {
+		for _, target := range targets {
+			r.c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: target})
+		}
+		for _, bm := range added {
+			r.c.BlockDirtyBitmapRemove(ctx, &bm)
+		}
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	actions := []*TransactionAction{}
+	for _, d := range r.disks {
+		bd := b.Disks[d.name]
+		bd.File = path.Join(dir, d.name+".qcow2")
+		io := &ImageOptions{Format: BlockdevDriverQcow2}
+		if r.prev != nil {
+			io.BackingFile, io.BackingFormat = r.prev.Disks[d.name].File, BlockdevDriverQcow2
+		} else {
+			info, err := InspectImage(d.file.File.Filename)
+			if err != nil {
+				return rollback(err)
+			}
+			io.Size = info.VirtualSize
+		}
+		if err := CreateImage(bd.File, io); err != nil {
+			return rollback(err)
+		}
+		file, err := filepath.Abs(bd.File)
+		if err != nil {
+			return rollback(err)
+		}
+		target := "backup-" + d.name
+		if err := r.c.BlockdevAdd(ctx, qcow2Node(target, file, nil)); err != nil {
+			return rollback(err)
+		}
+		targets = append(targets, target)
+
+		bm := r.newBitmap(d)
+		bd.Bitmap = bm.Name
+		no := false
+		args := &BlockdevBackup{
+			JobID:       target,
+			Device:      d.node.NodeName,
+			Target:      target,
+			Sync:        MirrorSyncModeFull,
+			AutoDismiss: &no,
+			Compress:    &opts.Compress,
+		}
+		if opts.Speed > 0 {
+			args.Speed = &opts.Speed
+		}
+		if r.kind == BackupIncremental {
+			args.Sync, args.Bitmap, args.BitmapMode = MirrorSyncModeBitmap, r.set.Bitmaps[d.name].Name, BitmapSyncModeNever
+		}
+		actions = append(actions,
+			&TransactionAction{Type: TransactionActionKindBlockDirtyBitmapAdd, BlockDirtyBitmapAdd: &BlockDirtyBitmapAddWrapper{Data: bm}},
+			&TransactionAction{Type: TransactionActionKindBlockdevBackup, BlockdevBackup: &BlockdevBackupWrapper{Data: args}},
+		)
+	}
+
+	// all or nothing: on error no bitmap was added and no job started
+	if err := r.c.Transaction(ctx, &TransactionArguments{Actions: actions}); err != nil {
+		return rollback(err)
+	}
+	for _, d := range r.disks {
+		added = append(added, BlockDirtyBitmap{Node: d.node.NodeName, Name: b.Disks[d.name].Bitmap})
+	}
+	// every job is waited and dismissed, the first error wins
+	for _, target := range targets {
+		if werr := waitJob(ctx, r.c, target); err == nil {
+			err = werr
+		}
+	}
+	for _, target := range targets {
+		if derr := r.c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: target}); err == nil {
+			err = derr
+		}
+	}
+	targets = nil
+	if err != nil {
+		return rollback(err)
+	}
+
+	if err := m.commitBackup(ctx, r, b); err != nil {
+		return nil, err
+	}
+	m.log.Info("backup created", "guest", name, "backup", b.Name, "kind", b.Kind)
+	return b, nil
+}
+
+// a disk exported by ExportBackup
+type ExportDisk struct {
+	Name   string // NBD export name
+	Node   string // fleecing node, reads see the disk as it was when the export started
+	File   string // image of the fleecing node, removed by FinishExport
+	Bitmap string `yaml:",omitempty"` // blocks written since the previous backup, incremental exports
+	Next   string // bitmap started by this backup
+}
+
+/*
+an NBD export of the guest disks for pull backups. A client reads each disk
+from URI and, for incremental backups, only the blocks dirty in the metadata
+context "qemu:dirty-bitmap:<Bitmap>".
+*/
+type BackupExport struct {
+	Backup string
+	Kind   BackupKind
+	Socket string                 // unix socket of the NBD server
+	Disks  map[string]*ExportDisk // by disk name
+}
+
+func (e *BackupExport) URI(disk string) string {
+	if d, ok := e.Disks[disk]; ok {
+		return fmt.Sprintf("nbd+unix:///%s?socket=%s", d.Name, e.Socket)
+	}
+	return ""
+}
+
+/*
+usage:
+
+	exp, err := m.ExportBackup(ctx, "web01", &virt.BackupOptions{Incremental: true})
+	// copy exp.URI("disk0") ...
+	_, err = m.FinishExport(ctx, "web01", true)
+
+start a pull backup: each disk is frozen behind a fleecing node (a temporary
+qcow2 over the disk, filled by a blockdev-backup sync=none job before the
+guest overwrites a block) and exported read-only by the NBD server of the
+guest, with the bitmap of the previous backup for incremental ones. The
+export lasts until FinishExport.
+*/
+func (m *Manager) ExportBackup(ctx context.Context, name string, opts *BackupOptions) (*BackupExport, error) {
+	r, done, err := m.startBackup(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	dir := path.Join(m.backupPath(), name, r.name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	sock, err := filepath.Abs(path.Join(m.socketPath(), name+".nbd"))
+	if err != nil {
+		return nil, err
+	}
+	exp := &BackupExport{Backup: r.name, Kind: r.kind, Socket: sock, Disks: map[string]*ExportDisk{}}
+	fail := func(err error) (*BackupExport, error) {
+		m.stopExport(ctx, r, exp, false)
+		os.RemoveAll(dir)
+		return nil, errors.Join(err, m.saveBackups(name, r.set))
+	}
+
+	for _, d := range r.disks {
+		info, err := InspectImage(d.file.File.Filename)
+		if err != nil {
+			return fail(err)
+		}
+		ed := &ExportDisk{Name: d.name, Node: "fleece-" + d.name, File: path.Join(dir, d.name+".fleece.qcow2")}
+		if err := CreateImage(ed.File, &ImageOptions{Format: BlockdevDriverQcow2, Size: info.VirtualSize}); err != nil {
+			return fail(err)
+		}
+		file, err := filepath.Abs(ed.File)
+		if err != nil {
+			return fail(err)
+		}
+		backing := d.node.NodeName
+		if err := r.c.BlockdevAdd(ctx, qcow2Node(ed.Node, file, &BlockdevRefOrNull{Reference: &backing})); err != nil {
+			return fail(err)
+		}
+		exp.Disks[d.name] = ed
+
+		// new bitmap, then the point in time, then the old bitmap stops: a write
+		// in between is marked in both and never lost
+		if ed.Next, err = r.addBitmap(ctx, d); err != nil {
+			return fail(err)
+		}
+		no := false
+		err = r.c.BlockdevBackup(ctx, &BlockdevBackup{JobID: ed.Node, Device: d.node.NodeName, Target: ed.Node, Sync: MirrorSyncModeNone, AutoDismiss: &no})
+		if err != nil {
+			return fail(err)
+		}
+		if r.kind == BackupIncremental {
+			ed.Bitmap = r.set.Bitmaps[d.name].Name
+			if err := r.c.BlockDirtyBitmapDisable(ctx, &BlockDirtyBitmap{Node: d.node.NodeName, Name: ed.Bitmap}); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	os.Remove(sock)
+	addr := &SocketAddressLegacy{Type: SocketAddressTypeUnix, Unix: &UnixSocketAddressWrapper{Data: &UnixSocketAddress{Path: sock}}}
+	if err := r.c.NBDServerStart(ctx, &NBDServerStartArguments{Addr: addr}); err != nil {
+		return fail(err)
+	}
+	for _, d := range r.disks {
+		ed := exp.Disks[d.name]
+		nbd := &BlockExportOptionsNbd{Name: ed.Name}
+		if ed.Bitmap != "" {
+			nbd.Bitmaps = []string{ed.Bitmap}
+		}
+		if err := r.c.BlockExportAdd(ctx, &BlockExportOptions{Type: BlockExportTypeNBD, ID: ed.Node, NodeName: ed.Node, NBD: nbd}); err != nil {
+			return fail(err)
+		}
+	}
+
+	r.set.Export = exp
+	if err := m.saveBackups(name, r.set); err != nil {
+		return fail(err)
+	}
+	m.log.Info("backup exported", "guest", name, "backup", exp.Backup, "kind", exp.Kind, "socket", sock)
+	return exp, nil
+}
+
+/*
+tear down the export: NBD exports and server, fleecing jobs and nodes. The
+bitmaps started by it are dropped unless the backup completed (the old ones
+then go in commitBackup). A failed incremental export merges the old bitmap,
+disabled by the export, into the new one that takes its place.
+*/
+func (m *Manager) stopExport(ctx context.Context, r *backupRun, exp *BackupExport, completed bool) error {
+	errs := []error{}
+	for _, ed := range exp.Disks {
+		r.c.BlockExportDel(ctx, &BlockExportDelArguments{ID: ed.Node, Mode: BlockExportRemoveModeHard})
+	}
+	r.c.NBDServerStop(ctx)
+	os.Remove(exp.Socket)
+
+	for _, d := range r.disks {
+		ed := exp.Disks[d.name]
+		if ed == nil {
+			continue
+		}
+		if err := cancelJob(ctx, r.c, ed.Node); err != nil {
+			m.log.Warn("fleecing job not cancelled", "guest", r.g.Name, "job", ed.Node, "err", err)
+		}
+		if err := r.c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: ed.Node}); err != nil {
+			errs = append(errs, err)
+		}
+		os.Remove(ed.File)
+		if completed || ed.Next == "" {
+			continue
+		}
+		node := d.node.NodeName
+		if ed.Bitmap != "" {
+			// the old bitmap stopped at the start of the export, the new one has the writes since
+			err := r.c.BlockDirtyBitmapMerge(ctx, &BlockDirtyBitmapMerge{Node: node, Target: ed.Next, Bitmaps: []string{ed.Bitmap}})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			r.c.BlockDirtyBitmapRemove(ctx, &BlockDirtyBitmap{Node: node, Name: ed.Bitmap})
+			r.set.Bitmaps[d.name].Name = ed.Next
+		} else {
+			r.c.BlockDirtyBitmapRemove(ctx, &BlockDirtyBitmap{Node: node, Name: ed.Next})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+end the export of ExportBackup. completed tells the client copied every disk:
+the backup is recorded (without images, see BackupDisk) and the next
+incremental one starts from it. Otherwise the bitmaps are kept as if the
+export never happened. If the guest stopped meanwhile the next backup of the
+exported disks must be a full one.
+*/
+func (m *Manager) FinishExport(ctx context.Context, name string, completed bool) (*Backup, error) {
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return nil, err
+	}
+	set, err := m.loadBackups(name)
+	if err != nil {
+		return nil, err
+	}
+	exp := set.Export
+	if exp == nil {
+		return nil, fmt.Errorf("%w: export NBD de %s", os.ErrNotExist, name)
+	}
+	set.Export = nil
+	dir := path.Join(m.backupPath(), name, exp.Backup)
+	if !m.guestRunning(name) {
+		// the export went with QEMU, and the bitmaps of the disks can not be trusted
+		for disk := range exp.Disks {
+			delete(set.Bitmaps, disk)
+		}
+		os.Remove(exp.Socket)
+		os.RemoveAll(dir)
+		return nil, errors.Join(ErrNotRunning, m.saveBackups(name, set))
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialGuest(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	r := &backupRun{g: g, c: c, set: set, name: exp.Backup, kind: exp.Kind}
+	for _, d := range g.snapshotDisks(t) {
+		if _, ok := exp.Disks[d.name]; ok {
+			r.disks = append(r.disks, d)
+		}
+	}
+	if exp.Kind == BackupIncremental {
+		for _, d := range r.disks {
+			if bm := set.Bitmaps[d.name]; bm != nil {
+				r.prev = set.Get(bm.Last)
+			}
+		}
+	}
+	if err := m.stopExport(ctx, r, exp, completed); err != nil {
+		m.log.Warn("export not fully removed", "guest", name, "backup", exp.Backup, "err", err)
+	}
+	os.RemoveAll(dir)
+	if !completed {
+		m.log.Info("backup export aborted", "guest", name, "backup", exp.Backup)
+		return nil, m.saveBackups(name, set)
+	}
+
+	b := r.newBackup()
+	for disk, bd := range b.Disks {
+		bd.Bitmap = exp.Disks[disk].Next
+	}
+	if err := m.commitBackup(ctx, r, b); err != nil {
+		return nil, err
+	}
+	m.log.Info("backup exported", "guest", name, "backup", b.Name, "kind", b.Kind)
+	return b, nil
+}
+
+type RestoreOptions struct {
+	Pool   string         // default: DefaultPool
+	Volume string         // default: <name>-<disk>-<backup>.<format>
+	Format BlockdevDriver // qcow2 (default) or raw
+}
+
+/*
+usage:
+
+	vol, err := m.RestoreBackup("web01", "20261017-020000", "disk0", nil)
+
+copy a disk of a backup, with the chain of backups below it, into a new volume
+*/
+func (m *Manager) RestoreBackup(name, backup, disk string, opts *RestoreOptions) (*Volume, error) {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
+	set, err := m.loadBackups(name)
+	if err != nil {
+		return nil, err
+	}
+	chain := set.Chain(backup)
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: backup %s", os.ErrNotExist, backup)
+	}
+	if chain[0].Kind != BackupFull {
+		return nil, fmt.Errorf("%w: cadeia de %s sem o backup completo", ErrBackup, backup)
+	}
+	for _, b := range chain {
+		bd := b.Disks[disk]
+		if bd == nil {
+			return nil, fmt.Errorf("%w: disco %s não está em %s", ErrBackup, disk, b.Name)
+		}
+		if bd.File == "" {
+			return nil, fmt.Errorf("%w: %s foi copiado por NBD, as imagens estão com o cliente", ErrBackup, b.Name)
+		}
+	}
+
+	pool := cmp.Or(opts.Pool, DefaultPool)
+	p, err := m.FindPool(pool)
+	if err != nil {
+		return nil, err
+	}
+	format := cmp.Or(opts.Format, BlockdevDriverQcow2)
+	vol := cmp.Or(opts.Volume, fmt.Sprintf("%s-%s-%s.%s", name, disk, backup, format))
+	v, err := p.Import(vol, chain[len(chain)-1].Disks[disk].File, &ConvertOptions{Format: format})
+	if err != nil {
+		return nil, err
+	}
+	m.log.Info("backup restored", "guest", name, "backup", backup, "disk", disk, "pool", pool, "volume", v.Name)
+	return v, nil
+}
+
+func (m *Manager) Backups(name string) (*BackupSet, error) {
+	if _, err := m.Store().Get(name); err != nil {
+		return nil, err
+	}
+	return m.loadBackups(name)
+}
+
+// push backup of a running guest, see Manager.BackupGuest
+func BackupGuest(ctx context.Context, name string, opts *BackupOptions) (*Backup, error) {
+	return defaultManager.BackupGuest(ctx, name, opts)
+}
+
+// start a pull backup over NBD, see Manager.ExportBackup
+func ExportBackup(ctx context.Context, name string, opts *BackupOptions) (*BackupExport, error) {
+	return defaultManager.ExportBackup(ctx, name, opts)
+}
+
+// end the export of ExportBackup, see Manager.FinishExport
+func FinishExport(ctx context.Context, name string, completed bool) (*Backup, error) {
+	return defaultManager.FinishExport(ctx, name, completed)
+}
+
+// copy a disk of a backup chain into a new volume, see Manager.RestoreBackup
+func RestoreBackup(name, backup, disk string, opts *RestoreOptions) (*Volume, error) {
+	return defaultManager.RestoreBackup(name, backup, disk, opts)
+}
+
+// the backups and dirty bitmaps of a guest
+func Backups(name string) (*BackupSet, error) { return defaultManager.Backups(name) }
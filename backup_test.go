@@ -0,0 +1,313 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// a guest "vm1" with the qcow2 disk "disk0", running for the manager, and its scripted QMP endpoint
+func runningGuest(t *testing.T) (*Manager, *fakeQmp) {
+	t.Helper()
+	fakeQemuImg(t, infoJSON, "0")
+	dir := t.TempDir()
+	m := NewManager(Config{
+		DataPath:    filepath.Join(dir, "data"),
+		StoragePath: filepath.Join(dir, "disks"),
+		SocketPath:  filepath.Join(dir, "sock"),
+		BackupPath:  filepath.Join(dir, "backups"),
+	})
+	for _, d := range []string{"data", "disks", "sock"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	disk := filepath.Join(dir, "disks", "vm1-disk0.qcow2")
+	if err := os.WriteFile(disk, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "sock", "vm1.qmp")
+
+	g := m.NewGuest("vm1")
+	g.Qmp = &QmpOptions{ProtoPath: "unix:" + sock, Serve: true}
+	fileNode, node := diskNodes("disk0", BlockdevDriverQcow2, disk)
+	g.BlockDevices = &BlockDevicesOptions{Nodes: []*BlockNode{fileNode, node}}
+	if err := m.CreateGuest(g); err != nil {
+		t.Fatal(err)
+	}
+	qemu := startFakeQemu(t, m.Supervisor(), "vm1")
+	t.Cleanup(func() {
+		qemu.Process.Kill()
+		qemu.Wait()
+	})
+	return m, newFakeQmp(t, sock)
+}
+
+// the data of the n-th action of kind in the last transaction
+func transactionData(t *testing.T, f *fakeQmp, kind string, n int) map[string]any {
+	t.Helper()
+	actions, _ := f.args("transaction")["actions"].([]any)
+	for _, a := range actions {
+		a, _ := a.(map[string]any)
+		if a["type"] != kind {
+			continue
+		}
+		if n == 0 {
+			data, _ := a["data"].(map[string]any)
+			return data
+		}
+		n--
+	}
+	t.Errorf("transaction %v without %s", actions, kind)
+	return nil
+}
+
+func jobStatus(id, status string) string {
+	return fmt.Sprintf(`{"event": "JOB_STATUS_CHANGE", "data": {"id": %q, "status": %q}, "timestamp": {"seconds": 1, "microseconds": 0}}`, id, status)
+}
+
+// Job.Wait of a job running until the events that follow the first query, then the dismiss
+func waitJobSteps(id, errMsg string, events ...string) []qmpStep {
+	concluded := fmt.Sprintf(`[{"id": %q, "type": "backup", "status": "concluded", "current-progress": 1048576, "total-progress": 1048576}]`, id)
+	if errMsg != "" {
+		concluded = fmt.Sprintf(`[{"id": %q, "type": "backup", "status": "concluded", "current-progress": 0, "total-progress": 1048576, "error": %q}]`, id, errMsg)
+	}
+	return []qmpStep{
+		{cmd: "query-jobs", ret: fmt.Sprintf(`[{"id": %q, "type": "backup", "status": "running", "current-progress": 0, "total-progress": 1048576}]`, id)},
+		{cmd: "query-block-jobs", ret: `[]`, events: events},
+		{cmd: "query-jobs", ret: concluded},
+		{cmd: "query-block-jobs", ret: `[]`},
+		{cmd: "job-dismiss"},
+	}
+}
+
+// the steps of BackupGuest for disk0, the job ending with errMsg
+func backupSteps(backup, errMsg string) []qmpStep {
+	id := "backup-disk0"
+	steps := []qmpStep{
+		{cmd: "blockdev-add"},
+		{cmd: "transaction", events: []string{jobStatus(id, "created"), jobStatus(id, "running")}},
+	}
+	steps = append(steps, waitJobSteps(id, errMsg, jobStatus(id, "waiting"), jobStatus(id, "pending"), jobStatus(id, "concluded"))...)
+	return append(steps, qmpStep{cmd: "blockdev-del"})
+}
+
+func TestBackupFullThenIncremental(t *testing.T) {
+	m, f := runningGuest(t)
+	ctx := context.Background()
+
+	f.script(backupSteps("b1", "")...)
+	b1, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transactionData(t, f, "block-dirty-bitmap-add", 0); !reflect.DeepEqual(got, map[string]any{"node": "disk0", "name": "backup-b1", "persistent": true}) {
+		t.Errorf("block-dirty-bitmap-add %v", got)
+	}
+	if got := transactionData(t, f, "blockdev-backup", 0); got["sync"] != "full" || got["device"] != "disk0" || got["target"] != "backup-disk0" || got["auto-dismiss"] != false {
+		t.Errorf("blockdev-backup %v", got)
+	}
+	if b1.Kind != BackupFull || b1.Disks["disk0"].Generation != 0 || b1.Disks["disk0"].Bitmap != "backup-b1" {
+		t.Errorf("full backup %+v %+v", b1, b1.Disks["disk0"])
+	}
+
+	f.script(backupSteps("b2", "")...)
+	f.script(qmpStep{cmd: "block-dirty-bitmap-remove"})
+	b2, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b2", Incremental: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := transactionData(t, f, "blockdev-backup", 0)
+	if got["sync"] != "bitmap" || got["bitmap"] != "backup-b1" || got["bitmap-mode"] != "never" {
+		t.Errorf("incremental blockdev-backup %v", got)
+	}
+	if got := f.args("block-dirty-bitmap-remove"); !reflect.DeepEqual(got, map[string]any{"node": "disk0", "name": "backup-b1"}) {
+		t.Errorf("block-dirty-bitmap-remove %v", got)
+	}
+	if b2.Kind != BackupIncremental || b2.Parent != "b1" || b2.Disks["disk0"].Generation != 1 {
+		t.Errorf("incremental backup %+v %+v", b2, b2.Disks["disk0"])
+	}
+
+	set, err := m.Backups("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Backups) != 2 || set.Backups[0].Name != "b1" || set.Backups[1].Name != "b2" {
+		t.Fatalf("backups %+v", set.Backups)
+	}
+	want := &BackupBitmap{Node: "disk0", Name: "backup-b2", Generation: 1, Last: "b2"}
+	if bm := set.Bitmaps["disk0"]; !reflect.DeepEqual(bm, want) {
+		t.Errorf("bitmap %+v, want %+v", bm, want)
+	}
+	if chain := set.Chain("b2"); len(chain) != 2 || chain[0].Name != "b1" {
+		t.Errorf("chain %+v", chain)
+	}
+}
+
+func TestBackupOneTransaction(t *testing.T) {
+	m, f := runningGuest(t)
+	g, err := m.Store().Get("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk := filepath.Join(m.storagePath(), "vm1-disk1.qcow2")
+	touch(t, disk)
+	fileNode, node := diskNodes("disk1", BlockdevDriverQcow2, disk)
+	g.BlockDevices.Nodes = append(g.BlockDevices.Nodes, fileNode, node)
+	if err := m.Store().Put(g); err != nil {
+		t.Fatal(err)
+	}
+
+	// both targets, then the bitmaps and the jobs of both disks at once
+	f.script(qmpStep{cmd: "blockdev-add"}, qmpStep{cmd: "blockdev-add"}, qmpStep{cmd: "transaction"})
+	f.script(waitJobSteps("backup-disk0", "", jobStatus("backup-disk0", "concluded"))...)
+	f.script(waitJobSteps("backup-disk1", "", jobStatus("backup-disk1", "concluded"))...)
+	f.script(qmpStep{cmd: "blockdev-del"}, qmpStep{cmd: "blockdev-del"})
+	if _, err := m.BackupGuest(context.Background(), "vm1", &BackupOptions{Name: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	kinds := []any{}
+	for _, a := range f.args("transaction")["actions"].([]any) {
+		kinds = append(kinds, a.(map[string]any)["type"])
+	}
+	if want := []any{"block-dirty-bitmap-add", "blockdev-backup", "block-dirty-bitmap-add", "blockdev-backup"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("transaction %v, want %v", kinds, want)
+	}
+	for n, node := range []string{"disk0", "disk1"} {
+		if got := transactionData(t, f, "blockdev-backup", n); got["device"] != node || got["job-id"] != "backup-"+node {
+			t.Errorf("blockdev-backup %v", got)
+		}
+	}
+}
+
+func TestBackupJobFailure(t *testing.T) {
+	m, f := runningGuest(t)
+	ctx := context.Background()
+	f.script(backupSteps("b1", "")...)
+	if _, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the job fails, the new bitmap goes and the chain is left as it was
+	f.script(backupSteps("b2", "No space left on device")...)
+	f.script(qmpStep{cmd: "block-dirty-bitmap-remove"})
+	_, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b2", Incremental: true})
+	var je *JobError
+	if !errors.As(err, &je) || je.Message != "No space left on device" {
+		t.Fatalf("err = %v, want *JobError", err)
+	}
+	if got := f.args("block-dirty-bitmap-remove"); got["name"] != "backup-b2" {
+		t.Errorf("block-dirty-bitmap-remove %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(m.backupPath(), "vm1", "b2")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup dir left: %v", err)
+	}
+
+	// a command refused by QEMU
+	f.script(qmpStep{cmd: "blockdev-add"}, qmpStep{cmd: "transaction", err: "Bitmap already exists"}, qmpStep{cmd: "blockdev-del"})
+	if _, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b3", Incremental: true}); err == nil {
+		t.Fatal("bitmap error not returned")
+	}
+
+	set, err := m.Backups("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Backups) != 1 || set.Bitmaps["disk0"].Name != "backup-b1" {
+		t.Errorf("backups %+v, bitmap %+v", set.Backups, set.Bitmaps["disk0"])
+	}
+}
+
+func TestBackupJobCancelled(t *testing.T) {
+	m, f := runningGuest(t)
+	ctx := context.Background()
+
+	// job-cancel from another client, the job concludes with an error
+	id := "backup-disk0"
+	steps := []qmpStep{{cmd: "blockdev-add"}, {cmd: "transaction"}}
+	steps = append(steps, waitJobSteps(id, "Operation cancelled", jobStatus(id, "aborting"), jobStatus(id, "concluded"))...)
+	f.script(steps...)
+	f.script(qmpStep{cmd: "blockdev-del"}, qmpStep{cmd: "block-dirty-bitmap-remove"})
+	_, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b1"})
+	var je *JobError
+	if !errors.As(err, &je) || je.ID != id {
+		t.Fatalf("err = %v, want *JobError", err)
+	}
+	set, err := m.Backups("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Backups) != 0 || len(set.Bitmaps) != 0 {
+		t.Errorf("cancelled backup recorded: %+v", set)
+	}
+}
+
+func TestExportBackupAborted(t *testing.T) {
+	m, f := runningGuest(t)
+	ctx := context.Background()
+	f.script(backupSteps("b1", "")...)
+	if _, err := m.BackupGuest(ctx, "vm1", &BackupOptions{Name: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.script(
+		qmpStep{cmd: "blockdev-add"},
+		qmpStep{cmd: "block-dirty-bitmap-add"},
+		qmpStep{cmd: "blockdev-backup"},
+		qmpStep{cmd: "block-dirty-bitmap-disable"},
+		qmpStep{cmd: "nbd-server-start"},
+		qmpStep{cmd: "block-export-add"},
+	)
+	exp, err := m.ExportBackup(ctx, "vm1", &BackupOptions{Name: "p1", Incremental: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.args("block-export-add"); !reflect.DeepEqual(got["bitmaps"], []any{"backup-b1"}) {
+		t.Errorf("block-export-add %v", got)
+	}
+	if exp.Disks["disk0"].Bitmap != "backup-b1" || exp.Disks["disk0"].Next != "backup-p1" {
+		t.Errorf("export %+v", exp.Disks["disk0"])
+	}
+
+	// the client gave up: the fleecing job is cancelled and the bitmaps merged back
+	id := "fleece-disk0"
+	f.script(qmpStep{cmd: "block-export-del"}, qmpStep{cmd: "nbd-server-stop"}, qmpStep{cmd: "job-cancel"})
+	f.script(waitJobSteps(id, "Operation cancelled", jobStatus(id, "aborting"), jobStatus(id, "concluded"))...)
+	f.script(qmpStep{cmd: "blockdev-del"}, qmpStep{cmd: "block-dirty-bitmap-merge"}, qmpStep{cmd: "block-dirty-bitmap-remove"})
+	b, err := m.FinishExport(ctx, "vm1", false)
+	if err != nil || b != nil {
+		t.Fatalf("FinishExport = %v, %v", b, err)
+	}
+	if got := f.args("block-dirty-bitmap-merge"); got["target"] != "backup-p1" || !reflect.DeepEqual(got["bitmaps"], []any{"backup-b1"}) {
+		t.Errorf("block-dirty-bitmap-merge %v", got)
+	}
+	set, err := m.Backups("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Export != nil || len(set.Backups) != 1 || set.Bitmaps["disk0"].Name != "backup-p1" {
+		t.Errorf("after abort: export %+v, backups %d, bitmap %+v", set.Export, len(set.Backups), set.Bitmaps["disk0"])
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	m, f := runningGuest(t)
+	f.script(backupSteps("b1", "")...)
+	if _, err := m.BackupGuest(context.Background(), "vm1", &BackupOptions{Name: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.RestoreBackup("vm1", "b1", "disk0", &RestoreOptions{Format: BlockdevDriverRaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "vm1-disk0-b1.raw" {
+		t.Errorf("volume %s", v.Name)
+	}
+	if _, err := m.RestoreBackup("vm1", "b9", "disk0", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unknown backup: %v", err)
+	}
+}
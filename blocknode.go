@@ -0,0 +1,363 @@
+package virt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+/*
+usage:
+
+	g.BlockDevices.Nodes = []*virt.BlockNode{
+		{Driver: virt.BlockdevDriverHostDevice, NodeName: "sdb", File: &virt.BlockFileOptions{Filename: "/dev/sdb"}},
+		{Driver: virt.BlockdevDriverQcow2, NodeName: "disk0", Format: &virt.BlockFormatOptions{File: "sdb"}},
+	}
+
+a -blockdev node with the options of its driver, the children (file, backing,
+data-file) are other nodes referenced by node-name. Only the options struct
+matching Driver is used:
+
+	file, host_device      File
+	qcow2, raw             Format
+	luks                   Luks
+	nbd                    Nbd
+	throttle               Throttle
+*/
+type BlockNode struct {
+	Driver       BlockdevDriver              `yaml:",omitempty" qemu:"driver,implied"`
+	NodeName     string                      `yaml:",omitempty" qemu:"node-name"`
+	ReadOnly     bool                        `yaml:",omitempty" qemu:"read-only"`
+	AutoReadOnly bool                        `yaml:",omitempty" qemu:"auto-read-only"`
+	ForceShare   bool                        `yaml:",omitempty" qemu:"force-share"`
+	Discard      BlockdevDiscardOptions      `yaml:",omitempty" qemu:"discard"`
+	DetectZeroes BlockdevDetectZeroesOptions `yaml:",omitempty" qemu:"detect-zeroes"`
+	Cache        *BlockNodeCache             `yaml:",omitempty" qemu:"cache"`
+
+	File     *BlockFileOptions     `yaml:",omitempty"` // file, host_device
+	Format   *BlockFormatOptions   `yaml:",omitempty"` // qcow2, raw
+	Luks     *BlockLuksOptions     `yaml:",omitempty"` // luks
+	Nbd      *BlockNbdOptions      `yaml:",omitempty"` // nbd
+	Throttle *BlockThrottleOptions `yaml:",omitempty"` // throttle
+}
+
+type BlockNodeCache struct {
+	Direct  bool `yaml:",omitempty" qemu:"direct"`   // O_DIRECT
+	NoFlush bool `yaml:",omitempty" qemu:"no-flush"` // ignore flush requests
+}
+
+// file, host_device
+type BlockFileOptions struct {
+	Filename string             `yaml:",omitempty" qemu:"filename"`
+	Aio      BlockdevAioOptions `yaml:",omitempty" qemu:"aio"`     // threads|native|io_uring
+	Locking  OnOffAuto          `yaml:",omitempty" qemu:"locking"` // on|off|auto
+}
+
+// qcow2, raw
+type BlockFormatOptions struct {
+	File     string `yaml:",omitempty" qemu:"file"`      // node-name of the image
+	Backing  string `yaml:",omitempty" qemu:"backing"`   // qcow2, node-name of the backing image
+	DataFile string `yaml:",omitempty" qemu:"data-file"` // qcow2, node-name of the external data file
+}
+
+type BlockLuksOptions struct {
+	File      string `yaml:",omitempty" qemu:"file"`       // node-name of the encrypted image
+	KeySecret string `yaml:",omitempty" qemu:"key-secret"` // id of the secret object with the passphrase
+}
+
+type BlockNbdOptions struct {
+	Server   *BlockNbdServer `yaml:",omitempty" qemu:"server"`
+	Export   string          `yaml:",omitempty" qemu:"export"`
+	TLSCreds string          `yaml:",omitempty" qemu:"tls-creds"`
+}
+
+// server.type=inet,server.host=...,server.port=... or server.type=unix,server.path=...
+type BlockNbdServer struct {
+	Type string `yaml:",omitempty" qemu:"type"` // inet|unix
+	Host string `yaml:",omitempty" qemu:"host"`
+	Port string `yaml:",omitempty" qemu:"port"`
+	Path string `yaml:",omitempty" qemu:"path"`
+}
+
+type BlockThrottleOptions struct {
+	File          string `yaml:",omitempty" qemu:"file"`           // node-name of the throttled node
+	ThrottleGroup string `yaml:",omitempty" qemu:"throttle-group"` // id of the throttle-group object
+}
+
+// the options struct of n.Driver, allocated when alloc is set; nil for other drivers
+func (n *BlockNode) driverOptions(alloc bool) any {
+	switch n.Driver {
+	case BlockdevDriverFile, BlockdevDriverHostDevice:
+		if n.File == nil && alloc {
+			n.File = &BlockFileOptions{}
+		}
+		if n.File != nil {
+			return n.File
+		}
+	case BlockdevDriverQcow2, BlockdevDriverRaw:
+		if n.Format == nil && alloc {
+			n.Format = &BlockFormatOptions{}
+		}
+		if n.Format != nil {
+			return n.Format
+		}
+	case BlockdevDriverLUKS:
+		if n.Luks == nil && alloc {
+			n.Luks = &BlockLuksOptions{}
+		}
+		if n.Luks != nil {
+			return n.Luks
+		}
+	case BlockdevDriverNBD:
+		if n.Nbd == nil && alloc {
+			n.Nbd = &BlockNbdOptions{}
+		}
+		if n.Nbd != nil {
+			return n.Nbd
+		}
+	case BlockdevDriverThrottle:
+		if n.Throttle == nil && alloc {
+			n.Throttle = &BlockThrottleOptions{}
+		}
+		if n.Throttle != nil {
+			return n.Throttle
+		}
+	}
+	return nil
+}
+
+func isBlockNodeDriver(d BlockdevDriver) bool {
+	return (&BlockNode{Driver: d}).driverOptions(true) != nil
+}
+
+// node-names referenced by n, keyed by option
+func (n *BlockNode) children() []qemuOpt {
+	refs := []qemuOpt{}
+	add := func(key, name string) {
+		if name != "" {
+			refs = append(refs, qemuOpt{key, name})
+		}
+	}
+	switch d := n.driverOptions(false).(type) {
+	case *BlockFormatOptions:
+		add("file", d.File)
+		add("backing", d.Backing)
+		add("data-file", d.DataFile)
+	case *BlockLuksOptions:
+		add("file", d.File)
+	case *BlockThrottleOptions:
+		add("file", d.File)
+	}
+	return refs
+}
+
+func (n *BlockNode) ToArgs() ([]string, error) {
+	opts, err := MarshalQemuOpts(n)
+	if err != nil {
+		return nil, fmt.Errorf("-blockdev %s: %w", n.NodeName, err)
+	}
+	if d := n.driverOptions(false); d != nil {
+		s, err := MarshalQemuOpts(d)
+		if err != nil {
+			return nil, fmt.Errorf("-blockdev %s: %w", n.NodeName, err)
+		}
+		if s != "" {
+			opts += "," + s
+		}
+	}
+	return []string{"-blockdev", opts}, nil
+}
+
+func (n *BlockNode) ToJSONArgs() ([]string, error) {
+	obj, err := n.jsonObject()
+	if err != nil {
+		return nil, fmt.Errorf("-blockdev %s: %w", n.NodeName, err)
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("-blockdev %s: %w", n.NodeName, err)
+	}
+	return []string{"-blockdev", string(b)}, nil
+}
+
+// the JSON form of the node, also the arguments of blockdev-add
+func (n *BlockNode) jsonObject() (map[string]any, error) {
+	obj, err := qemuJSONObject(n)
+	if err != nil {
+		return nil, err
+	}
+	if d := n.driverOptions(false); d != nil {
+		do, err := qemuJSONObject(d)
+		if err != nil {
+			return nil, err
+		}
+		mergeMaps(obj, do)
+	}
+	return obj, nil
+}
+
+// decode -blockdev options, ErrUnknownOpt for drivers and keys without a typed field
+func decodeBlockNode(opts []qemuOpt, n *BlockNode) error {
+	byKey, _, _ := qemuKeys(qemuFieldsOf(reflect.TypeFor[BlockNode]()))
+	generic, specific := []qemuOpt{}, []qemuOpt{}
+	for _, o := range opts {
+		if _, ok := byKey[o.Key]; ok {
+			generic = append(generic, o)
+		} else {
+			specific = append(specific, o)
+		}
+	}
+	out := &BlockNode{}
+	if err := decodeQemuOpts(generic, out); err != nil {
+		return err
+	}
+	d := out.driverOptions(true)
+	if d == nil {
+		return fmt.Errorf("%w: driver %s", ErrUnknownOpt, out.Driver)
+	}
+	if err := decodeQemuOpts(specific, d); err != nil {
+		return err
+	}
+	*n = *out
+	return nil
+}
+
+// nodes ordered so that children come before their parents, as QEMU needs
+func sortBlockNodes(nodes []*BlockNode) []*BlockNode {
+	byName := map[string]*BlockNode{}
+	for _, n := range nodes {
+		byName[n.NodeName] = n
+	}
+	sorted := []*BlockNode{}
+	state := map[*BlockNode]int{} // 1 visiting, 2 done
+	var visit func(n *BlockNode)
+	visit = func(n *BlockNode) {
+		if state[n] != 0 {
+			return
+		}
+		state[n] = 1
+		for _, c := range n.children() {
+			if child, ok := byName[c.Value]; ok {
+				visit(child)
+			}
+		}
+		state[n] = 2
+		sorted = append(sorted, n)
+	}
+	for _, n := range nodes {
+		visit(n)
+	}
+	return sorted
+}
+
+// node-names unique and set, driver options present, children existing and no cycles
+func (b *BlockDevicesOptions) validateNodes(v *validator) {
+	byName := map[string]*BlockNode{}
+	index := map[*BlockNode]string{}
+	if b.BlockDev != nil && b.BlockDev.NodeName != "" {
+		byName[b.BlockDev.NodeName] = nil
+	}
+	for i, n := range b.Nodes {
+		field := fmt.Sprintf("BlockDevices.Nodes[%d]", i)
+		index[n] = field
+		if n.NodeName == "" {
+			v.add(field+".NodeName", "vazio")
+		} else if _, dup := byName[n.NodeName]; dup {
+			v.add(field+".NodeName", "duplicado: %s", n.NodeName)
+		} else {
+			byName[n.NodeName] = n
+		}
+		n.validate(v, field)
+	}
+
+	for _, n := range b.Nodes {
+		for _, c := range n.children() {
+			if _, ok := byName[c.Value]; !ok {
+				v.add(index[n], "%s: nó inexistente: %s", c.Key, c.Value)
+			}
+		}
+	}
+
+	// depth-first search, a node seen again while visiting closes a cycle
+	state := map[*BlockNode]int{}
+	var path []string
+	var visit func(n *BlockNode) bool
+	visit = func(n *BlockNode) bool {
+		switch state[n] {
+		case 1:
+			i := slices.Index(path, n.NodeName)
+			v.add(index[n], "ciclo: %s", strings.Join(append(path[i:], n.NodeName), " -> "))
+			return false
+		case 2:
+			return true
+		}
+		state[n] = 1
+		path = append(path, n.NodeName)
+		defer func() { path = path[:len(path)-1] }()
+		for _, c := range n.children() {
+			if child := byName[c.Value]; child != nil && !visit(child) {
+				return false
+			}
+		}
+		state[n] = 2
+		return true
+	}
+	for _, n := range b.Nodes {
+		if !visit(n) {
+			break
+		}
+	}
+}
+
+func (n *BlockNode) validate(v *validator, field string) {
+	d := n.driverOptions(false)
+	if d == nil {
+		if isBlockNodeDriver(n.Driver) {
+			v.add(field, "opções do driver %s ausentes", n.Driver)
+		} else {
+			v.add(field+".Driver", "driver não suportado: %q", n.Driver)
+		}
+		return
+	}
+	switch d := d.(type) {
+	case *BlockFileOptions:
+		if d.Filename == "" {
+			v.add(field+".File.Filename", "vazio")
+		}
+		v.file(field+".File.Filename", d.Filename)
+	case *BlockFormatOptions:
+		if d.File == "" {
+			v.add(field+".Format.File", "vazio")
+		}
+		if n.Driver == BlockdevDriverRaw && (d.Backing != "" || d.DataFile != "") {
+			v.add(field+".Format", "raw não aceita backing nem data-file")
+		}
+	case *BlockLuksOptions:
+		if d.File == "" {
+			v.add(field+".Luks.File", "vazio")
+		}
+		if d.KeySecret == "" {
+			v.add(field+".Luks.KeySecret", "vazio")
+		}
+	case *BlockNbdOptions:
+		switch s := d.Server; {
+		case s == nil:
+			v.add(field+".Nbd.Server", "vazio")
+		case s.Type == "inet" && s.Host == "":
+			v.add(field+".Nbd.Server.Host", "vazio")
+		case s.Type == "unix" && s.Path == "":
+			v.add(field+".Nbd.Server.Path", "vazio")
+		case s.Type != "inet" && s.Type != "unix":
+			v.add(field+".Nbd.Server.Type", "tipo inválido: %q", s.Type)
+		}
+	case *BlockThrottleOptions:
+		if d.File == "" {
+			v.add(field+".Throttle.File", "vazio")
+		}
+		if d.ThrottleGroup == "" {
+			v.add(field+".Throttle.ThrottleGroup", "vazio")
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package virt
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type CloneMode int
+
+const (
+	CloneFull   CloneMode = iota // independent copy of every disk
+	CloneLinked                  // qcow2 overlay backed by the template image
+)
+
+// random MAC with the QEMU prefix 52:54:00
+func randomMAC() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", b[0], b[1], b[2])
+}
+
+// replace or add key in a raw option string
+func setOptValue(raw, key, value string) string {
+	opts := parseOpts(raw, "")
+	for i, o := range opts {
+		if o.Key == key {
+			opts[i].Value = value
+			return joinOpts(opts)
+		}
+	}
+	return joinOpts(append(opts, qemuOpt{key, value}))
+}
+
+// set a property in Props or Properties, wherever it already is
+func (d *DeviceOptions) setProp(key, value string) {
+	if _, ok := d.Props[key]; ok {
+		d.Props[key] = value
+		return
+	}
+	d.Properties = setOptValue(d.Properties, key, value)
+}
+
+/*
+replace the template name src when it is a whole token of s: s itself, or a
+src- prefix or a -src suffix, ignoring the extension. vm1 in vm10-disk0 or
+net-vm1x is left alone.
+*/
+func renameToken(s, src, newName string) string {
+	for _, ext := range []string{"", path.Ext(s)} {
+		stem := strings.TrimSuffix(s, ext)
+		switch {
+		case stem == src:
+			return newName + ext
+		case strings.HasPrefix(stem, src+"-"):
+			return newName + strings.TrimPrefix(stem, src) + ext
+		case strings.HasSuffix(stem, "-"+src):
+			return strings.TrimSuffix(stem, src) + newName + ext
+		}
+	}
+	return s
+}
+
+// renameToken on the base name of p
+func renamePath(p, src, newName string) string {
+	base := path.Base(p)
+	if n := renameToken(base, src, newName); n != base {
+		return strings.TrimSuffix(p, base) + n
+	}
+	return p
+}
+
+/*
+the identity of the clone: new MACs where the template sets one, the template
+name replaced by newName in netdev IDs (and their references), tap ifnames
+and socket paths, see renameToken. A tap ifname without the template name is dropped, two
+guests can not share it, and the QMP unix socket is always moved to a path of
+the clone.
+*/
+func (m *Manager) renameClone(g *Guest, src, newName string) {
+	rename := func(s string) string { return renameToken(s, src, newName) }
+
+	if g.Nic != nil && g.Nic.Mac != "" {
+		g.Nic.Mac = randomMAC()
+	}
+	if g.Netdev_Passt != nil && g.Netdev_Passt.Mac != "" {
+		g.Netdev_Passt.Mac = randomMAC()
+	}
+	for _, d := range g.Devices {
+		if d.prop("mac") != "" {
+			d.setProp("mac", randomMAC())
+		}
+	}
+
+	for _, n := range g.netdevs() {
+		*n.id = rename(*n.id)
+	}
+	if g.Netdev_Hubport != nil {
+		g.Netdev_Hubport.Netdev = rename(g.Netdev_Hubport.Netdev)
+	}
+	if g.Nic != nil {
+		for _, key := range []string{"id", "netdev"} {
+			if id := optValue(g.Nic.Option, key); id != "" {
+				g.Nic.Option = setOptValue(g.Nic.Option, key, rename(id))
+			}
+		}
+	}
+	for _, d := range g.Devices {
+		if id := d.prop("netdev"); id != "" {
+			d.setProp("netdev", rename(id))
+		}
+	}
+	if t := g.Netdev_Tap; t != nil && t.Ifname != "" {
+		if n := rename(t.Ifname); n != t.Ifname {
+			t.Ifname = n
+		} else {
+			t.Ifname = ""
+		}
+	}
+
+	if g.Netdev_Vde != nil {
+		g.Netdev_Vde.Sock = renamePath(g.Netdev_Vde.Sock, src, newName)
+	}
+	if q := g.Qmp; q != nil {
+		if network, addr, err := qmpNetwork(q.ProtoPath); err == nil && network == "unix" {
+			sock := renamePath(addr, src, newName)
+			if sock == addr {
+				sock = path.Join(m.socketPath(), newName+".qmp")
+			}
+			q.ProtoPath = strings.Replace(q.ProtoPath, addr, sock, 1)
+		}
+	}
+}
+
+// the volume name of the clone of vol
+func cloneVolumeName(vol, src, newName string, mode CloneMode) string {
+	name := renamePath(vol, src, newName)
+	if name == vol {
+		name = newName + "-" + vol
+	}
+	if mode == CloneLinked && path.Ext(name) != ".qcow2" {
+		name = strings.TrimSuffix(name, path.Ext(name)) + ".qcow2"
+	}
+	return name
+}
+
+// a format node over an overlay must read qcow2, not the raw template image
+func (b *BlockDevicesOptions) setOverlayFormat(file string) {
+	for _, d := range b.Drive {
+		if d.File == file && d.Format != "" {
+			d.Format = string(BlockdevDriverQcow2)
+		}
+	}
+	for _, f := range b.Nodes {
+		if f.File == nil || f.File.Filename != file {
+			continue
+		}
+		for _, n := range b.Nodes {
+			if n.Format != nil && n.Format.File == f.NodeName && n.Driver == BlockdevDriverRaw {
+				n.Driver = BlockdevDriverQcow2
+			}
+		}
+	}
+}
+
+/*
+usage:
+
+	g, err := m.CloneGuest("template", "ci-17", virt.CloneLinked)
+
+copy the definition of src with a fresh UUID and identity (see renameClone)
+and clone its disks: CloneFull copies each image inside its pool, CloneLinked
+creates a qcow2 overlay backed by the template image. Disks must live in a
+pool. A running template is refused with ErrGuestRunning, in both modes: a
+linked clone would be backed by an image that is still written.
+*/
+func (m *Manager) CloneGuest(src, newName string, mode CloneMode) (*Guest, error) {
+	if mode != CloneFull && mode != CloneLinked {
+		return nil, fmt.Errorf("%w: modo de clone %d", ErrDiskOptions, mode)
+	}
+	if src == newName {
+		return nil, os.ErrExist
+	}
+	unlock, err := m.lockGuests(src, newName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tmpl, err := m.Store().Get(src)
+	if err != nil {
+		return nil, err
+	}
+	if m.guestRunning(src) {
+		return nil, ErrGuestRunning
+	}
+	if _, err := m.Store().Get(newName); err == nil {
+		return nil, os.ErrExist
+	}
+
+	data, err := marshalGuest(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	g, err := unmarshalGuest(data)
+	if err != nil {
+		return nil, err
+	}
+	g.Name = newName
+	g.UUID = uuid.NewString()
+	m.renameClone(g, src, newName)
+
+	type created struct {
+		pool Pool
+		vol  string
+	}
+	done := []created{}
+	rollback := func() {
+		for _, c := range done {
+			c.pool.Delete(c.vol)
+		}
+	}
+	clones := map[string]string{} // template image -> clone image
+	for _, f := range g.diskFiles() {
+		if *f == "" {
+			continue
+		}
+		if c, ok := clones[*f]; ok {
+			*f = c
+			continue
+		}
+		p, vol, ok := m.lookupVolume(*f)
+		if !ok {
+			rollback()
+			return nil, fmt.Errorf("%w: %s fora dos pools", ErrDiskOptions, *f)
+		}
+		name := cloneVolumeName(vol, src, newName, mode)
+		var v *Volume
+		if mode == CloneFull {
+			v, err = p.Clone(vol, name)
+		} else {
+			v, err = p.Allocate(name, &ImageOptions{Format: BlockdevDriverQcow2, BackingFile: *f})
+		}
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		done = append(done, created{p, v.Name})
+		clones[*f] = v.Path
+		*f = v.Path
+	}
+	if mode == CloneLinked {
+		for _, c := range clones {
+			g.BlockDevices.setOverlayFormat(c)
+		}
+	}
+
+	if err := g.Validate(); err != nil {
+		rollback()
+		return nil, err
+	}
+	if err := m.Store().Put(g); err != nil {
+		rollback()
+		return nil, err
+	}
+	m.log.Info("guest cloned", "guest", src, "name", newName, "disks", len(done))
+	return g, nil
+}
+
+// copy a guest with a new identity and cloned disks, see Manager.CloneGuest
+func CloneGuest(src, newName string, mode CloneMode) (*Guest, error) {
+	return defaultManager.CloneGuest(src, newName, mode)
+}
@@ -0,0 +1,67 @@
+package virt
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRenameToken(t *testing.T) {
+	tests := []struct{ s, want string }{
+		{"vm1", "ci-17"},
+		{"vm1-net0", "ci-17-net0"},
+		{"net-vm1", "net-ci-17"},
+		{"tap-vm1", "tap-ci-17"},
+		{"vm1.qmp", "ci-17.qmp"},
+		{"vm1-disk0.qcow2", "ci-17-disk0.qcow2"},
+		{"vm10", "vm10"},
+		{"vm10-disk0.qcow2", "vm10-disk0.qcow2"},
+		{"net-vm1x", "net-vm1x"},
+		{"avm1-net0", "avm1-net0"},
+		{"net0", "net0"},
+	}
+	for _, tt := range tests {
+		if got := renameToken(tt.s, "vm1", "ci-17"); got != tt.want {
+			t.Errorf("renameToken(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+
+	// only the base name of a path
+	if got := renamePath("/run/vm1/vm1.qmp", "vm1", "ci-17"); got != "/run/vm1/ci-17.qmp" {
+		t.Errorf("renamePath = %q", got)
+	}
+	if got := renamePath("/run/vm1/guest.qmp", "vm1", "ci-17"); got != "/run/vm1/guest.qmp" {
+		t.Errorf("renamePath = %q", got)
+	}
+}
+
+func TestCloneVolumeName(t *testing.T) {
+	tests := []struct {
+		vol  string
+		mode CloneMode
+		want string
+	}{
+		{"vm1-disk0.qcow2", CloneFull, "ci-17-disk0.qcow2"},
+		{"vm1.raw", CloneFull, "ci-17.raw"},
+		{"vm1.raw", CloneLinked, "ci-17.qcow2"},
+		{"vm10-disk0.qcow2", CloneFull, "ci-17-vm10-disk0.qcow2"},
+		{"base.raw", CloneLinked, "ci-17-base.qcow2"},
+	}
+	for _, tt := range tests {
+		if got := cloneVolumeName(tt.vol, "vm1", "ci-17", tt.mode); got != tt.want {
+			t.Errorf("cloneVolumeName(%q) = %q, want %q", tt.vol, got, tt.want)
+		}
+	}
+}
+
+func TestCloneRunningTemplate(t *testing.T) {
+	m, _ := runningGuest(t)
+	for _, mode := range []CloneMode{CloneFull, CloneLinked} {
+		if _, err := m.CloneGuest("vm1", "ci-17", mode); !errors.Is(err, ErrGuestRunning) {
+			t.Errorf("mode %d: err = %v, want ErrGuestRunning", mode, err)
+		}
+	}
+	if _, err := m.Store().Get("ci-17"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("clone saved: %v", err)
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type entity struct {
+	kind string // enum | struct | union | alternate | command | event
+	name string
+	*expr
+}
+
+type member struct {
+	name     string
+	optional bool
+	typ      any // string | []any (array of one type)
+	doc      string
+}
+
+type schema struct {
+	ents  map[string]*entity
+	order []*entity
+}
+
+var kinds = []string{"enum", "struct", "union", "alternate", "command", "event"}
+
+func newSchema(exprs []*expr) (*schema, error) {
+	s := &schema{ents: map[string]*entity{}}
+	for _, e := range exprs {
+		ent := &entity{expr: e}
+		for _, k := range kinds {
+			if name := e.obj.str(k); name != "" {
+				ent.kind, ent.name = k, name
+				break
+			}
+		}
+		if ent.kind == "" {
+			return nil, fmt.Errorf("%s: unknown expression %v", e.file, e.obj.keys)
+		}
+		key := ent.name
+		if ent.kind == "command" || ent.kind == "event" {
+			key = ent.kind + ":" + ent.name
+		}
+		if _, dup := s.ents[key]; dup {
+			return nil, fmt.Errorf("%s: %s redefined", e.file, ent.name)
+		}
+		s.ents[key] = ent
+		s.order = append(s.order, ent)
+	}
+	return s, nil
+}
+
+var builtins = map[string]string{
+	"str":    "string",
+	"int":    "int64",
+	"int8":   "int8",
+	"int16":  "int16",
+	"int32":  "int32",
+	"int64":  "int64",
+	"uint8":  "uint8",
+	"uint16": "uint16",
+	"uint32": "uint32",
+	"uint64": "uint64",
+	"size":   "uint64",
+	"number": "float64",
+	"bool":   "bool",
+	"any":    "any",
+	"QType":  "string",
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "uuid": "UUID", "uri": "URI", "url": "URL", "json": "JSON",
+	"cpu": "CPU", "vcpu": "VCPU", "tls": "TLS", "nbd": "NBD", "luks": "LUKS",
+	"io": "IO", "iops": "IOPS", "bps": "BPS", "vm": "VM", "tcp": "TCP",
+	"udp": "UDP", "ip": "IP", "ipv4": "IPv4", "ipv6": "IPv6", "mtu": "MTU",
+}
+
+// node-name -> NodeName, query-status -> QueryStatus, system_powerdown -> SystemPowerdown
+func camel(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' || r == '.' || r == ' ' })
+	b := strings.Builder{}
+	for _, p := range parts {
+		if i, ok := initialisms[strings.ToLower(p)]; ok {
+			b.WriteString(i)
+			continue
+		}
+		r := []rune(p)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	out := b.String()
+	if out == "" || !unicode.IsLetter([]rune(out)[0]) {
+		out = "X" + out
+	}
+	return out
+}
+
+func (s *schema) members(data any, doc *docBlock) ([]member, error) {
+	obj, ok := data.(*object)
+	if !ok {
+		return nil, fmt.Errorf("members must be an object, got %T", data)
+	}
+	out := []member{}
+	for _, k := range obj.keys {
+		m := member{name: strings.TrimPrefix(k, "*"), optional: strings.HasPrefix(k, "*")}
+		switch v := obj.vals[k].(type) {
+		case string, []any:
+			m.typ = v
+		case *object:
+			m.typ = v.get("type")
+		}
+		if doc != nil {
+			m.doc = doc.members[m.name]
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// members of a struct including its base chain
+func (s *schema) structMembers(ent *entity) ([]member, error) {
+	out := []member{}
+	switch base := ent.obj.get("base").(type) {
+	case string:
+		b, ok := s.ents[base]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown base %s", ent.name, base)
+		}
+		ms, err := s.structMembers(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ms...)
+	case *object:
+		ms, err := s.members(base, ent.doc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ms...)
+	}
+	if data := ent.obj.get("data"); data != nil && ent.kind != "union" {
+		ms, err := s.members(data, ent.doc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ms...)
+	}
+	return out, nil
+}
+
+type branch struct {
+	value string
+	typ   string
+}
+
+func (s *schema) branches(ent *entity) []branch {
+	obj, _ := ent.obj.get("data").(*object)
+	if obj == nil {
+		return nil
+	}
+	out := []branch{}
+	for _, k := range obj.keys {
+		b := branch{value: k}
+		switch v := obj.vals[k].(type) {
+		case string:
+			b.typ = v
+		case *object:
+			b.typ = v.str("type")
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// mark every type reachable from t
+func (s *schema) visit(t any, seen map[string]bool) error {
+	switch v := t.(type) {
+	case nil:
+		return nil
+	case []any:
+		for _, e := range v {
+			if err := s.visit(e, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *object:
+		ms, err := s.members(v, nil)
+		if err != nil {
+			return err
+		}
+		for _, m := range ms {
+			if err := s.visit(m.typ, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		if _, ok := builtins[v]; ok || v == "null" || seen[v] {
+			return nil
+		}
+		ent, ok := s.ents[v]
+		if !ok {
+			return fmt.Errorf("unknown type %s", v)
+		}
+		seen[v] = true
+		switch ent.kind {
+		case "struct":
+			ms, err := s.structMembers(ent)
+			if err != nil {
+				return err
+			}
+			for _, m := range ms {
+				if err := s.visit(m.typ, seen); err != nil {
+					return err
+				}
+			}
+		case "union":
+			ms, err := s.structMembers(ent)
+			if err != nil {
+				return err
+			}
+			for _, m := range ms {
+				if err := s.visit(m.typ, seen); err != nil {
+					return err
+				}
+			}
+			for _, b := range s.branches(ent) {
+				if err := s.visit(b.typ, seen); err != nil {
+					return err
+				}
+			}
+		case "alternate":
+			for _, b := range s.branches(ent) {
+				if err := s.visit(b.typ, seen); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Go type of a member, omitempty when the zero value means "not set"
+func (s *schema) goType(t any, optional bool) (string, bool) {
+	if a, ok := t.([]any); ok {
+		elem, _ := s.goType(a[0], false)
+		return "[]" + elem, optional
+	}
+	name, _ := t.(string)
+	if g, ok := builtins[name]; ok {
+		switch {
+		case !optional:
+			return g, false
+		case g == "string" || g == "any":
+			return g, true
+		}
+		return "*" + g, true
+	}
+	ent := s.ents[name]
+	if ent != nil && ent.kind == "enum" {
+		return camel(name), optional
+	}
+	return "*" + camel(name), optional
+}
+
+func (s *schema) jsonKind(t string) byte {
+	if t == "null" {
+		return 'n'
+	}
+	switch builtins[t] {
+	case "string":
+		return 's'
+	case "bool":
+		return 'b'
+	case "":
+	default:
+		return 'd'
+	}
+	if ent := s.ents[t]; ent != nil && ent.kind == "enum" {
+		return 's'
+	}
+	return 'o'
+}
+
+type gen struct {
+	*schema
+	buf     bytes.Buffer
+	usesFmt bool
+}
+
+func (g *gen) p(format string, a ...any) { fmt.Fprintf(&g.buf, format+"\n", a...) }
+
+func (g *gen) comment(doc string, indent string) {
+	if doc == "" {
+		return
+	}
+	g.p("%s// %s", indent, doc)
+}
+
+func (s *schema) generate(pkg string, commands []string) ([]byte, error) {
+	selected := []*entity{}
+	if len(commands) == 0 {
+		for _, ent := range s.order {
+			if gen, ok := ent.obj.vals["gen"].(bool); ent.kind == "command" && (!ok || gen) {
+				selected = append(selected, ent)
+			}
+		}
+	}
+	for _, c := range commands {
+		ent, ok := s.ents["command:"+c]
+		if !ok {
+			return nil, fmt.Errorf("unknown command %s", c)
+		}
+		if gen, ok := ent.obj.vals["gen"].(bool); ok && !gen {
+			return nil, fmt.Errorf("command %s has 'gen': false, write it by hand", c)
+		}
+		selected = append(selected, ent)
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].name < selected[j].name })
+
+	seen := map[string]bool{}
+	for _, c := range selected {
+		if err := s.visit(c.obj.get("data"), seen); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+		if err := s.visit(c.obj.get("returns"), seen); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+
+	g := &gen{schema: s}
+
+	for _, ent := range s.order {
+		if !seen[ent.name] {
+			continue
+		}
+		var err error
+		switch ent.kind {
+		case "enum":
+			err = g.enum(ent)
+		case "struct":
+			err = g.structType(ent)
+		case "union":
+			err = g.union(ent)
+		case "alternate":
+			err = g.alternate(ent)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ent.name, err)
+		}
+	}
+	for _, c := range selected {
+		if err := g.command(c); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+
+	head := &gen{}
+	head.p("// Code generated by qapi-gen. DO NOT EDIT.")
+	head.p("")
+	head.p("package %s", pkg)
+	head.p("")
+	head.p("import (")
+	head.p("\t\"context\"")
+	head.p("\t\"encoding/json\"")
+	if g.usesFmt {
+		head.p("\t\"fmt\"")
+	}
+	head.p(")")
+	head.p("")
+	return append(head.buf.Bytes(), g.buf.Bytes()...), nil
+}
+
+func (g *gen) typeDoc(ent *entity) {
+	name := camel(ent.name)
+	if ent.doc != nil && ent.doc.summary != "" {
+		g.p("// %s: %s", name, ent.doc.summary)
+	}
+	g.p("//")
+	g.p("// QAPI %s '%s'", ent.kind, ent.name)
+}
+
+func (g *gen) enum(ent *entity) error {
+	name := camel(ent.name)
+	g.typeDoc(ent)
+	g.p("type %s string", name)
+	g.p("")
+	g.p("const (")
+	data, _ := ent.obj.get("data").([]any)
+	for _, v := range data {
+		val, ok := v.(string)
+		if o, isObj := v.(*object); isObj {
+			val, ok = o.str("name"), true
+		}
+		if !ok {
+			return fmt.Errorf("bad enum value %v", v)
+		}
+		if ent.doc != nil {
+			g.comment(ent.doc.members[val], "\t")
+		}
+		g.p("\t%s%s %s = %q", name, camel(val), name, val)
+	}
+	g.p(")")
+	g.p("")
+	return nil
+}
+
+func (g *gen) fields(ms []member) {
+	for _, m := range ms {
+		typ, omit := g.goType(m.typ, m.optional)
+		tag := m.name
+		if omit {
+			tag += ",omitempty"
+		}
+		g.comment(m.doc, "\t")
+		g.p("\t%s %s `json:%q`", camel(m.name), typ, tag)
+	}
+}
+
+func (g *gen) structType(ent *entity) error {
+	ms, err := g.structMembers(ent)
+	if err != nil {
+		return err
+	}
+	g.typeDoc(ent)
+	g.p("type %s struct {", camel(ent.name))
+	g.fields(ms)
+	g.p("}")
+	g.p("")
+	return nil
+}
+
+// base members are marshalled as usual, the branch selected by the discriminator is merged into them
+func (g *gen) union(ent *entity) error {
+	name := camel(ent.name)
+	ms, err := g.structMembers(ent)
+	if err != nil {
+		return err
+	}
+	disc := ent.obj.str("discriminator")
+	taken := map[string]bool{}
+	for _, m := range ms {
+		taken[camel(m.name)] = true
+	}
+	branches := g.branches(ent)
+	fieldName := func(b branch) string {
+		f := camel(b.value)
+		if taken[f] {
+			f += "Branch"
+		}
+		return f
+	}
+
+	g.typeDoc(ent)
+	g.p("//")
+	g.p("// only the field matching %s is sent", camel(disc))
+	g.p("type %s struct {", name)
+	g.fields(ms)
+	g.p("")
+	for _, b := range branches {
+		g.p("\t%s *%s `json:\"-\"` // %s=%s", fieldName(b), camel(b.typ), disc, b.value)
+	}
+	g.p("}")
+	g.p("")
+
+	g.p("func (u %s) MarshalJSON() ([]byte, error) {", name)
+	g.p("\ttype base %s", name)
+	g.p("\tvar branch any")
+	g.p("\tswitch u.%s {", camel(disc))
+	for _, b := range branches {
+		g.p("\tcase %q:", b.value)
+		g.p("\t\tif u.%s != nil {", fieldName(b))
+		g.p("\t\t\tbranch = u.%s", fieldName(b))
+		g.p("\t\t}")
+	}
+	g.p("\t}")
+	g.p("\treturn qapiMerge(base(u), branch)")
+	g.p("}")
+	g.p("")
+
+	g.p("func (u *%s) UnmarshalJSON(data []byte) error {", name)
+	g.p("\ttype base %s", name)
+	g.p("\tif err := json.Unmarshal(data, (*base)(u)); err != nil {")
+	g.p("\t\treturn err")
+	g.p("\t}")
+	g.p("\tswitch u.%s {", camel(disc))
+	for _, b := range branches {
+		g.p("\tcase %q:", b.value)
+		g.p("\t\tu.%s = &%s{}", fieldName(b), camel(b.typ))
+		g.p("\t\treturn json.Unmarshal(data, u.%s)", fieldName(b))
+	}
+	g.p("\t}")
+	g.p("\treturn nil")
+	g.p("}")
+	g.p("")
+	return nil
+}
+
+// exactly one field is set, chosen from the JSON type when decoding
+func (g *gen) alternate(ent *entity) error {
+	name := camel(ent.name)
+	branches := g.branches(ent)
+	g.usesFmt = true
+	g.typeDoc(ent)
+	g.p("type %s struct {", name)
+	for _, b := range branches {
+		if b.typ == "null" {
+			g.p("\t%s bool // null", camel(b.value))
+			continue
+		}
+		typ, _ := g.goType(b.typ, false)
+		if !strings.HasPrefix(typ, "*") {
+			typ = "*" + typ
+		}
+		g.p("\t%s %s", camel(b.value), typ)
+	}
+	g.p("}")
+	g.p("")
+
+	g.p("func (a %s) MarshalJSON() ([]byte, error) {", name)
+	g.p("\tswitch {")
+	for _, b := range branches {
+		if b.typ == "null" {
+			g.p("\tcase a.%s:", camel(b.value))
+			g.p("\t\treturn []byte(\"null\"), nil")
+			continue
+		}
+		g.p("\tcase a.%s != nil:", camel(b.value))
+		g.p("\t\treturn json.Marshal(a.%s)", camel(b.value))
+	}
+	g.p("\t}")
+	g.p("\treturn nil, fmt.Errorf(\"%s: no value set\")", name)
+	g.p("}")
+	g.p("")
+
+	g.p("func (a *%s) UnmarshalJSON(data []byte) error {", name)
+	g.p("\tswitch qapiKind(data) {")
+	for _, b := range branches {
+		g.p("\tcase '%c':", g.jsonKind(b.typ))
+		if b.typ == "null" {
+			g.p("\t\ta.%s = true", camel(b.value))
+			g.p("\t\treturn nil")
+			continue
+		}
+		typ, _ := g.goType(b.typ, false)
+		g.p("\t\ta.%s = new(%s)", camel(b.value), strings.TrimPrefix(typ, "*"))
+		g.p("\t\treturn json.Unmarshal(data, a.%s)", camel(b.value))
+	}
+	g.p("\t}")
+	g.p("\treturn fmt.Errorf(\"%s: unexpected %%s\", data)", name)
+	g.p("}")
+	g.p("")
+	return nil
+}
+
+func (g *gen) command(ent *entity) error {
+	name := camel(ent.name)
+	params := "ctx context.Context"
+	args := "nil"
+	switch data := ent.obj.get("data").(type) {
+	case string:
+		params += ", args *" + camel(data)
+		args = "qapiArgs(args)"
+	case *object:
+		argType := name + "Arguments"
+		ms, err := g.members(data, ent.doc)
+		if err != nil {
+			return err
+		}
+		g.p("// arguments of %s", name)
+		g.p("type %s struct {", argType)
+		g.fields(ms)
+		g.p("}")
+		g.p("")
+		params += ", args *" + argType
+		args = "qapiArgs(args)"
+	}
+
+	if ent.doc != nil && ent.doc.summary != "" {
+		g.p("// %s: %s", name, ent.doc.summary)
+		g.p("//")
+	}
+	g.p("// QAPI command '%s'", ent.name)
+
+	ret := ent.obj.get("returns")
+	if ret == nil {
+		g.p("func (c *Client) %s(%s) error {", name, params)
+		g.p("\t_, err := c.Execute(ctx, %q, %s)", ent.name, args)
+		g.p("\treturn err")
+		g.p("}")
+		g.p("")
+		return nil
+	}
+	if ret == "any" {
+		g.p("func (c *Client) %s(%s) (json.RawMessage, error) {", name, params)
+		g.p("\treturn c.Execute(ctx, %q, %s)", ent.name, args)
+		g.p("}")
+		g.p("")
+		return nil
+	}
+	typ, _ := g.goType(ret, false)
+	g.p("func (c *Client) %s(%s) (%s, error) {", name, params, typ)
+	g.p("\tvar v %s", typ)
+	g.p("\tret, err := c.Execute(ctx, %q, %s)", ent.name, args)
+	g.p("\tif err != nil {")
+	g.p("\t\treturn v, err")
+	g.p("\t}")
+	g.p("\terr = json.Unmarshal(ret, &v)")
+	g.p("\treturn v, err")
+	g.p("}")
+	g.p("")
+	return nil
+}
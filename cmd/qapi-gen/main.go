@@ -0,0 +1,58 @@
+/*
+qapi-gen reads QEMU's QAPI schema (qapi/*.json) and writes Go types and
+*virt.Client methods for the selected commands and every type they reach.
+
+usage:
+
+	go run ./cmd/qapi-gen -schema testdata/qapi/qapi-schema.json -o qapi_gen.go \
+		-commands query-status,stop,cont
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+)
+
+func main() {
+	schema := flag.String("schema", "testdata/qapi/qapi-schema.json", "QAPI schema entry point")
+	out := flag.String("o", "qapi_gen.go", "output file")
+	pkg := flag.String("pkg", "virt", "package name")
+	commands := flag.String("commands", "", "comma separated commands to generate, all when empty")
+	flag.Parse()
+
+	if err := run(*schema, *out, *pkg, *commands); err != nil {
+		fmt.Fprintln(os.Stderr, "qapi-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schema, out, pkg, commands string) error {
+	exprs, err := parseFile(schema, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	s, err := newSchema(exprs)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, c := range strings.Split(commands, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			names = append(names, c)
+		}
+	}
+	src, err := s.generate(pkg, names)
+	if err != nil {
+		return err
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, src)
+	}
+	return os.WriteFile(out, formatted, 0o644)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// a QAPI dictionary keeps the declaration order, the generated code depends on it
+type object struct {
+	keys []string
+	vals map[string]any
+}
+
+func (o *object) get(k string) any { return o.vals[k] }
+
+func (o *object) str(k string) string {
+	s, _ := o.vals[k].(string)
+	return s
+}
+
+func (o *object) boolean(k string) bool {
+	b, _ := o.vals[k].(bool)
+	return b
+}
+
+// expression read from a schema file with the doc block that precedes it
+type expr struct {
+	obj  *object
+	doc  *docBlock
+	file string
+}
+
+type docBlock struct {
+	summary string
+	members map[string]string
+}
+
+/*
+QAPI schema files are not JSON: strings use single quotes and '#' starts
+a comment. Comments starting with '##' are doc blocks:
+
+	##
+	# @StatusInfo:
+	#
+	# Information about VM run state
+	#
+	# @running: true if all VCPUs are runnable, false if not runnable
+	##
+*/
+type parser struct {
+	src  string
+	pos  int
+	file string
+	doc  *docBlock
+}
+
+func parseFile(file string, seen map[string]bool) ([]*expr, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return nil, nil
+	}
+	seen[abs] = true
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: string(data), file: file}
+	exprs := []*expr{}
+	for {
+		p.skip()
+		if p.pos >= len(p.src) {
+			return exprs, nil
+		}
+		doc := p.doc
+		p.doc = nil
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := v.(*object)
+		if !ok {
+			return nil, p.errorf("top level expression must be an object")
+		}
+		if inc := obj.str("include"); inc != "" {
+			sub, err := parseFile(filepath.Join(filepath.Dir(file), inc), seen)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, sub...)
+			continue
+		}
+		if _, ok := obj.vals["pragma"]; ok {
+			continue
+		}
+		exprs = append(exprs, &expr{obj: obj, doc: doc, file: file})
+	}
+}
+
+func (p *parser) errorf(format string, a ...any) error {
+	line := strings.Count(p.src[:min(p.pos, len(p.src))], "\n") + 1
+	return fmt.Errorf("%s:%d: %s", p.file, line, fmt.Sprintf(format, a...))
+}
+
+// skip blanks and comments, keeping the last doc block
+func (p *parser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '#':
+			if strings.HasPrefix(p.src[p.pos:], "##") && p.atLineStart() {
+				p.readDoc()
+				continue
+			}
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) atLineStart() bool {
+	i := p.pos - 1
+	for i >= 0 && (p.src[i] == ' ' || p.src[i] == '\t') {
+		i--
+	}
+	return i < 0 || p.src[i] == '\n'
+}
+
+func (p *parser) readDoc() {
+	lines := []string{}
+	// opening '##'
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+	for p.pos < len(p.src) {
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.src) - p.pos
+		}
+		line := strings.TrimSpace(p.src[p.pos : p.pos+end])
+		p.pos += end
+		if line == "##" || !strings.HasPrefix(line, "#") {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+	}
+
+	doc := &docBlock{members: map[string]string{}}
+	section, member := 0, "" // 0: name, 1: summary, 2: members
+	for _, l := range lines {
+		switch {
+		case section == 0 && strings.HasPrefix(l, "@"):
+			section = 1
+		case strings.HasPrefix(l, "@"):
+			section = 2
+			name, text, _ := strings.Cut(l[1:], ":")
+			member = name
+			doc.members[member] = strings.TrimSpace(text)
+		case l == "":
+			if section == 1 && doc.summary != "" {
+				section = 3 // extra paragraphs are ignored
+			}
+			member = ""
+		case strings.HasSuffix(l, ":") && !strings.Contains(l, " "):
+			section, member = 3, "" // Since:, Returns:, Example: ...
+		case section == 1:
+			doc.summary = strings.TrimSpace(doc.summary + " " + l)
+		case section == 2 && member != "":
+			doc.members[member] = strings.TrimSpace(doc.members[member] + " " + l)
+		}
+	}
+	p.doc = doc
+}
+
+func (p *parser) value() (any, error) {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	switch c := p.src[p.pos]; c {
+	case '{':
+		return p.object()
+	case '[':
+		return p.array()
+	case '\'':
+		return p.string()
+	}
+	for _, kw := range []string{"true", "false"} {
+		if strings.HasPrefix(p.src[p.pos:], kw) {
+			p.pos += len(kw)
+			return kw == "true", nil
+		}
+	}
+	return nil, p.errorf("unexpected %q", p.src[p.pos])
+}
+
+func (p *parser) expect(c byte) error {
+	p.skip()
+	if p.pos >= len(p.src) || p.src[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) string() (string, error) {
+	if err := p.expect('\''); err != nil {
+		return "", err
+	}
+	end := strings.IndexByte(p.src[p.pos:], '\'')
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *parser) object() (*object, error) {
+	p.pos++ // {
+	o := &object{vals: map[string]any{}}
+	if p.peek() == '}' {
+		p.pos++
+		return o, nil
+	}
+	for {
+		k, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := o.vals[k]; dup {
+			return nil, p.errorf("duplicate key %q", k)
+		}
+		o.keys = append(o.keys, k)
+		o.vals[k] = v
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return o, nil
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *parser) array() ([]any, error) {
+	p.pos++ // [
+	a := []any{}
+	if p.peek() == ']' {
+		p.pos++
+		return a, nil
+	}
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return a, nil
+		default:
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
@@ -1,6 +1,6 @@
 package virt
 
-import "strings"
+import "fmt"
 
 /*
 original command:
@@ -12,16 +12,29 @@ original command:
 	                use '-device help' to print all possible drivers
 	                use '-device driver,help' to print all possible properties
 		ex:
+
+Props holds typed properties, needed by the JSON form where the values of
+Properties are strings, but for a few well known keys (bootindex, share-rw, ...).
 */
 type DeviceOptions struct {
-	Driver     string `yaml:",omitempty"` // driver
-	Properties string `yaml:",omitempty"` // [,prop[=value][,...]]
+	Driver     string         `yaml:",omitempty" qemu:"driver,implied"` // driver
+	Properties string         `yaml:",omitempty" qemu:",raw"`           // [,prop[=value][,...]]
+	Props      map[string]any `yaml:",omitempty" qemu:",extra"`         // [,prop[=value][,...]]
+}
+
+func (d *DeviceOptions) ToArgs() ([]string, error) {
+	return qemuArg("-device", "", d)
+}
+
+// -device {"driver":...}
+func (d *DeviceOptions) ToJSONArgs() ([]string, error) {
+	return qemuJSONArg("-device", d)
 }
 
-func (d *DeviceOptions) ToArgs() []string {
-	args := []string{d.Driver}
-	if d.Properties != "" {
-		args = append(args, d.Properties)
+// value of a property, from Props or Properties
+func (d *DeviceOptions) prop(key string) string {
+	if v, ok := d.Props[key]; ok {
+		return fmt.Sprint(v)
 	}
-	return []string{"-device", strings.Join(args, ",")}
+	return optValue(d.Properties, key)
 }
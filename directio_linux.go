@@ -0,0 +1,21 @@
+//go:build linux
+
+package virt
+
+import (
+	"errors"
+	"syscall"
+)
+
+// whether the filesystem of file accepts O_DIRECT (tmpfs does not)
+func directIO(file string) (bool, error) {
+	fd, err := syscall.Open(file, syscall.O_RDONLY|syscall.O_DIRECT|syscall.O_CLOEXEC, 0)
+	if errors.Is(err, syscall.EINVAL) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	syscall.Close(fd)
+	return true, nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package virt
+
+import "errors"
+
+func directIO(file string) (bool, error) { return false, errors.ErrUnsupported }
@@ -0,0 +1,397 @@
+package virt
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	QemuImgPath = "qemu-img" // looked up in PATH
+
+	ErrDiskOptions = errors.New("opções de disco inválidas")
+)
+
+// returned when qemu-img fails, Stderr has its message
+type QemuImgError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *QemuImgError) Error() string {
+	msg := fmt.Sprintf("qemu-img %s: %v", e.Args[0], e.Err)
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		msg += ": " + s
+	}
+	return msg
+}
+
+func (e *QemuImgError) Unwrap() error { return e.Err }
+
+// run qemu-img, the stdout is returned even on error (check exits 2 and 3 with a report)
+func qemuImg(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(QemuImgPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		code := -1
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+		return stdout.Bytes(), &QemuImgError{Args: args, ExitCode: code, Stderr: stderr.String(), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+type Prealloc string
+
+const (
+	PreallocOff      Prealloc = "off"
+	PreallocMetadata Prealloc = "metadata" // qcow2
+	PreallocFalloc   Prealloc = "falloc"
+	PreallocFull     Prealloc = "full"
+)
+
+/*
+usage:
+
+	err := virt.CreateImage("disks/web01.qcow2", &virt.ImageOptions{
+		Format: virt.BlockdevDriverQcow2,
+		Size:   20 << 30,
+	})
+
+options of qemu-img create, the backing file makes an overlay and then Size
+may be zero (same size as the backing image)
+*/
+type ImageOptions struct {
+	Format        BlockdevDriver // qcow2 (default) or raw
+	Size          int64          // bytes
+	Preallocation Prealloc       // off|metadata|falloc|full
+	ClusterSize   int64          // qcow2, bytes
+	BackingFile   string         // qcow2
+	BackingFormat BlockdevDriver // default: from qemu-img info
+}
+
+func (o *ImageOptions) format() BlockdevDriver {
+	if o.Format == "" {
+		return BlockdevDriverQcow2
+	}
+	return o.Format
+}
+
+func (o *ImageOptions) check() error {
+	switch o.format() {
+	case BlockdevDriverQcow2:
+	case BlockdevDriverRaw:
+		if o.ClusterSize != 0 || o.BackingFile != "" || o.Preallocation == PreallocMetadata {
+			return fmt.Errorf("%w: raw não aceita cluster size, backing file nem preallocation=metadata", ErrDiskOptions)
+		}
+	default:
+		return fmt.Errorf("%w: formato %s", ErrDiskOptions, o.Format)
+	}
+	switch o.Preallocation {
+	case "", PreallocOff, PreallocMetadata, PreallocFalloc, PreallocFull:
+	default:
+		return fmt.Errorf("%w: preallocation %s", ErrDiskOptions, o.Preallocation)
+	}
+	if o.Size < 0 || (o.Size == 0 && o.BackingFile == "") {
+		return fmt.Errorf("%w: tamanho %d", ErrDiskOptions, o.Size)
+	}
+	if c := o.ClusterSize; c != 0 && (c < 512 || c > 2<<20 || c&(c-1) != 0) {
+		return fmt.Errorf("%w: cluster size %d, potência de 2 entre 512 e 2M", ErrDiskOptions, c)
+	}
+	return nil
+}
+
+// qemu-img create, overwrites file
+func CreateImage(file string, opts *ImageOptions) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+	args := []string{"create", "-f", string(opts.format())}
+	o := []string{}
+	if opts.Preallocation != "" {
+		o = append(o, "preallocation="+string(opts.Preallocation))
+	}
+	if opts.ClusterSize != 0 {
+		o = append(o, "cluster_size="+strconv.FormatInt(opts.ClusterSize, 10))
+	}
+	if len(o) > 0 {
+		args = append(args, "-o", strings.Join(o, ","))
+	}
+	if opts.BackingFile != "" {
+		// qemu-img resolves a relative backing file from the directory of file
+		backing, err := filepath.Abs(opts.BackingFile)
+		if err != nil {
+			return err
+		}
+		bf := opts.BackingFormat
+		if bf == "" {
+			info, err := InspectImage(opts.BackingFile)
+			if err != nil {
+				return err
+			}
+			bf = BlockdevDriver(info.Format)
+		}
+		args = append(args, "-b", backing, "-F", string(bf))
+	}
+	args = append(args, file)
+	if opts.Size > 0 {
+		args = append(args, strconv.FormatInt(opts.Size, 10))
+	}
+	_, err := qemuImg(args...)
+	return err
+}
+
+// a qcow2 overlay of backing, the writes go to file and backing is left untouched
+func CreateOverlay(file, backing string) error {
+	return CreateImage(file, &ImageOptions{Format: BlockdevDriverQcow2, BackingFile: backing})
+}
+
+// internal snapshot of an image, see ImageInfo
+type ImageSnapshot struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	VMStateSize int64  `json:"vm-state-size"`
+	DateSec     int64  `json:"date-sec"`
+	DateNsec    int64  `json:"date-nsec"`
+	VMClockSec  int64  `json:"vm-clock-sec"`
+	VMClockNsec int64  `json:"vm-clock-nsec"`
+}
+
+// qemu-img info --output=json
+type ImageInfo struct {
+	Filename              string          `json:"filename"`
+	Format                string          `json:"format"`
+	VirtualSize           int64           `json:"virtual-size"`
+	ActualSize            int64           `json:"actual-size"`
+	ClusterSize           int64           `json:"cluster-size"`
+	Encrypted             bool            `json:"encrypted"`
+	DirtyFlag             bool            `json:"dirty-flag"`
+	BackingFilename       string          `json:"backing-filename"`
+	FullBackingFilename   string          `json:"full-backing-filename"`
+	BackingFilenameFormat string          `json:"backing-filename-format"`
+	Snapshots             []ImageSnapshot `json:"snapshots"`
+	FormatSpecific        map[string]any  `json:"format-specific"` // {"type": "qcow2", "data": {...}}
+}
+
+/*
+usage:
+
+	info, err := virt.InspectImage("disks/web01.qcow2")
+	fmt.Println(info.Format, info.VirtualSize, info.BackingFilename)
+
+qemu-img info, with force-share so it also works while a guest uses the image
+*/
+func InspectImage(file string) (*ImageInfo, error) {
+	out, err := qemuImg("info", "--output=json", "-U", file)
+	if err != nil {
+		return nil, err
+	}
+	info := &ImageInfo{}
+	if err := json.Unmarshal(out, info); err != nil {
+		return nil, fmt.Errorf("qemu-img info: %w", err)
+	}
+	return info, nil
+}
+
+// qemu-img resize to size bytes, shrink must be set to make the image smaller
+func ResizeImage(file string, size int64, shrink bool) error {
+	if size <= 0 {
+		return fmt.Errorf("%w: tamanho %d", ErrDiskOptions, size)
+	}
+	args := []string{"resize"}
+	if shrink {
+		args = append(args, "--shrink")
+	}
+	_, err := qemuImg(append(args, file, strconv.FormatInt(size, 10))...)
+	return err
+}
+
+// options of qemu-img convert
+type ConvertOptions struct {
+	Format        BlockdevDriver // output format, qcow2 (default) or raw
+	Compress      bool           // qcow2
+	Preallocation Prealloc
+}
+
+// qemu-img convert, dst is a standalone copy of src and its backing chain
+func ConvertImage(src, dst string, opts *ConvertOptions) error {
+	if opts == nil {
+		opts = &ConvertOptions{}
+	}
+	f := opts.Format
+	if f == "" {
+		f = BlockdevDriverQcow2
+	}
+	if opts.Compress && f != BlockdevDriverQcow2 {
+		return fmt.Errorf("%w: compressão só em qcow2", ErrDiskOptions)
+	}
+	args := []string{"convert", "-O", string(f)}
+	if opts.Compress {
+		args = append(args, "-c")
+	}
+	if opts.Preallocation != "" {
+		args = append(args, "-o", "preallocation="+string(opts.Preallocation))
+	}
+	_, err := qemuImg(append(args, src, dst)...)
+	return err
+}
+
+type ImageRepair string
+
+const (
+	RepairNone  ImageRepair = ""
+	RepairLeaks ImageRepair = "leaks" // leaked clusters only
+	RepairAll   ImageRepair = "all"   // leaks and corruptions
+)
+
+// qemu-img check --output=json
+type ImageCheck struct {
+	Filename           string `json:"filename"`
+	Format             string `json:"format"`
+	CheckErrors        int64  `json:"check-errors"`
+	Corruptions        int64  `json:"corruptions"`
+	Leaks              int64  `json:"leaks"`
+	CorruptionsFixed   int64  `json:"corruptions-fixed"`
+	LeaksFixed         int64  `json:"leaks-fixed"`
+	ImageEndOffset     int64  `json:"image-end-offset"`
+	TotalClusters      int64  `json:"total-clusters"`
+	AllocatedClusters  int64  `json:"allocated-clusters"`
+	FragmentedClusters int64  `json:"fragmented-clusters"`
+	CompressedClusters int64  `json:"compressed-clusters"`
+}
+
+// true when nothing is left to fix
+func (c *ImageCheck) Clean() bool {
+	return c.CheckErrors == 0 && c.Corruptions == 0 && c.Leaks == 0
+}
+
+/*
+usage:
+
+	c, err := virt.CheckImage("disks/web01.qcow2", virt.RepairLeaks)
+	if err == nil && !c.Clean() { ... }
+
+qemu-img check, optionally repairing. Corruptions and leaks are reported in
+ImageCheck, not as an error (qemu-img exits with 2 and 3)
+*/
+func CheckImage(file string, repair ImageRepair) (*ImageCheck, error) {
+	args := []string{"check", "--output=json"}
+	switch repair {
+	case RepairNone:
+	case RepairLeaks, RepairAll:
+		args = append(args, "-r", string(repair))
+	default:
+		return nil, fmt.Errorf("%w: repair %s", ErrDiskOptions, repair)
+	}
+	out, err := qemuImg(append(args, file)...)
+	var ie *QemuImgError
+	if err != nil && !(errors.As(err, &ie) && (ie.ExitCode == 2 || ie.ExitCode == 3)) {
+		return nil, err
+	}
+	c := &ImageCheck{}
+	if err := json.Unmarshal(out, c); err != nil {
+		return nil, fmt.Errorf("qemu-img check: %w", err)
+	}
+	return c, nil
+}
+
+// qemu-img commit, writes the overlay into its backing file and empties it
+func CommitImage(file string) error {
+	_, err := qemuImg("commit", file)
+	return err
+}
+
+/*
+a disk created by CreateDisk. Pool defaults to DefaultPool, Device
+(virtio-blk-pci, scsi-hd ...) adds the frontend to the guest; empty registers
+only the -blockdev nodes
+*/
+type DiskOptions struct {
+	ImageOptions
+	Pool   string
+	Device string
+}
+
+// the file node "<name>-file" and the format node "<name>" of an image
+func diskNodes(name string, format BlockdevDriver, file string) (fileNode, formatNode *BlockNode) {
+	fileNode = &BlockNode{Driver: BlockdevDriverFile, NodeName: name + "-file", File: &BlockFileOptions{Filename: file}}
+	formatNode = &BlockNode{Driver: format, NodeName: name, Format: &BlockFormatOptions{File: fileNode.NodeName}}
+	return fileNode, formatNode
+}
+
+/*
+usage:
+
+	node, err := m.CreateDisk("web01", "disk0", &virt.DiskOptions{
+		ImageOptions: virt.ImageOptions{Size: 20 << 30},
+		Device:       "virtio-blk-pci",
+	})
+
+create the volume <guest>-<name>.<format> in the pool and register it in the
+guest: a file node "<name>-file" and a format node "<name>" in
+BlockDevices.Nodes. Returns the format node.
+*/
+func (m *Manager) CreateDisk(guestName, name string, opts *DiskOptions) (*BlockNode, error) {
+	if opts == nil {
+		opts = &DiskOptions{}
+	}
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	p, err := m.FindPool(cmp.Or(opts.Pool, DefaultPool))
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lockGuest(guestName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(guestName)
+	if err != nil {
+		return nil, err
+	}
+	if g.BlockDevices == nil {
+		g.BlockDevices = &BlockDevicesOptions{}
+	}
+	b := g.BlockDevices
+	for _, n := range b.Nodes {
+		if n.NodeName == name || n.NodeName == name+"-file" {
+			return nil, fmt.Errorf("%w: disco %s", os.ErrExist, name)
+		}
+	}
+
+	vol, err := p.Allocate(fmt.Sprintf("%s-%s.%s", guestName, name, opts.format()), &opts.ImageOptions)
+	if err != nil {
+		return nil, err
+	}
+	fileNode, node := diskNodes(name, opts.format(), vol.Path)
+	b.Nodes = append(b.Nodes, fileNode, node)
+	if opts.Device != "" {
+		g.Devices = append(g.Devices, &DeviceOptions{Driver: opts.Device, Props: map[string]any{"drive": name, "id": name}})
+	}
+	if err := m.Store().Put(g); err != nil {
+		p.Delete(vol.Name)
+		return nil, err
+	}
+	m.log.Info("disk created", "guest", guestName, "disk", name, "pool", p.Name(), "volume", vol.Name)
+	return node, nil
+}
+
+// create the volume in the pool and register it in the guest, see Manager.CreateDisk
+func CreateDisk(guestName, name string, opts *DiskOptions) (*BlockNode, error) {
+	return defaultManager.CreateDisk(guestName, name, opts)
+}
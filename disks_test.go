@@ -0,0 +1,233 @@
+package virt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+each call appends its argv, one argument per line, ended by a line "--";
+convert creates its output and a call with an argument containing
+$QEMU_IMG_FAIL exits 1
+*/
+const qemuImgStub = `#!/bin/sh
+for a; do printf '%s\n' "$a"; done >> "$QEMU_IMG_LOG"
+echo -- >> "$QEMU_IMG_LOG"
+[ -n "$QEMU_IMG_FAIL" ] && for a; do case "$a" in *"$QEMU_IMG_FAIL"*) exit 1;; esac; done
+[ "$1" = convert ] && for a; do out=$a; done && touch "$out"
+printf '%s' "$QEMU_IMG_STDOUT"
+printf '%s' "$QEMU_IMG_STDERR" >&2
+exit ${QEMU_IMG_EXIT:-0}
+`
+
+// a scripted qemu-img first on PATH, returns the calls made so far
+func fakeQemuImg(t *testing.T, stdout string, exit string) func() [][]string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "qemu-img"), []byte(qemuImgStub), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("QEMU_IMG_LOG", log)
+	t.Setenv("QEMU_IMG_STDOUT", stdout)
+	t.Setenv("QEMU_IMG_STDERR", "")
+	t.Setenv("QEMU_IMG_EXIT", exit)
+	t.Setenv("QEMU_IMG_FAIL", "")
+	return func() [][]string {
+		b, err := os.ReadFile(log)
+		if err != nil {
+			return nil
+		}
+		calls := [][]string{}
+		call := []string{}
+		for _, l := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+			if l == "--" {
+				calls = append(calls, call)
+				call = []string{}
+				continue
+			}
+			call = append(call, l)
+		}
+		return calls
+	}
+}
+
+const infoJSON = `{"filename":"base.qcow2","format":"qcow2","virtual-size":1073741824,"actual-size":200704,
+"cluster-size":65536,"dirty-flag":false,"backing-filename":"root.raw","full-backing-filename":"/img/root.raw",
+"backing-filename-format":"raw","snapshots":[{"id":"1","name":"s1","vm-state-size":0,"date-sec":10}],
+"format-specific":{"type":"qcow2","data":{"compat":"1.1"}}}`
+
+func TestQemuImgArgs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	base, err := filepath.Abs("base.qcow2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		run  func() error
+		want [][]string
+	}{
+		{
+			name: "create qcow2",
+			run: func() error {
+				return CreateImage("d.qcow2", &ImageOptions{Size: 1 << 30, Preallocation: PreallocMetadata, ClusterSize: 1 << 16})
+			},
+			want: [][]string{{"create", "-f", "qcow2", "-o", "preallocation=metadata,cluster_size=65536", "d.qcow2", "1073741824"}},
+		},
+		{
+			name: "create raw",
+			run: func() error {
+				return CreateImage("d.raw", &ImageOptions{Format: BlockdevDriverRaw, Size: 4096, Preallocation: PreallocFalloc})
+			},
+			want: [][]string{{"create", "-f", "raw", "-o", "preallocation=falloc", "d.raw", "4096"}},
+		},
+		{
+			name: "overlay",
+			run:  func() error { return CreateOverlay("top.qcow2", "base.qcow2") },
+			want: [][]string{
+				{"info", "--output=json", "-U", "base.qcow2"},
+				{"create", "-f", "qcow2", "-b", base, "-F", "qcow2", "top.qcow2"},
+			},
+		},
+		{
+			name: "overlay with backing format",
+			run: func() error {
+				return CreateImage("top.qcow2", &ImageOptions{BackingFile: "base.qcow2", BackingFormat: BlockdevDriverRaw, Size: 8192})
+			},
+			want: [][]string{{"create", "-f", "qcow2", "-b", base, "-F", "raw", "top.qcow2", "8192"}},
+		},
+		{
+			name: "resize",
+			run:  func() error { return ResizeImage("d.qcow2", 2<<30, false) },
+			want: [][]string{{"resize", "d.qcow2", "2147483648"}},
+		},
+		{
+			name: "shrink",
+			run:  func() error { return ResizeImage("d.qcow2", 1<<20, true) },
+			want: [][]string{{"resize", "--shrink", "d.qcow2", "1048576"}},
+		},
+		{
+			name: "convert",
+			run:  func() error { return ConvertImage("a.qcow2", "b.raw", &ConvertOptions{Format: BlockdevDriverRaw}) },
+			want: [][]string{{"convert", "-O", "raw", "a.qcow2", "b.raw"}},
+		},
+		{
+			name: "convert compressed",
+			run: func() error {
+				return ConvertImage("a.qcow2", "b.qcow2", &ConvertOptions{Compress: true, Preallocation: PreallocMetadata})
+			},
+			want: [][]string{{"convert", "-O", "qcow2", "-c", "-o", "preallocation=metadata", "a.qcow2", "b.qcow2"}},
+		},
+		{
+			name: "check",
+			run: func() error {
+				_, err := CheckImage("d.qcow2", RepairLeaks)
+				return err
+			},
+			want: [][]string{{"check", "--output=json", "-r", "leaks", "d.qcow2"}},
+		},
+		{
+			name: "commit",
+			run:  func() error { return CommitImage("top.qcow2") },
+			want: [][]string{{"commit", "top.qcow2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeQemuImg(t, infoJSON, "0")
+			if err := tt.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := calls(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("argv\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQemuImgInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"raw with cluster size", func() error {
+			return CreateImage("d.raw", &ImageOptions{Format: BlockdevDriverRaw, Size: 4096, ClusterSize: 1 << 16})
+		}},
+		{"cluster size not a power of 2", func() error {
+			return CreateImage("d.qcow2", &ImageOptions{Size: 4096, ClusterSize: 3000})
+		}},
+		{"no size", func() error { return CreateImage("d.qcow2", &ImageOptions{}) }},
+		{"compressed raw", func() error {
+			return ConvertImage("a", "b", &ConvertOptions{Format: BlockdevDriverRaw, Compress: true})
+		}},
+		{"resize to 0", func() error { return ResizeImage("d.qcow2", 0, false) }},
+		{"repair", func() error {
+			_, err := CheckImage("d.qcow2", "some")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeQemuImg(t, "", "0")
+			if err := tt.run(); !errors.Is(err, ErrDiskOptions) {
+				t.Errorf("err = %v, want ErrDiskOptions", err)
+			}
+			if got := calls(); len(got) != 0 {
+				t.Errorf("qemu-img called: %q", got)
+			}
+		})
+	}
+}
+
+func TestInspectImage(t *testing.T) {
+	fakeQemuImg(t, infoJSON, "0")
+	info, err := InspectImage("base.qcow2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ImageInfo{
+		Filename:              "base.qcow2",
+		Format:                "qcow2",
+		VirtualSize:           1 << 30,
+		ActualSize:            200704,
+		ClusterSize:           1 << 16,
+		BackingFilename:       "root.raw",
+		FullBackingFilename:   "/img/root.raw",
+		BackingFilenameFormat: "raw",
+		Snapshots:             []ImageSnapshot{{ID: "1", Name: "s1", DateSec: 10}},
+		FormatSpecific:        map[string]any{"type": "qcow2", "data": map[string]any{"compat": "1.1"}},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info\n got %+v\nwant %+v", info, want)
+	}
+}
+
+func TestCheckImageReport(t *testing.T) {
+	// exit 3: leaked clusters, a report and not an error
+	fakeQemuImg(t, `{"filename":"d.qcow2","format":"qcow2","check-errors":0,"leaks":4,"total-clusters":16}`, "3")
+	c, err := CheckImage("d.qcow2", RepairNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Leaks != 4 || c.TotalClusters != 16 || c.Clean() {
+		t.Errorf("check = %+v", c)
+	}
+}
+
+func TestQemuImgError(t *testing.T) {
+	fakeQemuImg(t, "", "1")
+	t.Setenv("QEMU_IMG_STDERR", "Could not open 'd.qcow2'")
+	err := CommitImage("d.qcow2")
+	var ie *QemuImgError
+	if !errors.As(err, &ie) {
+		t.Fatalf("err = %v, want *QemuImgError", err)
+	}
+	if ie.ExitCode != 1 || ie.Stderr != "Could not open 'd.qcow2'" || ie.Args[0] != "commit" {
+		t.Errorf("error = %+v", ie)
+	}
+}
@@ -2,15 +2,20 @@ package virt
 
 import (
 	"fmt"
-	"strings"
+	"slices"
+
+	yaml "gopkg.in/yaml.v3"
 )
 
 // -cdrom file     use 'file' as CD-ROM image
 type CdromOptions struct {
-	File string `yaml:",omitempty"`
+	File string `yaml:",omitempty" qemu:"file"`
 }
 
-func (c *CdromOptions) ToArgs() []string { return []string{"-cdrom", c.File} }
+// the -drive that -cdrom stands for, -cdrom takes the file as is and a comma can't be escaped
+func (c *CdromOptions) ToArgs() ([]string, error) { return qemuArg("-drive", cdromDrive, c) }
+
+const cdromDrive = "index=2,media=cdrom"
 
 /*
 -drive [file=file][,if=type][,bus=n][,unit=m][,media=d][,index=i]
@@ -29,37 +34,164 @@ func (c *CdromOptions) ToArgs() []string { return []string{"-cdrom", c.File} }
 	[[,group=g]]
 */
 type DriveOptions struct {
-	File   string `yaml:",omitempty"` // [file=file]
-	If     string `yaml:",omitempty"` // [,if=type]
-	Bus    string `yaml:",omitempty"` // [,bus=n]
-	Unit   string `yaml:",omitempty"` // [,unit=m]
-	Media  string `yaml:",omitempty"` // [,media=d]
-	Index  string `yaml:",omitempty"` // [,index=i]
-	Format string `yaml:",omitempty"` // [,format=f]
+	File         string                      `yaml:",omitempty" qemu:"file"`          // [file=file]
+	If           string                      `yaml:",omitempty" qemu:"if"`            // [,if=type]
+	Bus          string                      `yaml:",omitempty" qemu:"bus"`           // [,bus=n]
+	Unit         string                      `yaml:",omitempty" qemu:"unit"`          // [,unit=m]
+	Media        string                      `yaml:",omitempty" qemu:"media"`         // [,media=d]
+	Index        string                      `yaml:",omitempty" qemu:"index"`         // [,index=i]
+	Cache        CacheMode                   `yaml:",omitempty" qemu:"cache"`         // [,cache=writethrough|writeback|none|directsync|unsafe]
+	Format       string                      `yaml:",omitempty" qemu:"format"`        // [,format=f]
+	Snapshot     bool                        `yaml:",omitempty" qemu:"snapshot"`      // [,snapshot=on|off] writes go to a temporary file
+	Rerror       ErrorAction                 `yaml:",omitempty" qemu:"rerror"`        // [,rerror=ignore|stop|report]
+	Werror       ErrorAction                 `yaml:",omitempty" qemu:"werror"`        // [,werror=ignore|stop|report|enospc]
+	ID           string                      `yaml:",omitempty" qemu:"id"`            // [,id=name]
+	Aio          AioMode                     `yaml:",omitempty" qemu:"aio"`           // [,aio=threads|native|io_uring]
+	ReadOnly     bool                        `yaml:",omitempty" qemu:"readonly"`      // [,readonly=on|off]
+	CopyOnRead   bool                        `yaml:",omitempty" qemu:"copy-on-read"`  // [,copy-on-read=on|off]
+	Discard      BlockdevDiscardOptions      `yaml:",omitempty" qemu:"discard"`       // [,discard=ignore|unmap]
+	DetectZeroes BlockdevDetectZeroesOptions `yaml:",omitempty" qemu:"detect-zeroes"` // [,detect-zeroes=on|off|unmap]
+
+	Throttling *ThrottleLimits `yaml:",omitempty" qemu:"throttling"`       // [,bps=b]...[,iops_size=is] as throttling.bps-total=b ...
+	Group      string          `yaml:",omitempty" qemu:"throttling.group"` // [,group=g] throttle group shared with other drives
+}
+
+func (d *DriveOptions) ToArgs() ([]string, error) {
+	return qemuArg("-drive", "", d)
+}
+
+// -drive cache=, the zero value leaves the QEMU default (writeback)
+type CacheMode int
+
+const (
+	CacheDefault      CacheMode = iota //
+	CacheWritethrough                  // writethrough, flush on every write
+	CacheWriteback                     // writeback, the host page cache holds the writes until the guest flushes
+	CacheNone                          // none, O_DIRECT and writeback
+	CacheDirectsync                    // directsync, O_DIRECT and writethrough
+	CacheUnsafe                        // unsafe, writeback and the flushes of the guest are ignored
+)
+
+var cacheModes = []string{"", "writethrough", "writeback", "none", "directsync", "unsafe"}
+
+func (c CacheMode) String() string { return enumName(cacheModes, int(c)) }
+
+// O_DIRECT, bypass the host page cache
+func (c CacheMode) direct() bool { return c == CacheNone || c == CacheDirectsync }
+
+func (c CacheMode) MarshalYAML() (any, error) { return c.String(), nil }
+
+func (c *CacheMode) UnmarshalYAML(value *yaml.Node) error {
+	return c.UnmarshalText([]byte(value.Value))
+}
+
+func (c *CacheMode) UnmarshalText(text []byte) error {
+	return parseEnum(cacheModes, string(text), "cache", (*int)(c))
 }
 
-func (d *DriveOptions) ToArgs() []string {
-	args := []string{fmt.Sprintf("file=%s", d.File)}
-	if d.If != "" {
-		args = append(args, fmt.Sprintf("if=%s", d.If))
+// -drive aio=, the zero value leaves the QEMU default (threads)
+type AioMode int
+
+const (
+	AioDefault AioMode = iota //
+	AioThreads                // threads, the thread pool of QEMU
+	AioNative                 // native, Linux AIO, needs cache.direct (CacheNone or CacheDirectsync)
+	AioIoUring                // io_uring, Linux
+)
+
+var aioModes = []string{"", "threads", "native", "io_uring"}
+
+func (a AioMode) String() string { return enumName(aioModes, int(a)) }
+
+func (a AioMode) MarshalYAML() (any, error) { return a.String(), nil }
+
+func (a *AioMode) UnmarshalYAML(value *yaml.Node) error {
+	return a.UnmarshalText([]byte(value.Value))
+}
+
+func (a *AioMode) UnmarshalText(text []byte) error {
+	return parseEnum(aioModes, string(text), "aio", (*int)(a))
+}
+
+// -drive rerror= and werror=, the zero value leaves the QEMU default (report, enospc for werror)
+type ErrorAction int
+
+const (
+	ErrorActionDefault ErrorAction = iota //
+	ErrorActionIgnore                     // ignore, the guest does not see the error
+	ErrorActionStop                       // stop, pause the guest, resumed by cont
+	ErrorActionReport                     // report, the guest gets the error
+	ErrorActionEnospc                     // enospc, stop on ENOSPC and report the other errors, werror only
+)
+
+var errorActions = []string{"", "ignore", "stop", "report", "enospc"}
+
+func (e ErrorAction) String() string { return enumName(errorActions, int(e)) }
+
+func (e ErrorAction) MarshalYAML() (any, error) { return e.String(), nil }
+
+func (e *ErrorAction) UnmarshalYAML(value *yaml.Node) error {
+	return e.UnmarshalText([]byte(value.Value))
+}
+
+func (e *ErrorAction) UnmarshalText(text []byte) error {
+	return parseEnum(errorActions, string(text), "error action", (*int)(e))
+}
+
+func enumName(names []string, i int) string {
+	if i < 0 || i >= len(names) {
+		return ""
+	}
+	return names[i]
+}
+
+// the index of s in names, the empty name (zero value) included
+func parseEnum(names []string, s, what string, out *int) error {
+	i := slices.Index(names, s)
+	if i < 0 {
+		return fmt.Errorf("%w: %s %s", ErrQemuOpts, what, s)
+	}
+	*out = i
+	return nil
+}
+
+// a -drive by ID or by file, nil when there is none
+func (b *BlockDevicesOptions) findDrive(disk string) *DriveOptions {
+	if b == nil {
+		return nil
+	}
+	for _, d := range b.Drive {
+		if (d.ID != "" && d.ID == disk) || (d.File != "" && sameFile(d.File, disk)) {
+			return d
+		}
 	}
-	if d.Bus != "" {
-		args = append(args, fmt.Sprintf("bus=%s", d.Bus))
+	return nil
+}
+
+// the combinations QEMU refuses at startup
+func (d *DriveOptions) validate(v *validator, field string) {
+	v.file(field+".File", d.File)
+	if d.Aio == AioNative && !d.Cache.direct() {
+		v.add(field+".Aio", "native exige cache none ou directsync")
 	}
-	if d.Unit != "" {
-		args = append(args, fmt.Sprintf("unit=%s", d.Unit))
+	if d.Rerror == ErrorActionEnospc {
+		v.add(field+".Rerror", "enospc só vale para Werror")
 	}
-	if d.Index != "" {
-		args = append(args, fmt.Sprintf("index=%s", d.Index))
+	if d.Rerror != ErrorActionDefault || d.Werror != ErrorActionDefault {
+		switch d.If {
+		case "", "ide", "virtio", "scsi", "none":
+		default:
+			v.add(field+".If", "rerror/werror não suportados em if=%s", d.If)
+		}
 	}
-	if d.Media != "" {
-		args = append(args, fmt.Sprintf("media=%s", d.Media))
+	if d.DetectZeroes == BlockdevDetectZeroesOptionsUnmap && d.Discard != BlockdevDiscardOptionsUnmap {
+		v.add(field+".DetectZeroes", "unmap exige Discard unmap")
 	}
-	if d.Format != "" {
-		args = append(args, fmt.Sprintf("format=%s", d.Format))
+	if d.Cache.direct() && d.File != "" && isLocalFile(d.File) {
+		if ok, err := directIO(d.File); err == nil && !ok {
+			v.add(field+".Cache", "%s: o filesystem de %s não aceita O_DIRECT", d.Cache, d.File)
+		}
 	}
-
-	return []string{"-drive", strings.Join(args, ",")}
 }
 
 /*
@@ -74,92 +206,92 @@ original command:
 		[,detect-zeroes=on|off|unmap]
 		[,driver specific parameters...]
 	    		configure a block backend
+
+the driver specific parameters go in Options, nested maps are the child nodes:
+
+	&BlockDev{Driver: "qcow2", NodeName: "disk0", Options: map[string]any{
+		"file": map[string]any{"driver": "host_device", "filename": "/dev/sdb"},
+	}}
 */
 type BlockDev struct {
-	Driver       string `yaml:",omitempty"` // [driver=]driver
-	Discard      string `yaml:",omitempty"` // [,discard=ignore|unmap]
-	ReadOnly     string `yaml:",omitempty"` //[,read-only=on|off]
-	NodeName     string `yaml:",omitempty"` // [,node-name=N]
-	CacheDirect  string `yaml:",omitempty"` // [,cache.direct=on|off]
-	CacheNoFlush string `yaml:",omitempty"` //[,cache.no-flush=on|off]
-	AutoReadOnly string `yaml:",omitempty"` //[,auto-read-only=on|off]
-	ForceShare   string `yaml:",omitempty"` //[,force-share=on|off]
-	DetectZeroes string `yaml:",omitempty"` //[,detect-zeroes=on|off|unmap]
+	Driver       string         `yaml:",omitempty" qemu:"driver,implied"`      // [driver=]driver
+	Discard      string         `yaml:",omitempty" qemu:"discard"`             // [,discard=ignore|unmap]
+	ReadOnly     string         `yaml:",omitempty" qemu:"read-only,bool"`      //[,read-only=on|off]
+	NodeName     string         `yaml:",omitempty" qemu:"node-name"`           // [,node-name=N]
+	CacheDirect  string         `yaml:",omitempty" qemu:"cache.direct,bool"`   // [,cache.direct=on|off]
+	CacheNoFlush string         `yaml:",omitempty" qemu:"cache.no-flush,bool"` //[,cache.no-flush=on|off]
+	AutoReadOnly string         `yaml:",omitempty" qemu:"auto-read-only,bool"` //[,auto-read-only=on|off]
+	ForceShare   string         `yaml:",omitempty" qemu:"force-share,bool"`    //[,force-share=on|off]
+	DetectZeroes string         `yaml:",omitempty" qemu:"detect-zeroes"`       //[,detect-zeroes=on|off|unmap]
+	Options      map[string]any `yaml:",omitempty" qemu:",extra"`              // [,driver specific parameters...]
 }
 
-func (b *BlockDev) ToArgs() []string {
-	args := []string{}
-	if b.Driver != "" {
-		args = append(args, fmt.Sprintf("driver=%s", b.Driver))
-	}
-	if b.Discard != "" {
-		args = append(args, fmt.Sprintf("discard=%s", b.Discard))
-	}
-	if b.ReadOnly != "" {
-		args = append(args, fmt.Sprintf("read-only=%s", b.ReadOnly))
-	}
-	if b.NodeName != "" {
-		args = append(args, fmt.Sprintf("node-name=%s", b.NodeName))
-	}
-	if b.CacheDirect != "" {
-		args = append(args, fmt.Sprintf("cache.direct=%s", b.CacheDirect))
-	}
-	if b.CacheNoFlush != "" {
-		args = append(args, fmt.Sprintf("cache.no-flush=%s", b.CacheNoFlush))
-	}
-	if b.AutoReadOnly != "" {
-		args = append(args, fmt.Sprintf("auto-read-only=%s", b.AutoReadOnly))
-	}
-	if b.ForceShare != "" {
-		args = append(args, fmt.Sprintf("force-share=%s", b.ForceShare))
-	}
-	if b.DetectZeroes != "" {
-		args = append(args, fmt.Sprintf("detect-zeroes=%s", b.DetectZeroes))
-	}
+func (b *BlockDev) ToArgs() ([]string, error) {
+	return qemuArg("-blockdev", "", b)
+}
 
-	return []string{"-blockdev", strings.Join(args, ",")}
+// -blockdev {"driver":...}, the only form that keeps the types of Options
+func (b *BlockDev) ToJSONArgs() ([]string, error) {
+	return qemuJSONArg("-blockdev", b)
 }
 
 type BlockDevicesOptions struct {
 	Drive    []*DriveOptions `yaml:",omitempty"` // use 'file' as a drive image
 	Cdrom    []*CdromOptions `yaml:",omitempty"` // use 'file' as CD-ROM image
 	BlockDev *BlockDev       `yaml:",omitempty"` //
+	Nodes    []*BlockNode    `yaml:",omitempty"` // -blockdev graph, see BlockNode
 	Fda      string          `yaml:",omitempty"` // use 'file' as floppy disk 0 image
 	Fdb      string          `yaml:",omitempty"` // use 'file' as floppy disk 1 image
 	Hda      string          `yaml:",omitempty"` // use 'file' as hard disk 0 image
 	Hdb      string          `yaml:",omitempty"` // use 'file' as hard disk 1 image
 	Hdc      string          `yaml:",omitempty"` // use 'file' as hard disk 2 image
 	Hdd      string          `yaml:",omitempty"` // use 'file' as hard disk 3 image
+
+	ThrottleGroups []*ThrottleGroup `yaml:",omitempty"` // -object throttle-group, see ThrottleGroup
 }
 
-func (b *BlockDevicesOptions) ToArgs() []string {
-	args := []string{}
+func (b *BlockDevicesOptions) ToArgs() ([]string, error) { return b.toArgs(false) }
+
+func (b *BlockDevicesOptions) toArgs(jsonSyntax bool) ([]string, error) {
+	args := &argsBuilder{}
 	if b.Fda != "" {
-		args = append(args, "-fda", b.Fda)
+		args.put("-fda", b.Fda)
 	}
 	if b.Fdb != "" {
-		args = append(args, "-fdb", b.Fdb)
+		args.put("-fdb", b.Fdb)
 	}
 	if b.Hda != "" {
-		args = append(args, "-hda", b.Hda)
+		args.put("-hda", b.Hda)
 	}
 	if b.Hdb != "" {
-		args = append(args, "-hdb", b.Hdb)
+		args.put("-hdb", b.Hdb)
 	}
 	if b.Hdc != "" {
-		args = append(args, "-hdc", b.Hdc)
+		args.put("-hdc", b.Hdc)
 	}
 	if b.Hdd != "" {
-		args = append(args, "-hdd", b.Hdd)
+		args.put("-hdd", b.Hdd)
+	}
+	for _, t := range b.ThrottleGroups {
+		args.add(t.ToArgs())
+	}
+	for _, n := range sortBlockNodes(b.Nodes) {
+		if jsonSyntax {
+			args.add(n.ToJSONArgs())
+		} else {
+			args.add(n.ToArgs())
+		}
 	}
-	if b.BlockDev != nil {
-		args = append(args, b.BlockDev.ToArgs()...)
+	if b.BlockDev != nil && jsonSyntax {
+		args.add(b.BlockDev.ToJSONArgs())
+	} else if b.BlockDev != nil {
+		args.add(b.BlockDev.ToArgs())
 	}
 	for _, c := range b.Cdrom {
-		args = append(args, c.ToArgs()...)
+		args.add(c.ToArgs())
 	}
 	for _, d := range b.Drive {
-		args = append(args, d.ToArgs()...)
+		args.add(d.ToArgs())
 	}
-	return args
+	return args.result()
 }
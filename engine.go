@@ -55,32 +55,32 @@ func (n EngineArch) MarshalYAML() (any, error) {
 	return n.String(), nil
 }
 
-func (n EngineArch) UnmarshalYAML(value *yaml.Node) error {
+func (n *EngineArch) UnmarshalYAML(value *yaml.Node) error {
 	switch strings.ToLower(value.Value) {
 	default:
 		return fmt.Errorf("status inválido: %s", value.Value)
 	case "qemu-system-arm":
-		n = Qemu_system_arm
+		*n = Qemu_system_arm
 	case "qemu-system-aarch64":
-		n = Qemu_system_aarch64
+		*n = Qemu_system_aarch64
 	case "qemu-system-x86_64":
-		n = Qemu_system_x86_64
+		*n = Qemu_system_x86_64
 	case "qemu-system-i386":
-		n = Qemu_system_i386
+		*n = Qemu_system_i386
 	case "qemu-system-m68k":
-		n = Qemu_system_m68k
+		*n = Qemu_system_m68k
 	case "qemu-system-mips":
-		n = Qemu_system_mips
+		*n = Qemu_system_mips
 	case "qemu-system-ppc32":
-		n = Qemu_system_ppc32
+		*n = Qemu_system_ppc32
 	case "qemu-system-ppc64":
-		n = Qemu_system_ppc64
+		*n = Qemu_system_ppc64
 	case "qemu-system-riscv32":
-		n = Qemu_system_riscv32
+		*n = Qemu_system_riscv32
 	case "qemu-system-riscv64":
-		n = Qemu_system_riscv64
+		*n = Qemu_system_riscv64
 	case "qemu-system-s390x":
-		n = Qemu_system_s390x
+		*n = Qemu_system_s390x
 	}
 	return nil
 }
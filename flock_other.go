@@ -0,0 +1,15 @@
+//go:build !unix
+
+package virt
+
+import (
+	"os"
+	"time"
+)
+
+// no flock(2), only the in-process lock applies
+func lockFile(p string, deadline time.Time) (*os.File, error) {
+	return os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0o644)
+}
+
+func unlockFile(f *os.File) error { return f.Close() }
@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package virt
+
+import (
+	"os"
+	"syscall"
+)
+
+// size and free space of the filesystem holding dir
+func fsUsage(dir string) (capacity, available int64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Blocks) * int64(st.Bsize), int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// bytes allocated to a file, less than its size when sparse
+func fileAllocation(fi os.FileInfo) int64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return fi.Size()
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd)
+
+package virt
+
+import (
+	"errors"
+	"os"
+)
+
+func fsUsage(dir string) (capacity, available int64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+func fileAllocation(fi os.FileInfo) int64 { return fi.Size() }
@@ -38,7 +38,8 @@ type Guest struct {
 
 	//
 
-	Devices []*DeviceOptions
+	Devices    []*DeviceOptions
+	JSONSyntax bool `yaml:",omitempty"` // -blockdev and -device in JSON form
 
 	//
 	Qmp       *QmpOptions `yaml:",omitempty"`
@@ -50,88 +51,96 @@ type Guest struct {
 
 }
 
-func (g *Guest) ToArgs() []string {
+/*
+the QEMU command line of the guest, an option that can not be encoded (a
+TextMarshaler failing on its value) is returned as an error
+*/
+func (g *Guest) ToArgs() ([]string, error) {
 	if g.UUID == "" {
 		g.UUID = uuid.NewString()
 	}
-	args := []string{
+	args := &argsBuilder{args: []string{
 		g.Engine.String(),
 		"-name", g.Name,
 		"-uuid", g.UUID,
-	}
+	}}
 
 	if g.Memory != nil {
-		args = append(args, g.Memory.ToArgs()...)
+		args.add(g.Memory.ToArgs())
+	}
+	if g.MemPath != "" {
+		args.put("-mem-path", g.MemPath)
+	}
+	if g.MemPrealloc != "" {
+		args.put("-mem-prealloc")
 	}
 	if g.Smp != nil {
-		args = append(args, g.Smp.ToArgs()...)
+		args.add(g.Smp.ToArgs())
 	}
 	if g.BlockDevices != nil {
-		args = append(args, g.BlockDevices.ToArgs()...)
+		args.add(g.BlockDevices.toArgs(g.JSONSyntax))
 	}
 	if g.Qmp != nil {
-		args = append(args, g.Qmp.ToArgs()...)
+		args.add(g.Qmp.ToArgs())
 	}
 	if g.Nic != nil {
-		args = append(args, g.Nic.ToArgs()...)
+		args.add(g.Nic.ToArgs())
 	}
 	if g.NetDev_Bridge != nil {
-		args = append(args, g.NetDev_Bridge.ToArgs()...)
+		args.add(g.NetDev_Bridge.ToArgs())
 	}
 	if g.Netdev_Hubport != nil {
-		args = append(args, g.Netdev_Hubport.ToArgs()...)
+		args.add(g.Netdev_Hubport.ToArgs())
 	}
 	if g.Netdev_Passt != nil {
-		args = append(args, g.Netdev_Passt.ToArgs()...)
+		args.add(g.Netdev_Passt.ToArgs())
 	}
 	if g.Netdev_Tap != nil {
-		args = append(args, g.Netdev_Tap.ToArgs()...)
+		args.add(g.Netdev_Tap.ToArgs())
 	}
 	if g.Netdev_User != nil {
-		args = append(args, g.Netdev_User.ToArgs()...)
+		args.add(g.Netdev_User.ToArgs())
 	}
 	if g.Netdev_Vde != nil {
-		args = append(args, g.Netdev_Vde.ToArgs()...)
+		args.add(g.Netdev_Vde.ToArgs())
 	}
 	if g.Netdev_Vhost_user != nil {
-		args = append(args, g.Netdev_Vhost_user.ToArgs()...)
+		args.add(g.Netdev_Vhost_user.ToArgs())
 	}
 	if g.Netdev_Vhost_vdpa != nil {
-		args = append(args, g.Netdev_Vhost_vdpa.ToArgs()...)
+		args.add(g.Netdev_Vhost_vdpa.ToArgs())
 	}
 	for _, d := range g.Devices {
-		args = append(args, d.ToArgs()...)
+		if g.JSONSyntax {
+			args.add(d.ToJSONArgs())
+		} else {
+			args.add(d.ToArgs())
+		}
+	}
+	if g.K != "" {
+		args.put("-k", g.K)
+	}
+	if g.NoGraphic {
+		args.put("-nographic")
 	}
 	if g.Daemonize {
-		args = append(args, "-daemonize")
+		args.put("-daemonize")
 	}
-
-	return args
+	return args.result()
 }
 
 // -qmp stdio
-// -qmp tcp:127.0.0.1:4444,server,nowait
-// -qmp unix:/tmp/qmp-sock,server,nowait
+// -qmp tcp:127.0.0.1:4444,server=on,wait=off
+// -qmp unix:/tmp/qmp-sock,server=on,wait=off
 type QmpOptions struct {
-	ProtoPath string // unix:path | tcp:host:port | stdio
-	Wait      bool   //
-	Serve     bool
+	ProtoPath string `qemu:"path,implied"` // unix:path | tcp:host:port | stdio, a comma is part of the path
+	Serve     bool   `qemu:"server"`       //
+	Wait      bool   `qemu:"wait,always"`  //
 }
 
-func (q *QmpOptions) ToArgs() []string {
-	args := []string{}
+func (q *QmpOptions) ToArgs() ([]string, error) {
 	if p := strings.ToLower(q.ProtoPath); p == "stdio" {
-		args = append(args, p)
-	} else {
-		args = append(args, q.ProtoPath)
-		if q.Serve {
-			args = append(args, "server")
-		}
-		if q.Wait {
-			args = append(args, "wait")
-		} else {
-			args = append(args, "nowait")
-		}
+		return []string{"-qmp", p}, nil
 	}
-	return []string{"-qmp", strings.Join(args, ",")}
+	return qemuArg("-qmp", "", q)
 }
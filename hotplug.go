@@ -0,0 +1,341 @@
+package virt
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrHotplug = errors.New("hotplug de disco inválido")
+
+/*
+a disk attached by AttachDisk. Device is the frontend, virtio-blk-pci by
+default, scsi-hd (Bus a scsi controller, "scsi0.0") or usb-storage; Props are
+extra properties of the device (serial, bootindex ...)
+*/
+type AttachOptions struct {
+	Device   string
+	Format   BlockdevDriver // qcow2 or raw, default the format of the image
+	ReadOnly bool
+	Bus      string
+	Props    map[string]any
+}
+
+// node-names, device IDs and drive IDs of the guest
+func (g *Guest) diskIDs() []string {
+	ids := []string{}
+	if b := g.BlockDevices; b != nil {
+		for _, n := range b.Nodes {
+			ids = append(ids, n.NodeName)
+		}
+		for _, d := range b.Drive {
+			ids = append(ids, d.ID)
+		}
+	}
+	for _, d := range g.Devices {
+		ids = append(ids, d.prop("id"))
+	}
+	return ids
+}
+
+/*
+usage:
+
+	err := m.AttachDisk(ctx, "web01", "disk1", "/var/lib/virt/data.qcow2", &virt.AttachOptions{Device: "scsi-hd", Bus: "scsi0.0"})
+
+attach the image file as the disk named disk: a file node "<disk>-file" and a
+format node "<disk>" in BlockDevices.Nodes and a device with ID disk. The new
+definition must pass Validate. While the guest runs they are added with
+blockdev-add and device_add first, the definition is saved only once the
+guest has the disk.
+*/
+func (m *Manager) AttachDisk(ctx context.Context, name, disk, file string, opts *AttachOptions) error {
+	if opts == nil {
+		opts = &AttachOptions{}
+	}
+	if disk == "" {
+		return fmt.Errorf("%w: disco sem nome", ErrHotplug)
+	}
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	ids := g.diskIDs()
+	if slices.Contains(ids, disk) || slices.Contains(ids, disk+"-file") {
+		return fmt.Errorf("%w: disco %s", os.ErrExist, disk)
+	}
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	format := opts.Format
+	if format == "" {
+		info, err := InspectImage(file)
+		if err != nil {
+			return err
+		}
+		format = BlockdevDriver(info.Format)
+	}
+	if format != BlockdevDriverQcow2 && format != BlockdevDriverRaw {
+		return fmt.Errorf("%w: formato %s", ErrHotplug, format)
+	}
+
+	fileNode, node := diskNodes(disk, format, file)
+	fileNode.ReadOnly, node.ReadOnly = opts.ReadOnly, opts.ReadOnly
+	props := maps.Clone(opts.Props)
+	if props == nil {
+		props = map[string]any{}
+	}
+	props["drive"], props["id"] = disk, disk
+	if opts.Bus != "" {
+		props["bus"] = opts.Bus
+	}
+	dev := &DeviceOptions{Driver: cmp.Or(opts.Device, "virtio-blk-pci"), Props: props}
+
+	if g.BlockDevices == nil {
+		g.BlockDevices = &BlockDevicesOptions{}
+	}
+	g.BlockDevices.Nodes = append(g.BlockDevices.Nodes, fileNode, node)
+	g.Devices = append(g.Devices, dev)
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	running := m.guestRunning(name)
+	if running {
+		if err := m.plugDisk(ctx, g, []*BlockNode{fileNode, node}, dev); err != nil {
+			return err
+		}
+	}
+	if err := m.Store().Put(g); err != nil {
+		if running {
+			return fmt.Errorf("%w: o guest já usa o disco %s, a definição não o tem", err, disk)
+		}
+		return err
+	}
+	m.log.Info("disk attached", "guest", name, "disk", disk, "file", file, "live", running)
+	return nil
+}
+
+// blockdev-add of the nodes, children first, and device_add of dev
+func (m *Manager) plugDisk(ctx context.Context, g *Guest, nodes []*BlockNode, dev *DeviceOptions) error {
+	c, err := dialGuest(ctx, g)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	added := []string{}
+	rollback := func(err error) error {
+		for _, n := range slices.Backward(added) {
+			c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: n})
+		}
+		return err
+	}
+	for _, n := range nodes {
+		live := *n
+		if n.File != nil {
+			// QEMU does not run in the working directory of the caller
+			f := *n.File
+			if f.Filename, err = filepath.Abs(f.Filename); err != nil {
+				return rollback(err)
+			}
+			live.File = &f
+		}
+		obj, err := live.jsonObject()
+		if err != nil {
+			return rollback(err)
+		}
+		if _, err := c.Execute(ctx, "blockdev-add", obj); err != nil {
+			return rollback(err)
+		}
+		added = append(added, n.NodeName)
+	}
+
+	props := maps.Clone(dev.Props)
+	args := &DeviceAddArguments{Driver: dev.Driver, ID: dev.prop("id"), Bus: dev.prop("bus"), Props: props}
+	delete(props, "id")
+	delete(props, "bus")
+	if err := c.DeviceAdd(ctx, args); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
+
+// the device of a disk, by ID or drive=
+func (g *Guest) diskDevice(disk string) int {
+	if i := slices.IndexFunc(g.Devices, func(d *DeviceOptions) bool { return d.prop("id") == disk }); i >= 0 {
+		return i
+	}
+	return slices.IndexFunc(g.Devices, func(d *DeviceOptions) bool { return d.prop("drive") == disk })
+}
+
+/*
+the node top and the nodes under it no other node uses, parents first (the
+order of blockdev-del)
+*/
+func (b *BlockDevicesOptions) diskSubtree(top string) []*BlockNode {
+	byName := map[string]*BlockNode{}
+	for _, n := range b.Nodes {
+		byName[n.NodeName] = n
+	}
+	tree := []*BlockNode{}
+	in := func(name string) bool {
+		return slices.ContainsFunc(tree, func(n *BlockNode) bool { return n.NodeName == name })
+	}
+	usedElsewhere := func(name string) bool {
+		return slices.ContainsFunc(b.Nodes, func(n *BlockNode) bool {
+			return !in(n.NodeName) && slices.ContainsFunc(n.children(), func(o qemuOpt) bool { return o.Value == name })
+		})
+	}
+	if n := byName[top]; n != nil {
+		tree = append(tree, n)
+	}
+	for i := 0; i < len(tree); i++ {
+		for _, ref := range tree[i].children() {
+			if n := byName[ref.Value]; n != nil && !in(n.NodeName) && !usedElsewhere(n.NodeName) {
+				tree = append(tree, n)
+			}
+		}
+	}
+	return tree
+}
+
+/*
+usage:
+
+	err := m.DetachDisk(ctx, "web01", "disk1")
+
+remove the disk attached as disk (the ID of its device or the node of
+drive=): the device and its nodes, or its -drive, leave the definition, which
+must still pass Validate (no node left referencing them). While the guest
+runs device_del asks the guest to release the device, DetachDisk waits for
+DEVICE_DELETED (bounded by ctx, a guest without hotplug support never
+answers) before blockdev-del of the nodes. The image is not removed.
+*/
+func (m *Manager) DetachDisk(ctx context.Context, name, disk string) error {
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	i := g.diskDevice(disk)
+	if i < 0 {
+		return fmt.Errorf("%w: disco %s", os.ErrNotExist, disk)
+	}
+	dev := g.Devices[i]
+	id, top := dev.prop("id"), dev.prop("drive")
+	if id == "" {
+		return fmt.Errorf("%w: o device de %s não tem id", ErrHotplug, disk)
+	}
+	b := g.BlockDevices
+	if b == nil {
+		b = &BlockDevicesOptions{}
+	}
+	nodes := b.diskSubtree(top)
+	drive := slices.IndexFunc(b.Drive, func(d *DriveOptions) bool { return d.ID == top && top != "" })
+
+	g.Devices = slices.Delete(g.Devices, i, i+1)
+	b.Nodes = slices.DeleteFunc(b.Nodes, func(n *BlockNode) bool { return slices.Contains(nodes, n) })
+	if drive >= 0 {
+		// a -drive if=none goes away with its device
+		b.Drive = slices.Delete(b.Drive, drive, drive+1)
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	running := m.guestRunning(name)
+	if running {
+		if err := m.unplugDisk(ctx, g, id, nodes); err != nil {
+			return err
+		}
+	}
+	if err := m.Store().Put(g); err != nil {
+		if running {
+			return fmt.Errorf("%w: o disco %s já saiu do guest, a definição ainda o tem", err, disk)
+		}
+		return err
+	}
+	m.log.Info("disk detached", "guest", name, "disk", disk, "live", running)
+	return nil
+}
+
+/*
+device_del of a device whose unplug was already requested. QEMU has no error
+class for it, only a GenericError whose desc (qdev_unplug) reads "Device 'x'
+is already in the process of unplug": a change of that text makes
+DetachDisk fail instead of waiting again.
+*/
+func unplugPending(err error) bool {
+	qe := &QmpError{}
+	return errors.As(err, &qe) && qe.Class == "GenericError" && strings.Contains(qe.Desc, "in the process of unplug")
+}
+
+// device_del of id, wait for DEVICE_DELETED and blockdev-del of the nodes
+func (m *Manager) unplugDisk(ctx context.Context, g *Guest, id string, nodes []*BlockNode) error {
+	c, err := dialGuest(ctx, g)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// subscribed first, a quick guest does not lose the event
+	sub := c.Subscribe(EventDeviceDeleted)
+	defer sub.Close()
+	// a DetachDisk given up earlier left the unplug pending, keep waiting for it
+	if err := c.DeviceDel(ctx, &DeviceDelArguments{ID: id}); err != nil && !unplugPending(err) {
+		return err
+	}
+	for deleted := false; !deleted; {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return ErrQmpClosed
+			}
+			e := DeviceDeletedEvent{}
+			deleted = json.Unmarshal(ev.Data, &e) == nil && e.Device == id
+		case <-ctx.Done():
+			return fmt.Errorf("%w: o guest não liberou %s: %w", ErrHotplug, id, ctx.Err())
+		}
+	}
+	for _, n := range nodes {
+		if err := c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: n.NodeName}); err != nil {
+			m.log.Warn("node not removed", "guest", g.Name, "node", n.NodeName, "err", err)
+		}
+	}
+	return nil
+}
+
+/*
+usage:
+
+	err := virt.AttachDisk(ctx, "web01", "disk1", "/var/lib/virt/data.qcow2", nil)
+
+attach an image to the guest, live while it runs, see Manager.AttachDisk
+*/
+func AttachDisk(ctx context.Context, name, disk, file string, opts *AttachOptions) error {
+	return defaultManager.AttachDisk(ctx, name, disk, file, opts)
+}
+
+// remove a disk from the guest, live while it runs, see Manager.DetachDisk
+func DetachDisk(ctx context.Context, name, disk string) error {
+	return defaultManager.DetachDisk(ctx, name, disk)
+}
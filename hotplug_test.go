@@ -0,0 +1,72 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// runningGuest with a device for disk0
+func pluggedGuest(t *testing.T) (*Manager, *fakeQmp, *Guest) {
+	t.Helper()
+	m, f := runningGuest(t)
+	g, err := m.Store().Get("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Devices = append(g.Devices, &DeviceOptions{Driver: "virtio-blk-pci", Props: map[string]any{"drive": "disk0", "id": "disk0"}})
+	if err := m.Store().Put(g); err != nil {
+		t.Fatal(err)
+	}
+	return m, f, g
+}
+
+const deviceDeleted = `{"event": "DEVICE_DELETED", "data": {"device": "disk0", "path": "/machine/peripheral/disk0"}, "timestamp": {"seconds": 1, "microseconds": 0}}`
+
+func TestDetachDiskPendingUnplug(t *testing.T) {
+	m, f, _ := pluggedGuest(t)
+	// an earlier DetachDisk gave up waiting
+	f.script(
+		qmpStep{cmd: "device_del", err: "Device 'disk0' is already in the process of unplug", events: []string{deviceDeleted}},
+		qmpStep{cmd: "blockdev-del"},
+		qmpStep{cmd: "blockdev-del"},
+	)
+	if err := m.DetachDisk(context.Background(), "vm1", "disk0"); err != nil {
+		t.Fatal(err)
+	}
+	g, err := m.Store().Get("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Devices) != 0 || len(g.BlockDevices.Nodes) != 0 {
+		t.Errorf("disk0 still in the definition: %+v %+v", g.Devices, g.BlockDevices.Nodes)
+	}
+
+	m, f, _ = pluggedGuest(t)
+	f.script(qmpStep{cmd: "device_del", err: "Bus 'pci.0' does not support hotplugging"})
+	qe := &QmpError{}
+	if err := m.DetachDisk(context.Background(), "vm1", "disk0"); !errors.As(err, &qe) {
+		t.Errorf("err = %v, want the QmpError", err)
+	}
+}
+
+func TestDetachDiskInvalid(t *testing.T) {
+	m, _, g := pluggedGuest(t)
+	// a node over disk0, removing disk0 leaves it dangling
+	g.BlockDevices.Nodes = append(g.BlockDevices.Nodes, &BlockNode{Driver: BlockdevDriverRaw, NodeName: "over", Format: &BlockFormatOptions{File: "disk0"}})
+	if err := m.Store().Put(g); err != nil {
+		t.Fatal(err)
+	}
+	ve := ValidationError{}
+	if err := m.DetachDisk(context.Background(), "vm1", "disk0"); !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	// nothing was sent to the guest (the fake fails on any command) nor saved
+	got, err := m.Store().Get("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Devices) != 1 || len(got.BlockDevices.Nodes) != 3 {
+		t.Errorf("definition changed: %+v %+v", got.Devices, got.BlockDevices.Nodes)
+	}
+}
@@ -0,0 +1,302 @@
+package virt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrJobNotFound = errors.New("job não encontrado")
+
+// returned by Job.Wait when the job concluded with an error
+type JobError struct {
+	ID      string
+	Message string
+}
+
+func (e *JobError) Error() string { return fmt.Sprintf("job %s: %s", e.ID, e.Message) }
+
+/*
+a background job of a running guest (backup, mirror, commit, stream,
+snapshot-save ...) as listed by query-jobs. Block jobs also fill Device,
+Speed, Paused and Ready from query-block-jobs.
+
+The values are those of the query, the methods act on the job by its ID.
+*/
+type Job struct {
+	ID      string
+	Type    JobType
+	Status  JobStatus
+	Current int64  // progress, bytes for block jobs
+	Total   int64  // estimated Current at the end, it may change while the job runs
+	Speed   int64  // block jobs, bytes per second, 0 for unlimited
+	Device  string // block jobs, the node of the job
+	Paused  bool
+	Ready   bool // mirror, waiting for Complete
+	Error   string
+
+	c *Client
+}
+
+// a job known by ID, without querying QEMU
+func (c *Client) jobRef(id string) *Job { return &Job{ID: id, c: c} }
+
+/*
+usage:
+
+	jobs, err := c.Jobs(ctx)
+	for _, j := range jobs {
+		fmt.Println(j.ID, j.Type, j.Status, j.Progress())
+	}
+
+the jobs of QEMU, query-jobs merged with query-block-jobs
+*/
+func (c *Client) Jobs(ctx context.Context) ([]*Job, error) {
+	infos, err := c.QueryJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := c.QueryBlockJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*Job{}
+	for _, i := range infos {
+		j := &Job{
+			ID:      i.ID,
+			Type:    i.Type,
+			Status:  i.Status,
+			Current: i.CurrentProgress,
+			Total:   i.TotalProgress,
+			Error:   i.Error,
+			c:       c,
+		}
+		if k := slices.IndexFunc(blocks, func(b *BlockJobInfo) bool { return b.Device == i.ID }); k >= 0 {
+			b := blocks[k]
+			j.Speed, j.Device, j.Paused, j.Ready = b.Speed, b.Device, b.Paused, b.Ready
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// a job by ID, ErrJobNotFound when QEMU does not list it (dismissed)
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+	jobs, err := c.Jobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// Current/Total, 0 while Total is unknown
+func (j *Job) Progress() float64 {
+	if j.Total <= 0 {
+		return 0
+	}
+	return float64(j.Current) / float64(j.Total)
+}
+
+func (j *Job) Pause(ctx context.Context) error {
+	return j.c.JobPause(ctx, &JobPauseArguments{ID: j.ID})
+}
+
+func (j *Job) Resume(ctx context.Context) error {
+	return j.c.JobResume(ctx, &JobResumeArguments{ID: j.ID})
+}
+
+// ask the job to stop, it concludes with an error (or completes if it was about to)
+func (j *Job) Cancel(ctx context.Context) error {
+	return j.c.JobCancel(ctx, &JobCancelArguments{ID: j.ID})
+}
+
+// end a ready job (mirror), switching to the target
+func (j *Job) Complete(ctx context.Context) error {
+	return j.c.JobComplete(ctx, &JobCompleteArguments{ID: j.ID})
+}
+
+// apply the graph changes of a pending job started with auto-finalize off
+func (j *Job) Finalize(ctx context.Context) error {
+	return j.c.JobFinalize(ctx, &JobFinalizeArguments{ID: j.ID})
+}
+
+// remove a concluded job started with auto-dismiss off
+func (j *Job) Dismiss(ctx context.Context) error {
+	return j.c.JobDismiss(ctx, &JobDismissArguments{ID: j.ID})
+}
+
+// block jobs, bytes per second, 0 for unlimited
+func (j *Job) SetSpeed(ctx context.Context, speed int64) error {
+	return j.c.BlockJobSetSpeed(ctx, &BlockJobSetSpeedArguments{Device: j.ID, Speed: speed})
+}
+
+/*
+usage:
+
+	st, err := j.WaitStatus(ctx, virt.JobStatusReady)
+
+wait for the job to reach one of the statuses, returned at once if it already
+has. A job that concludes (or is dismissed) first ends the wait, with its
+*JobError if it failed.
+*/
+func (j *Job) WaitStatus(ctx context.Context, statuses ...JobStatus) (JobStatus, error) {
+	sub := j.c.Subscribe(EventJobStatusChange, EventBlockJobCompleted)
+	defer sub.Close()
+
+	// subscribed first, a change after the query is not lost
+	cur, err := j.c.Job(ctx, j.ID)
+	if err != nil {
+		return "", err
+	}
+	if cur.Status == JobStatusConcluded {
+		return cur.Status, j.ended(cur.Error, statuses)
+	}
+	if slices.Contains(statuses, cur.Status) {
+		return cur.Status, nil
+	}
+
+	blockErr := ""
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return "", ErrQmpClosed
+			}
+			if ev.Name == EventBlockJobCompleted {
+				e := BlockJobEvent{}
+				if json.Unmarshal(ev.Data, &e) == nil && e.Device == j.ID {
+					blockErr = e.Error
+				}
+				continue
+			}
+			e := JobStatusChangeEvent{}
+			if json.Unmarshal(ev.Data, &e) != nil || e.ID != j.ID {
+				continue
+			}
+			switch st := JobStatus(e.Status); {
+			case st == JobStatusConcluded:
+				// still listed unless auto-dismiss, then only BLOCK_JOB_COMPLETED told the error
+				if cur, err := j.c.Job(ctx, j.ID); err == nil {
+					blockErr = cur.Error
+				}
+				return st, j.ended(blockErr, statuses)
+			case st == JobStatusNull:
+				return st, j.ended(blockErr, statuses)
+			case slices.Contains(statuses, st):
+				return st, nil
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// the error of a job that concluded while waiting for statuses
+func (j *Job) ended(msg string, statuses []JobStatus) error {
+	if msg != "" {
+		return &JobError{ID: j.ID, Message: msg}
+	}
+	if slices.Contains(statuses, JobStatusConcluded) || slices.Contains(statuses, JobStatusNull) {
+		return nil
+	}
+	return fmt.Errorf("job %s concluído antes de %v", j.ID, statuses)
+}
+
+/*
+usage:
+
+	if err := j.Wait(ctx); err != nil {
+		var je *virt.JobError
+		if errors.As(err, &je) { log.Println(je.Message) }
+	}
+
+wait for the job to conclude, from the JOB_STATUS_CHANGE events. The error of
+the job is a *JobError. Jobs started with auto-dismiss off stay listed until
+Dismiss.
+*/
+func (j *Job) Wait(ctx context.Context) error {
+	_, err := j.WaitStatus(ctx, JobStatusConcluded, JobStatusNull)
+	return err
+}
+
+// start a job created with auto-dismiss off, wait for it and dismiss it
+func runJob(ctx context.Context, c *Client, id string, start func() error) error {
+	if err := start(); err != nil {
+		return err
+	}
+	return waitJob(ctx, c, id)
+}
+
+/*
+wait for a job created with auto-dismiss off and dismiss it. Once ctx is done
+the job is cancelled instead (see abortJob), it must not outlive the call.
+*/
+func waitJob(ctx context.Context, c *Client, id string) error {
+	j := c.jobRef(id)
+	err := j.Wait(ctx)
+	if ctx.Err() != nil {
+		abortJob(ctx, c, id)
+		return err
+	}
+	j.Dismiss(ctx)
+	return err
+}
+
+// cancel a job created with auto-dismiss off and dismiss it
+func cancelJob(ctx context.Context, c *Client, id string) error {
+	j := c.jobRef(id)
+	if err := j.Cancel(ctx); err != nil {
+		return err
+	}
+	if err := j.Wait(ctx); err != nil {
+		var je *JobError
+		if !errors.As(err, &je) {
+			return err
+		}
+	}
+	return j.Dismiss(ctx)
+}
+
+// cancelJob, with a context of its own once ctx is done
+func abortJob(ctx context.Context, c *Client, id string) error {
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), QmpDialTimeout)
+		defer cancel()
+	}
+	return cancelJob(ctx, c, id)
+}
+
+/*
+usage:
+
+	jobs, err := m.Jobs(ctx, "web01")
+
+the jobs of a running guest, values only: the connection is closed on return,
+control them with a Client (Guest.Qmp.Dial, Client.Jobs)
+*/
+func (m *Manager) Jobs(ctx context.Context, name string) ([]*Job, error) {
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.guestRunning(name) {
+		return nil, ErrNotRunning
+	}
+	c, err := dialGuest(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Jobs(ctx)
+}
+
+// the jobs of a running guest, see Manager.Jobs
+func Jobs(ctx context.Context, name string) ([]*Job, error) { return defaultManager.Jobs(ctx, name) }
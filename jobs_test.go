@@ -0,0 +1,44 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunJobContextDone(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "qmp.sock")
+	id := "backup-disk0"
+	running := `[{"id": "backup-disk0", "type": "backup", "status": "running", "current-progress": 0, "total-progress": 1048576}]`
+	cancelled := `[{"id": "backup-disk0", "type": "backup", "status": "concluded", "current-progress": 0, "total-progress": 1048576, "error": "Operation cancelled"}]`
+	f := newFakeQmp(t, sock,
+		qmpStep{cmd: "blockdev-backup"},
+		qmpStep{cmd: "query-jobs", ret: running},
+		qmpStep{cmd: "query-block-jobs", ret: `[]`},
+		// after ctx, the job must not be left running
+		qmpStep{cmd: "job-cancel"},
+		qmpStep{cmd: "query-jobs", ret: cancelled},
+		qmpStep{cmd: "query-block-jobs", ret: `[]`},
+		qmpStep{cmd: "job-dismiss"},
+	)
+	c, err := DialQmp(context.Background(), "unix:"+sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = runJob(ctx, c, id, func() error {
+		_, err := c.Execute(ctx, "blockdev-backup", nil)
+		return err
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if got := f.args("job-dismiss"); got["id"] != id {
+		t.Errorf("job-dismiss %v", got)
+	}
+}
@@ -0,0 +1,177 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNoQmp = errors.New("guest sem monitor QMP (use Qmp com Serve)")
+
+	QmpDialTimeout = 5 * time.Second
+)
+
+type StopOptions struct {
+	Timeout     time.Duration // wait for SHUTDOWN after system_powerdown, default 60s
+	QuitTimeout time.Duration // wait for the process to exit after quit (or SIGTERM), default 10s
+	Force       bool          // skip the ACPI powerdown
+}
+
+func (o *StopOptions) defaults() StopOptions {
+	opts := StopOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 60 * time.Second
+	}
+	if opts.QuitTimeout <= 0 {
+		opts.QuitTimeout = 10 * time.Second
+	}
+	return opts
+}
+
+// connect to the guest monitor, it must be started with '-qmp unix:...,server'
+func dialGuest(ctx context.Context, g *Guest) (*Client, error) {
+	if g.Qmp == nil || !g.Qmp.Serve {
+		return nil, ErrNoQmp
+	}
+	ctx, cancel := context.WithTimeout(ctx, QmpDialTimeout)
+	defer cancel()
+	return g.Qmp.Dial(ctx)
+}
+
+func waitExit(i *Instance, timeout time.Duration) bool {
+	select {
+	case <-i.Done():
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+/*
+usage:
+
+	err := virt.StopGuest(guest, &virt.StopOptions{Timeout: 2 * time.Minute})
+
+ACPI powerdown, waiting for SHUTDOWN up to opts.Timeout, then 'quit' and
+finally SIGKILL on the recorded PID. Without a QMP monitor SIGTERM is used
+in place of 'quit'. ErrGuestRunning when the process outlives SIGKILL.
+*/
+func StopGuest(g *Guest, opts *StopOptions) error { return defaultManager.StopGuest(g, opts) }
+
+func (m *Manager) StopGuest(g *Guest, opts *StopOptions) error {
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.stopGuest(g, opts)
+}
+
+func (m *Manager) stopGuest(g *Guest, opts *StopOptions) error {
+	o := opts.defaults()
+	inst, err := m.Supervisor().Attach(g.Name)
+	if err != nil {
+		return ErrNotRunning
+	}
+	m.setState(g.Name, StateShuttingDown, inst.Pid)
+	m.log.Info("stopping guest", "guest", g.Name, "pid", inst.Pid, "force", o.Force)
+
+	c, err := dialGuest(context.Background(), g)
+	if err == nil {
+		defer c.Close()
+		if !o.Force && powerdown(c, inst, o.Timeout) {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), o.QuitTimeout)
+		c.Quit(ctx)
+		cancel()
+	} else {
+		inst.Signal(syscall.SIGTERM)
+	}
+	if waitExit(inst, o.QuitTimeout) {
+		return nil
+	}
+
+	m.log.Warn("killing guest", "guest", g.Name, "pid", inst.Pid)
+	if err := inst.Kill(); err != nil && !errors.Is(err, ErrNotRunning) {
+		return err
+	}
+	if !waitExit(inst, o.QuitTimeout) {
+		return fmt.Errorf("%w: pid %d não saiu após SIGKILL", ErrGuestRunning, inst.Pid)
+	}
+	return nil
+}
+
+// true when the guest shut down and QEMU exited before timeout
+func powerdown(c *Client, inst *Instance, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sub := c.Subscribe(EventShutdown)
+	defer sub.Close()
+	if err := c.SystemPowerdown(ctx); err != nil {
+		return false
+	}
+	select {
+	case _, ok := <-sub.C:
+		if !ok { // connection lost, QEMU is probably exiting
+			return waitExit(inst, time.Until(deadline(ctx)))
+		}
+	case <-inst.Done():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+	// with -no-shutdown QEMU stays alive after SHUTDOWN
+	return waitExit(inst, time.Until(deadline(ctx)))
+}
+
+func deadline(ctx context.Context) time.Time {
+	d, _ := ctx.Deadline()
+	return d
+}
+
+// hard reset of a running guest (system_reset)
+func RebootGuest(g *Guest) error { return defaultManager.RebootGuest(g) }
+
+func (m *Manager) RebootGuest(g *Guest) error {
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := m.Supervisor().Attach(g.Name); err != nil {
+		return ErrNotRunning
+	}
+	c, err := dialGuest(context.Background(), g)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), QmpDialTimeout)
+	defer cancel()
+	return c.SystemReset(ctx)
+}
+
+// stop the QEMU process (see StopGuest) and start a new one
+func RestartGuest(g *Guest, opts *StopOptions) error { return defaultManager.RestartGuest(g, opts) }
+
+func (m *Manager) RestartGuest(g *Guest, opts *StopOptions) error {
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.stopGuest(g, opts); err != nil && !errors.Is(err, ErrNotRunning) {
+		return err
+	}
+	return m.startGuest(g)
+}
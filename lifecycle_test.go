@@ -0,0 +1,22 @@
+package virt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStopGuestOutlivesKill(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(Config{DataPath: dir})
+	// never reaped, the zombie stays alive for the supervisor
+	cmd := startFakeQemu(t, m.Supervisor(), "vm1")
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	err := m.StopGuest(m.NewGuest("vm1"), &StopOptions{QuitTimeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrGuestRunning) {
+		t.Errorf("err = %v, want ErrGuestRunning", err)
+	}
+}
@@ -3,15 +3,17 @@ package virt
 import (
 	"net"
 	"os"
-	"sync"
-)
-
-var (
-	vmListeners sync.Map
 )
 
 func CreateListener(qmpSockPath string) (net.Listener, error) {
-	if _, ok := vmListeners.Load(qmpSockPath); ok {
+	return defaultManager.CreateListener(qmpSockPath)
+}
+
+func DeleteListener(qmpSockPath string) error { return defaultManager.DeleteListener(qmpSockPath) }
+
+// listen on qmpSockPath for a guest started with '-qmp unix:qmpSockPath' (no server)
+func (m *Manager) CreateListener(qmpSockPath string) (net.Listener, error) {
+	if _, ok := m.listeners.Load(qmpSockPath); ok {
 		return nil, os.ErrExist
 	}
 	if err := os.RemoveAll(qmpSockPath); err != nil {
@@ -24,12 +26,12 @@ func CreateListener(qmpSockPath string) (net.Listener, error) {
 	if err := os.Chown(qmpSockPath, 1000, 1000); err != nil {
 		return nil, err
 	}
-	vmListeners.Store(qmpSockPath, listener)
+	m.listeners.Store(qmpSockPath, listener)
 	return listener, nil
 }
 
-func DeleteListener(qmpSockPath string) error {
-	if l, ok := vmListeners.LoadAndDelete(qmpSockPath); ok {
+func (m *Manager) DeleteListener(qmpSockPath string) error {
+	if l, ok := m.listeners.LoadAndDelete(qmpSockPath); ok {
 		if listener, ok := l.(net.Listener); ok {
 			if err := listener.Close(); err != nil {
 				return err
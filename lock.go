@@ -0,0 +1,68 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+)
+
+var (
+	ErrGuestBusy      = errors.New("guest ocupado por outra operação")
+	ErrAlreadyRunning = errors.New("guest já está em execução")
+
+	LockTimeout = 30 * time.Second // wait for another operation on the same guest
+)
+
+func (m *Manager) lockPath(name string) string {
+	return path.Join(m.dataPath(), fmt.Sprintf("%s.lock", name))
+}
+
+/*
+serialize operations on a guest: a semaphore per name inside the process and
+flock(2) on <DataPath>/<name>.lock across processes. Waits up to LockTimeout
+and then returns ErrGuestBusy.
+*/
+func (m *Manager) lockGuest(name string) (unlock func(), err error) {
+	v, _ := m.locks.LoadOrStore(name, make(chan struct{}, 1))
+	sem := v.(chan struct{})
+	deadline := time.Now().Add(LockTimeout)
+
+	t := time.NewTimer(LockTimeout)
+	defer t.Stop()
+	select {
+	case sem <- struct{}{}:
+	case <-t.C:
+		return nil, ErrGuestBusy
+	}
+
+	f, err := lockFile(m.lockPath(name), deadline)
+	if err != nil {
+		<-sem
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		<-sem
+	}, nil
+}
+
+// lock two guests in name order, two calls on the same pair can not deadlock
+func (m *Manager) lockGuests(a, b string) (unlock func(), err error) {
+	if b < a {
+		a, b = b, a
+	}
+	unlockA, err := m.lockGuest(a)
+	if err != nil {
+		return nil, err
+	}
+	unlockB, err := m.lockGuest(b)
+	if err != nil {
+		unlockA()
+		return nil, err
+	}
+	return func() {
+		unlockB()
+		unlockA()
+	}, nil
+}
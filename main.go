@@ -5,41 +5,260 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
 	yaml "gopkg.in/yaml.v3"
 )
 
 var (
-	SocketPath    = "sock/"  // fique a vontade para mudar
-	VmDataPath    = "data/"  // fique a vontade para mudar
-	VmStoragePath = "disks/" // fique a vontade para mudar
+	SocketPath    = "sock/"    // fique a vontade para mudar
+	VmDataPath    = "data/"    // fique a vontade para mudar
+	VmStoragePath = "disks/"   // fique a vontade para mudar
+	VmBackupPath  = "backups/" // fique a vontade para mudar
+
+	ErrGuestRunning = errors.New("guest em execução")
 )
 
-func createGuest(g *Guest) error {
-	fPath := path.Join(VmDataPath, fmt.Sprintf("%s.yaml", g.Name))
-	_, err := os.Stat(fPath)
-	if err == nil {
+/*
+files kept in VmDataPath next to <name>.yaml. <name>.lock is not one of them:
+it stays when the guest is deleted or renamed, replacing a lock file another
+process waits on would let both hold "the" lock.
+*/
+var guestMetaSuffixes = []string{".state.yaml", ".snapshots.yaml", ".backups.yaml", ".pid", ".log", ".events.qmp"}
+
+// write to a temp file in the same directory and rename over fPath
+func writeFileAtomic(fPath string, data []byte) error {
+	f, err := os.CreateTemp(path.Dir(fPath), "."+path.Base(fPath)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fPath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) CreateGuest(g *Guest) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := m.Store().Get(g.Name); err == nil {
 		return os.ErrExist
 	}
 
 	if g.UUID == "" {
 		g.UUID = uuid.NewString()
 	}
+	if err := m.Store().Put(g); err != nil {
+		return err
+	}
+	m.log.Info("guest created", "guest", g.Name)
+	return nil
+}
+
+// replace an existing definition, an invalid guest is refused as by CreateGuest
+func (m *Manager) UpdateGuest(g *Guest) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	f, err := os.Create(fPath)
+	if _, err := m.Store().Get(g.Name); err != nil {
+		return err
+	}
+	return m.Store().Put(g)
+}
+
+func (m *Manager) LoadGuest(name string) (*Guest, error) { return m.Store().Get(name) }
+
+func (m *Manager) ListGuests() ([]*Guest, error) { return m.Store().List() }
+
+func isGuestMeta(file string) bool {
+	for _, s := range guestMetaSuffixes {
+		if strings.HasSuffix(file, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) guestRunning(name string) bool {
+	_, err := m.Supervisor().Attach(name)
+	return err == nil
+}
+
+type DeleteOptions struct {
+	RemoveDisks   bool // remove the guest disks found in the pools, ErrVolumeInUse if another guest uses them
+	RemoveSockets bool // remove the guest sockets found in SocketPath
+}
+
+// files referenced by the guest that live inside dir
+func guestFilesIn(g *Guest, dir string) []string {
+	candidates := guestDisks(g)
+	if g.Qmp != nil {
+		if network, addr, err := qmpNetwork(g.Qmp.ProtoPath); err == nil && network == "unix" {
+			candidates = append(candidates, addr)
+		}
+	}
+	if g.Netdev_Vde != nil {
+		candidates = append(candidates, g.Netdev_Vde.Sock)
+	}
+
+	root := path.Clean(dir)
+	files := []string{}
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(root, path.Clean(c)); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+			files = append(files, c)
+		}
+	}
+	return files
+}
+
+// remove a stopped guest definition, its state and optionally its disks and sockets
+func (m *Manager) DeleteGuest(name string, opts *DeleteOptions) error {
+	unlock, err := m.lockGuest(name)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	if m.guestRunning(name) {
+		return ErrGuestRunning
+	}
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+
+	disks := []string{}
+	if opts.RemoveDisks {
+		t, err := m.loadSnapshots(name)
+		if err != nil {
+			return err
+		}
+		idx, err := m.volumeIndex()
+		if err != nil {
+			return err
+		}
+		for _, f := range append(guestDisks(g), t.files()...) {
+			if _, _, ok := m.lookupVolume(f); !ok {
+				continue
+			}
+			if others := slices.DeleteFunc(idx.users(f), func(u string) bool { return u == name }); len(others) > 0 {
+				return fmt.Errorf("%w: %s por %s", ErrVolumeInUse, f, strings.Join(others, ", "))
+			}
+			disks = append(disks, f)
+		}
+	}
+	remove := []string{}
+	if opts.RemoveSockets {
+		remove = append(remove, guestFilesIn(g, m.socketPath())...)
+	}
+	for _, s := range guestMetaSuffixes {
+		remove = append(remove, path.Join(m.dataPath(), name+s))
+	}
+	for n := 1; n <= LogBackups; n++ {
+		remove = append(remove, fmt.Sprintf("%s.%d", m.Supervisor().logFile(name), n))
+	}
+
+	if err := m.Store().Delete(name); err != nil {
+		return err
+	}
+	m.log.Info("guest deleted", "guest", name)
+	errs := []error{}
+	for _, f := range disks {
+		if p, vol, ok := m.lookupVolume(f); ok {
+			if err := p.Delete(vol); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, f := range remove {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	gData, err := yaml.Marshal(g)
+// rename a stopped guest, refuses with ErrGuestRunning while it runs
+func (m *Manager) RenameGuest(oldName, newName string) error {
+	if oldName == newName {
+		return os.ErrExist
+	}
+	unlock, err := m.lockGuests(oldName, newName)
 	if err != nil {
-		return nil
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(oldName)
+	if err != nil {
+		return err
+	}
+	if m.guestRunning(oldName) {
+		return ErrGuestRunning
+	}
+	if _, err := m.Store().Get(newName); err == nil {
+		return os.ErrExist
+	}
+
+	g.Name = newName
+	if err := m.Store().Put(g); err != nil {
+		return err
+	}
+	if err := m.Store().Delete(oldName); err != nil {
+		return err
+	}
+	for _, s := range guestMetaSuffixes {
+		os.Rename(path.Join(m.dataPath(), oldName+s), path.Join(m.dataPath(), newName+s))
 	}
-	f.Write(gData)
+	if st, err := m.loadState(newName); err == nil {
+		st.Name = newName
+		m.saveState(st)
+	}
+	m.log.Info("guest renamed", "guest", oldName, "name", newName)
 	return nil
 }
 
@@ -61,24 +280,47 @@ func loadGuest(guestPath string) (*Guest, error) {
 	return g, nil
 }
 
-func startGuest(g *Guest) error {
-	var stdout, stderr bytes.Buffer
+// start the guest without blocking, see Supervisor
+func (m *Manager) StartGuest(g *Guest) error {
+	unlock, err := m.lockGuest(g.Name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.startGuest(g)
+}
 
-	a := g.ToArgs()
-	cmd := exec.Command(a[0], a[1:]...)
-	cmd.Stderr = &stderr
-	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
-		return errors.New(stderr.String())
+func (m *Manager) startGuest(g *Guest) error {
+	if err := g.Validate(); err != nil {
+		return err
 	}
-	return nil
+	if m.guestRunning(g.Name) {
+		return ErrAlreadyRunning
+	}
+	m.setState(g.Name, StateStarting, 0)
+	inst, err := m.Supervisor().Start(g)
+	if err != nil {
+		st := &Status{Name: g.Name, State: StateStopped, ExitCode: -1}
+		var se *StartError
+		if errors.As(err, &se) {
+			st.ExitCode = se.ExitCode
+		}
+		if errors.Is(err, os.ErrExist) {
+			return ErrAlreadyRunning
+		}
+		m.saveState(st)
+		m.log.Error("guest failed to start", "guest", g.Name, "err", err)
+		return err
+	}
+	m.log.Info("guest started", "guest", g.Name, "pid", inst.Pid)
+	return m.setState(g.Name, StateRunning, inst.Pid)
 }
 
 /*
 usage:
 
 	yalmPath := path.Join("/custom/path/","guestName.yaml")
-	guest, err := qmp.LoadGuestFromPath(yalmPath)
+	guest, err := virt.LoadGuestFromPath(yalmPath)
 
 load a guest from custom path
 */
@@ -87,13 +329,43 @@ func LoadGuestFromPath(yalmPath string) (*Guest, error) { return loadGuest(yalmP
 /*
 usage:
 
-	guest,err := qmp.LoadGuest("guestName")
+	guest,err := virt.LoadGuest("guestName")
 
-load a guest from default path
+load a guest from DefaultStore
 */
-func LoadGuest(guestName string) (*Guest, error) {
-	return loadGuest(path.Join(VmDataPath, fmt.Sprintf("%s.yaml", guestName)))
+func LoadGuest(guestName string) (*Guest, error) { return defaultManager.LoadGuest(guestName) }
+
+func CreateGuest(g *Guest) error { return defaultManager.CreateGuest(g) }
+
+// list the guests of DefaultStore
+func ListGuests() ([]*Guest, error) { return defaultManager.ListGuests() }
+
+// replace an existing definition (temp file + rename)
+func UpdateGuest(g *Guest) error { return defaultManager.UpdateGuest(g) }
+
+/*
+usage:
+
+	err := virt.DeleteGuest("guestName", &virt.DeleteOptions{RemoveDisks: true})
+
+remove a stopped guest definition, its state and optionally its disks and sockets
+*/
+func DeleteGuest(name string, opts *DeleteOptions) error {
+	return defaultManager.DeleteGuest(name, opts)
 }
 
-func CreateGuest(g *Guest) error { return createGuest(g) }
-func StartGuest(g *Guest) error  { return startGuest(g) }
+// rename a stopped guest, refuses with ErrGuestRunning while it runs
+func RenameGuest(oldName, newName string) error { return defaultManager.RenameGuest(oldName, newName) }
+
+/*
+usage:
+
+	if err := virt.StartGuest(guest); err != nil {
+		var se *virt.StartError
+		if errors.As(err, &se) { log.Println(se.Stderr) }
+	}
+	inst, _ := virt.DefaultSupervisor.Instance(guest.Name)
+
+start the guest without blocking, see Supervisor
+*/
+func StartGuest(g *Guest) error { return defaultManager.StartGuest(g) }
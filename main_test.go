@@ -0,0 +1,98 @@
+package virt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGuestLockFilesKept(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(Config{DataPath: dir, StoragePath: filepath.Join(dir, "disks"), SocketPath: dir})
+	if err := m.CreateGuest(m.NewGuest("vm1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RenameGuest("vm1", "vm2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteGuest("vm2", nil); err != nil {
+		t.Fatal(err)
+	}
+	// another process may be waiting on them
+	for _, f := range []string{"vm1.lock", "vm2.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vm2.yaml")); !os.IsNotExist(err) {
+		t.Errorf("vm2.yaml: %v", err)
+	}
+}
+
+func TestUpdateGuestValidates(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(Config{DataPath: dir, StoragePath: filepath.Join(dir, "disks"), SocketPath: dir})
+	g := m.NewGuest("vm1")
+	if err := m.CreateGuest(g); err != nil {
+		t.Fatal(err)
+	}
+	g.Memory = &MemoryOptions{Size: 4096, Maxmen: 2048}
+	ve := ValidationError{}
+	if err := m.UpdateGuest(g); !errors.As(err, &ve) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	got, err := m.LoadGuest("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Memory != nil {
+		t.Errorf("invalid definition saved: %+v", got.Memory)
+	}
+}
+
+func TestRenameGuestLockOrder(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(Config{DataPath: dir, StoragePath: filepath.Join(dir, "disks"), SocketPath: dir})
+	for _, name := range []string{"vm1", "vm2"} {
+		if err := m.CreateGuest(m.NewGuest(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RenameGuest("vm1", "vm1"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("rename to itself: %v", err)
+	}
+
+	defer func(d time.Duration) { LockTimeout = d }(LockTimeout)
+	LockTimeout = 500 * time.Millisecond
+	// both sides exist, a deadlock would end in ErrGuestBusy
+	for range 20 {
+		errs := make(chan error, 2)
+		go func() { errs <- m.RenameGuest("vm1", "vm2") }()
+		go func() { errs <- m.RenameGuest("vm2", "vm1") }()
+		for range 2 {
+			if err := <-errs; !errors.Is(err, os.ErrExist) {
+				t.Fatalf("err = %v, want os.ErrExist", err)
+			}
+		}
+	}
+}
+
+func TestDeleteGuestVolumeUsersOnce(t *testing.T) {
+	m, _ := stoppedGuest(t)
+	calls := fakeQemuImg(t, "", "0")
+	if err := m.DeleteGuest("vm1", &DeleteOptions{RemoveDisks: true}); err != nil {
+		t.Fatal(err)
+	}
+	// one chain per disk of the guests, not one per disk checked
+	infos := 0
+	for _, c := range calls() {
+		if c[0] == "info" {
+			infos++
+		}
+	}
+	if infos != 2 {
+		t.Errorf("%d qemu-img info, want 2: %q", infos, calls())
+	}
+}
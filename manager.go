@@ -0,0 +1,111 @@
+package virt
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+configuration of a Manager, empty paths fall back to the package variables
+(SocketPath, VmDataPath, VmStoragePath, VmBackupPath)
+*/
+type Config struct {
+	SocketPath  string       // qmp and other guest sockets
+	DataPath    string       // <name>.yaml, state, pid and log files
+	StoragePath string       // guest disks
+	BackupPath  string       // backup chains, <BackupPath>/<name>/<backup>/<disk>.qcow2
+	Pools       []Pool       // besides the default pool over StoragePath
+	Engine      EngineArch   // engine of guests created by NewGuest
+	Store       Store        // default: NewDirStore(DataPath)
+	Logger      *slog.Logger // default: discard
+}
+
+/*
+usage:
+
+	m := virt.NewManager(virt.Config{
+		DataPath:    "/var/lib/virt/data",
+		StoragePath: "/var/lib/virt/disks",
+		SocketPath:  "/run/virt",
+	})
+	g := m.NewGuest("web01")
+	err := m.CreateGuest(g)
+	err = m.StartGuest(g)
+
+an independent VM root, owning the store, the QEMU processes and the listeners
+of its guests. The package functions (CreateGuest, StartGuest ...) use a
+default manager that follows the package variables.
+*/
+type Manager struct {
+	cfg       Config
+	sup       *Supervisor // nil: DefaultSupervisor
+	log       *slog.Logger
+	listeners sync.Map
+	locks     sync.Map // guest name -> chan struct{}, see lockGuest
+}
+
+func NewManager(cfg Config) *Manager {
+	m := &Manager{cfg: cfg, log: cfg.Logger}
+	if m.cfg.Store == nil {
+		m.cfg.Store = NewDirStore(cfg.DataPath)
+	}
+	if m.log == nil {
+		m.log = slog.New(slog.DiscardHandler)
+	}
+	m.sup = NewSupervisor()
+	m.sup.DataPath = cfg.DataPath
+	m.sup.OnExit = m.recordExit
+	return m
+}
+
+// used by the package functions, resolves DefaultStore and DefaultSupervisor on each call
+var defaultManager = &Manager{log: slog.New(slog.DiscardHandler)}
+
+func (m *Manager) socketPath() string {
+	if m.cfg.SocketPath == "" {
+		return SocketPath
+	}
+	return m.cfg.SocketPath
+}
+
+func (m *Manager) dataPath() string {
+	if m.cfg.DataPath == "" {
+		return VmDataPath
+	}
+	return m.cfg.DataPath
+}
+
+func (m *Manager) storagePath() string {
+	if m.cfg.StoragePath == "" {
+		return VmStoragePath
+	}
+	return m.cfg.StoragePath
+}
+
+func (m *Manager) backupPath() string {
+	if m.cfg.BackupPath == "" {
+		return VmBackupPath
+	}
+	return m.cfg.BackupPath
+}
+
+func (m *Manager) Store() Store {
+	if m.cfg.Store == nil {
+		return DefaultStore
+	}
+	return m.cfg.Store
+}
+
+func (m *Manager) Supervisor() *Supervisor {
+	if m.sup == nil {
+		return DefaultSupervisor
+	}
+	return m.sup
+}
+
+// a guest with a fresh UUID and the configured engine
+func (m *Manager) NewGuest(name string) *Guest {
+	return &Guest{Name: name, UUID: uuid.NewString(), Engine: m.cfg.Engine}
+}
@@ -1,14 +1,9 @@
 package virt
 
-import (
-	"fmt"
-	"strings"
-)
-
 type MemoryOptions struct {
-	Size   int    `yaml:",omitempty"` // megabyte
-	Slots  string `yaml:",omitempty"`
-	Maxmen int    `yaml:",omitempty"`
+	Size   int    `yaml:",omitempty" qemu:"size,implied,mb"` // megabyte
+	Slots  string `yaml:",omitempty" qemu:"slots"`
+	Maxmen int    `yaml:",omitempty" qemu:"maxmem,mb,bytes"` // megabyte, maxmem=<bytes> without suffix
 }
 
 // original command:
@@ -18,15 +13,6 @@ type MemoryOptions struct {
 //		size: initial amount of guest memory
 //		slots: number of hotplug slots (default: none)
 //		maxmem: maximum amount of guest memory (default: none)
-func (m *MemoryOptions) ToArgs() []string {
-	args := []string{
-		fmt.Sprintf("size=%d", m.Size),
-	}
-	if m.Slots != "" {
-		args = append(args, fmt.Sprintf("slots=%s", m.Slots))
-	}
-	if m.Maxmen != 0 {
-		args = append(args, fmt.Sprintf("maxmen=%d", m.Maxmen))
-	}
-	return []string{"-m", strings.Join(args, ",")}
+func (m *MemoryOptions) ToArgs() ([]string, error) {
+	return qemuArg("-m", "", m)
 }
@@ -0,0 +1,335 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var ErrMoveDisk = errors.New("movimentação de disco inválida")
+
+type MoveOptions struct {
+	Format        BlockdevDriver // qcow2 or raw, default the format of the disk
+	Speed         int64          // running guests, bytes per second, 0 for unlimited
+	WriteBlocking bool           // running guests, guest writes also go to the destination, a busy disk still gets ready
+	RemoveSource  bool           // remove the old image when it is a pool volume no guest or snapshot uses
+}
+
+// the disk being moved: a node of BlockDevices.Nodes or a -drive
+type moveSource struct {
+	disk   *guestDisk
+	drive  *DriveOptions
+	file   string
+	format BlockdevDriver
+}
+
+// a disk of snapshotDisks by name or a -drive by ID or file
+func (g *Guest) moveSource(t *SnapshotTree, disk string) (*moveSource, error) {
+	for _, d := range g.snapshotDisks(t) {
+		if d.name != disk {
+			continue
+		}
+		if d.node.Format.Backing != "" || d.node.Format.DataFile != "" {
+			return nil, fmt.Errorf("%w: %s tem backing ou data-file em BlockDevices.Nodes", ErrMoveDisk, disk)
+		}
+		return &moveSource{disk: &d, file: d.file.File.Filename, format: d.node.Driver}, nil
+	}
+	if dr := g.BlockDevices.findDrive(disk); dr != nil {
+		if dr.Media == "cdrom" {
+			return nil, fmt.Errorf("%w: %s é um cdrom", ErrMoveDisk, disk)
+		}
+		src := &moveSource{drive: dr, file: dr.File, format: BlockdevDriver(dr.Format)}
+		if src.format == "" {
+			info, err := InspectImage(dr.File)
+			if err != nil {
+				return nil, err
+			}
+			src.format = BlockdevDriver(info.Format)
+		}
+		return src, nil
+	}
+	return nil, fmt.Errorf("%w: disco %s", os.ErrNotExist, disk)
+}
+
+// "<disk>-mv", "<disk>-mv2" ... the first name not used by the nodes of the guest
+func (g *Guest) moveNodeName(disk string) string {
+	used := func(name string) bool {
+		return slices.ContainsFunc(g.BlockDevices.Nodes, func(n *BlockNode) bool {
+			return n.NodeName == name || n.NodeName == name+"-file"
+		})
+	}
+	name := disk + "-mv"
+	for i := 2; used(name); i++ {
+		name = disk + "-mv" + strconv.Itoa(i)
+	}
+	return name
+}
+
+/*
+usage:
+
+	err := m.MoveDisk(ctx, "web01", "disk0", "/mnt/fast/web01.qcow2", &virt.MoveOptions{RemoveSource: true})
+
+move a disk to dest, a new image. disk is the name of a disk of
+BlockDevices.Nodes (as in the snapshots) or the ID or file of a -drive. The
+destination is a standalone copy, the backing chain of the disk is collapsed.
+
+While the guest runs, blockdev-mirror copies the disk to a new node over dest
+and the guest keeps writing to it. Once the job is ready job-complete switches
+the guest to the new node and the old nodes are removed. Stopped guests are
+copied with qemu-img convert.
+
+The definition is saved only after the switch: the nodes of the disk become
+"<disk>-mv" and "<disk>-mv-file" (the snapshots keep calling the disk <disk>)
+or the -drive gets dest as File. Internal snapshots and frozen images stay with
+the old images, the dirty bitmaps of the backups do not follow the disk, the
+next backup must be full.
+*/
+func (m *Manager) MoveDisk(ctx context.Context, name, disk, dest string, opts *MoveOptions) error {
+	if opts == nil {
+		opts = &MoveOptions{}
+	}
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return err
+	}
+	src, err := g.moveSource(t, disk)
+	if err != nil {
+		return err
+	}
+	format := opts.Format
+	if format == "" {
+		format = src.format
+	}
+	if format != BlockdevDriverQcow2 && format != BlockdevDriverRaw {
+		return fmt.Errorf("%w: formato %s", ErrMoveDisk, format)
+	}
+	if sameFile(src.file, dest) {
+		return fmt.Errorf("%w: %s já está em %s", ErrMoveDisk, disk, dest)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%w: %s", os.ErrExist, dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	running := m.guestRunning(name)
+	node := ""
+	if running {
+		if src.disk != nil {
+			node = g.moveNodeName(src.disk.name)
+		} else {
+			node = "move-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		}
+		if err := m.mirrorDisk(ctx, g, src, node, dest, format, opts); err != nil {
+			return err
+		}
+	} else if err := ConvertImage(src.file, dest, &ConvertOptions{Format: format}); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	if d := src.disk; d != nil {
+		if node != "" {
+			g.renameNode(d.file.NodeName, node+"-file")
+			g.renameNode(d.node.NodeName, node)
+		}
+		d.file.Driver = BlockdevDriverFile
+		d.file.File.Filename = dest
+		d.node.Driver = format
+	} else {
+		src.drive.File, src.drive.Format = dest, string(format)
+	}
+	if err := m.Store().Put(g); err != nil {
+		if running {
+			return fmt.Errorf("%w: o guest já usa %s, a definição ainda aponta para %s", err, dest, src.file)
+		}
+		os.Remove(dest)
+		return err
+	}
+	if src.disk != nil && node != "" {
+		if t.Active == nil {
+			t.Active = map[string]string{}
+		}
+		t.Active[src.disk.name] = node
+		if err := m.saveSnapshots(name, t); err != nil {
+			return err
+		}
+	}
+	m.log.Info("disk moved", "guest", name, "disk", disk, "file", dest)
+
+	if opts.RemoveSource {
+		m.removeMoved(name, src.file, t)
+	}
+	return nil
+}
+
+// remove the old image of a moved disk if it is a pool volume nothing else uses
+func (m *Manager) removeMoved(name, file string, t *SnapshotTree) {
+	p, vol, ok := m.lookupVolume(file)
+	if !ok || slices.ContainsFunc(t.files(), func(f string) bool { return sameFile(f, file) }) {
+		return
+	}
+	users, err := m.VolumeUsers(file)
+	if err == nil && len(users) > 0 {
+		return
+	}
+	if err == nil {
+		err = p.Delete(vol)
+	}
+	if err != nil {
+		m.log.Warn("moved disk not removed", "guest", name, "file", file, "err", err)
+	}
+}
+
+// blockdev-mirror of a running guest disk to node over dest, switched by job-complete
+func (m *Manager) mirrorDisk(ctx context.Context, g *Guest, src *moveSource, node, dest string, format BlockdevDriver, opts *MoveOptions) error {
+	c, err := dialGuest(ctx, g)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	device := ""
+	if src.disk != nil {
+		device = src.disk.node.NodeName
+	} else {
+		blocks, err := c.QueryBlock(ctx)
+		if err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			if b.Inserted != nil && sameFile(b.Inserted.File, src.file) {
+				device = b.Inserted.NodeName
+			}
+		}
+		if device == "" {
+			return fmt.Errorf("%w: %s não está aberto no guest", ErrMoveDisk, src.file)
+		}
+	}
+
+	info, err := InspectImage(src.file)
+	if err != nil {
+		return err
+	}
+	if err := CreateImage(dest, &ImageOptions{Format: format, Size: info.VirtualSize}); err != nil {
+		return err
+	}
+	file, err := filepath.Abs(dest)
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	fileNode := &BlockdevOptions{Driver: BlockdevDriverFile, NodeName: node + "-file", File: &BlockdevOptionsFile{Filename: file}}
+	ref := node + "-file"
+	formatNode := &BlockdevOptions{Driver: format, NodeName: node}
+	if d := src.disk; d != nil {
+		fileNode.File.Aio = d.file.File.Aio
+		formatNode.Discard, formatNode.DetectZeroes = d.node.Discard, d.node.DetectZeroes
+		if fc := d.file.Cache; fc != nil {
+			direct, noFlush := fc.Direct, fc.NoFlush
+			fileNode.Cache = &BlockdevCacheOptions{Direct: &direct, NoFlush: &noFlush}
+		}
+	} else if dr := src.drive; dr != nil {
+		fileNode.File.Aio = BlockdevAioOptions(dr.Aio.String())
+		formatNode.Discard, formatNode.DetectZeroes = dr.Discard, dr.DetectZeroes
+		if dr.Cache.direct() {
+			direct := true
+			fileNode.Cache = &BlockdevCacheOptions{Direct: &direct}
+		}
+	}
+	if format == BlockdevDriverQcow2 {
+		formatNode.Qcow2 = &BlockdevOptionsQcow2{File: &BlockdevRef{Reference: &ref}}
+	} else {
+		formatNode.Raw = &BlockdevOptionsRaw{File: &BlockdevRef{Reference: &ref}}
+	}
+
+	added := []string{}
+	rollback := func(err error) error {
+		for _, n := range slices.Backward(added) {
+			c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: n})
+		}
+		os.Remove(dest)
+		return err
+	}
+	for _, n := range []*BlockdevOptions{fileNode, formatNode} {
+		if err := c.BlockdevAdd(ctx, n); err != nil {
+			return rollback(err)
+		}
+		added = append(added, n.NodeName)
+	}
+
+	no := false
+	id := "move-" + node
+	args := &BlockdevMirrorArguments{
+		JobID:       id,
+		Device:      device,
+		Target:      node,
+		Sync:        MirrorSyncModeFull,
+		AutoDismiss: &no,
+	}
+	if opts.Speed > 0 {
+		args.Speed = &opts.Speed
+	}
+	if opts.WriteBlocking {
+		args.CopyMode = MirrorCopyModeWriteBlocking
+	}
+	if err := c.BlockdevMirror(ctx, args); err != nil {
+		return rollback(err)
+	}
+	j := c.jobRef(id)
+	if _, err := j.WaitStatus(ctx, JobStatusReady); err != nil {
+		var je *JobError
+		if errors.As(err, &je) {
+			j.Dismiss(ctx)
+		} else {
+			abortJob(ctx, c, id)
+		}
+		return rollback(err)
+	}
+	if err := j.Complete(ctx); err != nil {
+		abortJob(ctx, c, id)
+		return rollback(err)
+	}
+	if err := waitJob(ctx, c, id); err != nil {
+		return rollback(err)
+	}
+
+	// the nodes created by -blockdev or by an earlier move, the implicit ones went away with the switch
+	old := []string{device, device + "-file"}
+	if src.disk != nil {
+		old[1] = src.disk.file.NodeName
+	}
+	for _, n := range old {
+		c.BlockdevDel(ctx, &BlockdevDelArguments{NodeName: n})
+	}
+	return nil
+}
+
+/*
+usage:
+
+	err := virt.MoveDisk(ctx, "web01", "disk0", "/mnt/fast/web01.qcow2", nil)
+
+move a disk of the guest to a new image, live while it runs, see Manager.MoveDisk
+*/
+func MoveDisk(ctx context.Context, name, disk, dest string, opts *MoveOptions) error {
+	return defaultManager.MoveDisk(ctx, name, disk, dest, opts)
+}
@@ -0,0 +1,53 @@
+package virt
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMoveDiskLiveCache(t *testing.T) {
+	m, f := runningGuest(t)
+	g, err := m.Store().Get("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.BlockDevices.Nodes[0].Cache = &BlockNodeCache{Direct: true, NoFlush: true}
+	if err := m.Store().Put(g); err != nil {
+		t.Fatal(err)
+	}
+
+	id := "move-disk0-mv"
+	job := func(status string) string {
+		return fmt.Sprintf(`[{"id": %q, "type": "mirror", "status": %q, "current-progress": 1048576, "total-progress": 1048576}]`, id, status)
+	}
+	f.script(
+		qmpStep{cmd: "blockdev-add"},
+		qmpStep{cmd: "blockdev-add"},
+		qmpStep{cmd: "blockdev-mirror"},
+		qmpStep{cmd: "query-jobs", ret: job("ready")},
+		qmpStep{cmd: "query-block-jobs", ret: `[]`},
+		qmpStep{cmd: "job-complete"},
+		qmpStep{cmd: "query-jobs", ret: job("concluded")},
+		qmpStep{cmd: "query-block-jobs", ret: `[]`},
+		qmpStep{cmd: "job-dismiss"},
+		qmpStep{cmd: "blockdev-del"},
+		qmpStep{cmd: "blockdev-del"},
+	)
+	dest := filepath.Join(t.TempDir(), "vm1-disk0.qcow2")
+	if err := m.MoveDisk(context.Background(), "vm1", "disk0", dest, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var fileNode map[string]any
+	for _, c := range f.received() {
+		if c.cmd == "blockdev-add" && c.args["driver"] == "file" {
+			fileNode = c.args
+		}
+	}
+	if want := map[string]any{"direct": true, "no-flush": true}; !reflect.DeepEqual(fileNode["cache"], want) {
+		t.Errorf("target file node %v, want cache %v", fileNode, want)
+	}
+}
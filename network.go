@@ -2,7 +2,6 @@ package virt
 
 import (
 	"fmt"
-	"strings"
 
 	yaml "gopkg.in/yaml.v3"
 )
@@ -125,7 +124,7 @@ func (n NicType) String() string {
 	}
 }
 
-func (n *NicType) MarshalYAML() (interface{}, error) {
+func (n NicType) MarshalYAML() (interface{}, error) {
 	return n.String(), nil
 }
 
@@ -155,21 +154,23 @@ func (n *NicType) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+func (n *NicType) UnmarshalText(text []byte) error {
+	t, ok := parseNicType(string(text))
+	if !ok {
+		return fmt.Errorf("%w: nic %s", ErrQemuOpts, text)
+	}
+	*n = t
+	return nil
+}
+
 type NicOptions struct {
-	Type   NicType `yaml:",omitempty"`
-	Mac    string  `yaml:",omitempty"`
-	Option string  `yaml:",omitempty"`
+	Type   NicType `yaml:",omitempty" qemu:"type,implied,always"`
+	Mac    string  `yaml:",omitempty" qemu:"mac"`
+	Option string  `yaml:",omitempty" qemu:",raw"`
 }
 
-func (n *NicOptions) ToArgs() []string {
-	args := []string{n.Type.String()}
-	if n.Option != "" {
-		args = append(args, n.Option)
-	}
-	if n.Mac != "" {
-		args = append(args, n.Mac)
-	}
-	return []string{"-nic", strings.Join(args, ",")}
+func (n *NicOptions) ToArgs() ([]string, error) {
+	return qemuArg("-nic", "", n)
 }
 
 /*
@@ -185,91 +186,30 @@ original command:
 	       its DHCP server and optional services
 */
 type Netdev_UserOptions struct {
-	ID               string `yaml:",omitempty"` // id=str
-	Ipv4             string `yaml:",omitempty"` // [,ipv4=on|off]
-	Net              string `yaml:",omitempty"` // [,net=addr[/mask]]
-	Host             string `yaml:",omitempty"` // [,host=addr]
-	Ipv6             string `yaml:",omitempty"` // [,ipv6=on|off]
-	Ipv6_net         string `yaml:",omitempty"` // [,ipv6-net=addr[/int]]
-	Ipv6_host        string `yaml:",omitempty"` // [,ipv6-host=addr]
-	Restrict         string `yaml:",omitempty"` // [,restrict=on|off]
-	Hostname         string `yaml:",omitempty"` // [,hostname=host]
-	Dhcpstart        string `yaml:",omitempty"` // [,dhcpstart=addr]
-	Dns              string `yaml:",omitempty"` // [,dns=addr]
-	Ipv6_dns         string `yaml:",omitempty"` // [,ipv6-dns=addr]
-	Dnssearch        string `yaml:",omitempty"` // [,dnssearch=domain]
-	Domainname       string `yaml:",omitempty"` // [,domainname=domain]
-	Tftp             string `yaml:",omitempty"` // [,tftp=dir]
-	Tftp_server_name string `yaml:",omitempty"` // [,tftp-server-name=name]
-	Bootfile         string `yaml:",omitempty"` // [,bootfile=f]
-	Hostfwd          string `yaml:",omitempty"` // [,hostfwd=rule]
-	Guestfwd         string `yaml:",omitempty"` // [,guestfwd=rule]
-	Smb              string `yaml:",omitempty"` // [,smb=dir[,smbserver=addr]]
+	ID               string `yaml:",omitempty" qemu:"id"`               // id=str
+	Ipv4             string `yaml:",omitempty" qemu:"ipv4"`             // [,ipv4=on|off]
+	Net              string `yaml:",omitempty" qemu:"net"`              // [,net=addr[/mask]]
+	Host             string `yaml:",omitempty" qemu:"host"`             // [,host=addr]
+	Ipv6             string `yaml:",omitempty" qemu:"ipv6"`             // [,ipv6=on|off]
+	Ipv6_net         string `yaml:",omitempty" qemu:"ipv6-net"`         // [,ipv6-net=addr[/int]]
+	Ipv6_host        string `yaml:",omitempty" qemu:"ipv6-host"`        // [,ipv6-host=addr]
+	Restrict         string `yaml:",omitempty" qemu:"restrict"`         // [,restrict=on|off]
+	Hostname         string `yaml:",omitempty" qemu:"hostname"`         // [,hostname=host]
+	Dhcpstart        string `yaml:",omitempty" qemu:"dhcpstart"`        // [,dhcpstart=addr]
+	Dns              string `yaml:",omitempty" qemu:"dns"`              // [,dns=addr]
+	Ipv6_dns         string `yaml:",omitempty" qemu:"ipv6-dns"`         // [,ipv6-dns=addr]
+	Dnssearch        string `yaml:",omitempty" qemu:"dnssearch"`        // [,dnssearch=domain]
+	Domainname       string `yaml:",omitempty" qemu:"domainname"`       // [,domainname=domain]
+	Tftp             string `yaml:",omitempty" qemu:"tftp"`             // [,tftp=dir]
+	Tftp_server_name string `yaml:",omitempty" qemu:"tftp-server-name"` // [,tftp-server-name=name]
+	Bootfile         string `yaml:",omitempty" qemu:"bootfile"`         // [,bootfile=f]
+	Hostfwd          string `yaml:",omitempty" qemu:"hostfwd"`          // [,hostfwd=rule]
+	Guestfwd         string `yaml:",omitempty" qemu:"guestfwd"`         // [,guestfwd=rule]
+	Smb              string `yaml:",omitempty" qemu:"smb"`              // [,smb=dir[,smbserver=addr]]
 }
 
-func (n *Netdev_UserOptions) ToArgs() []string {
-	args := []string{"user"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Ipv4 != "" {
-		args = append(args, fmt.Sprintf("ipv4=%s", n.Ipv4))
-	}
-	if n.Net != "" {
-		args = append(args, fmt.Sprintf("net=%s", n.Net))
-	}
-	if n.Host != "" {
-		args = append(args, fmt.Sprintf("host=%s", n.Host))
-	}
-	if n.Ipv6 != "" {
-		args = append(args, fmt.Sprintf("ipv6=%s", n.Ipv6))
-	}
-	if n.Ipv6_net != "" {
-		args = append(args, fmt.Sprintf("ipv6-net=%s", n.Ipv6_net))
-	}
-	if n.Ipv6_host != "" {
-		args = append(args, fmt.Sprintf("ipv6-host=%s", n.Ipv6_host))
-	}
-	if n.Restrict != "" {
-		args = append(args, fmt.Sprintf("restrict=%s", n.Restrict))
-	}
-	if n.Hostname != "" {
-		args = append(args, fmt.Sprintf("hostname=%s", n.Hostname))
-	}
-	if n.Dhcpstart != "" {
-		args = append(args, fmt.Sprintf("dhcpstart=%s", n.Dhcpstart))
-	}
-	if n.Dns != "" {
-		args = append(args, fmt.Sprintf("dns=%s", n.Dns))
-	}
-	if n.Ipv6_dns != "" {
-		args = append(args, fmt.Sprintf("ipv6-dns=%s", n.Ipv6_dns))
-	}
-	if n.Dnssearch != "" {
-		args = append(args, fmt.Sprintf("dnssearch=%s", n.Dnssearch))
-	}
-	if n.Domainname != "" {
-		args = append(args, fmt.Sprintf("domainname=%s", n.Domainname))
-	}
-	if n.Tftp != "" {
-		args = append(args, fmt.Sprintf("tftp=%s", n.Tftp))
-	}
-	if n.Tftp_server_name != "" {
-		args = append(args, fmt.Sprintf("tftp-server-name=%s", n.Tftp_server_name))
-	}
-	if n.Bootfile != "" {
-		args = append(args, fmt.Sprintf("bootfile=%s", n.Bootfile))
-	}
-	if n.Hostfwd != "" {
-		args = append(args, fmt.Sprintf("hostfwd=%s", n.Hostfwd))
-	}
-	if n.Guestfwd != "" {
-		args = append(args, fmt.Sprintf("guestfwd=%s", n.Guestfwd))
-	}
-	if n.Smb != "" {
-		args = append(args, fmt.Sprintf("smb=%s", n.Smb))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_UserOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "user", n)
 }
 
 /*
@@ -304,75 +244,26 @@ original command:
 */
 
 type Netdev_TapOptions struct {
-	ID         string `yaml:",omitempty"` // id=str
-	Fd         string `yaml:",omitempty"` // [,fd=h]
-	Fds        string `yaml:",omitempty"` // [,fds=x:y:...:z]
-	Ifname     string `yaml:",omitempty"` // [,ifname=name]
-	Script     string `yaml:",omitempty"` // [,script=file]
-	Downscript string `yaml:",omitempty"` // [,downscript=dfile]
-	Br         string `yaml:",omitempty"` // [,br=bridge]
-	Helper     string `yaml:",omitempty"` // [,helper=helper]
-	Sndbuf     string `yaml:",omitempty"` // [,sndbuf=nbytes]
-	Vnet_hdr   string `yaml:",omitempty"` // [,vnet_hdr=on|off]
-	Vhost      string `yaml:",omitempty"` // [,vhost=on|off]
-	Vhostfd    string `yaml:",omitempty"` // [,vhostfd=h]
-	Vhostfds   string `yaml:",omitempty"` // [,vhostfds=x:y:...:z]
-	Vhostforce string `yaml:",omitempty"` // [,vhostforce=on|off]
-	Queues     string `yaml:",omitempty"` // [,queues=n]
-	Poll       string `yaml:",omitempty"` // [,poll-us=n]
+	ID         string `yaml:",omitempty" qemu:"id"`         // id=str
+	Fd         string `yaml:",omitempty" qemu:"fd"`         // [,fd=h]
+	Fds        string `yaml:",omitempty" qemu:"fds"`        // [,fds=x:y:...:z]
+	Ifname     string `yaml:",omitempty" qemu:"ifname"`     // [,ifname=name]
+	Script     string `yaml:",omitempty" qemu:"script"`     // [,script=file]
+	Downscript string `yaml:",omitempty" qemu:"downscript"` // [,downscript=dfile]
+	Br         string `yaml:",omitempty" qemu:"br"`         // [,br=bridge]
+	Helper     string `yaml:",omitempty" qemu:"helper"`     // [,helper=helper]
+	Sndbuf     string `yaml:",omitempty" qemu:"sndbuf"`     // [,sndbuf=nbytes]
+	Vnet_hdr   string `yaml:",omitempty" qemu:"vnet_hdr"`   // [,vnet_hdr=on|off]
+	Vhost      string `yaml:",omitempty" qemu:"vhost"`      // [,vhost=on|off]
+	Vhostfd    string `yaml:",omitempty" qemu:"vhostfd"`    // [,vhostfd=h]
+	Vhostfds   string `yaml:",omitempty" qemu:"vhostfds"`   // [,vhostfds=x:y:...:z]
+	Vhostforce string `yaml:",omitempty" qemu:"vhostforce"` // [,vhostforce=on|off]
+	Queues     string `yaml:",omitempty" qemu:"queues"`     // [,queues=n]
+	Poll       string `yaml:",omitempty" qemu:"poll-us"`    // [,poll-us=n]
 }
 
-func (n Netdev_TapOptions) ToArgs() []string {
-	args := []string{"tap"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Fd != "" {
-		args = append(args, fmt.Sprintf("fd=%s", n.Fd))
-	}
-	if n.Fds != "" {
-		args = append(args, fmt.Sprintf("fds=%s", n.Fds))
-	}
-	if n.Ifname != "" {
-		args = append(args, fmt.Sprintf("ifname=%s", n.Ifname))
-	}
-	if n.Script != "" {
-		args = append(args, fmt.Sprintf("script=%s", n.Script))
-	}
-	if n.Downscript != "" {
-		args = append(args, fmt.Sprintf("downscript=%s", n.Downscript))
-	}
-	if n.Br != "" {
-		args = append(args, fmt.Sprintf("br=%s", n.Br))
-	}
-	if n.Helper != "" {
-		args = append(args, fmt.Sprintf("helper=%s", n.Helper))
-	}
-	if n.Sndbuf != "" {
-		args = append(args, fmt.Sprintf("sndbuf=%s", n.Sndbuf))
-	}
-	if n.Vnet_hdr != "" {
-		args = append(args, fmt.Sprintf("vnet_hdr=%s", n.Vnet_hdr))
-	}
-	if n.Vhost != "" {
-		args = append(args, fmt.Sprintf("vhost=%s", n.Vhost))
-	}
-	if n.Vhostfd != "" {
-		args = append(args, fmt.Sprintf("vhostfd=%s", n.Vhostfd))
-	}
-	if n.Vhostfds != "" {
-		args = append(args, fmt.Sprintf("vhostfds=%s", n.Vhostfds))
-	}
-	if n.Vhostforce != "" {
-		args = append(args, fmt.Sprintf("vhostforce=%s", n.Vhostforce))
-	}
-	if n.Queues != "" {
-		args = append(args, fmt.Sprintf("queues=%s", n.Queues))
-	}
-	if n.Poll != "" {
-		args = append(args, fmt.Sprintf("poll=%s", n.Poll))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_TapOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "tap", n)
 }
 
 /*
@@ -399,151 +290,45 @@ original command:
 			'param' allows to pass any option defined by passt(1)
 */
 type Netdev_PasstOptions struct {
-	ID                string `yaml:",omitempty"` // id=str
-	Path              string `yaml:",omitempty"` // [,path=file]
-	Quiet             string `yaml:",omitempty"` // [,quiet=on|off]
-	Vhost             string `yaml:",omitempty"` // [,vhost-user=on|off]
-	Mtu               string `yaml:",omitempty"` // [,mtu=mtu]
-	Address           string `yaml:",omitempty"` // [,address=addr]
-	Netmask           string `yaml:",omitempty"` // [,netmask=mask]
-	Mac               string `yaml:",omitempty"` // [,mac=addr]
-	Gateway           string `yaml:",omitempty"` // [,gateway=addr]
-	Interface         string `yaml:",omitempty"` // [,interface=name]
-	Outbound          string `yaml:",omitempty"` // [,outbound=address]
-	Outbound_if4      string `yaml:",omitempty"` // [,outbound-if4=name]
-	Outbound_if6      string `yaml:",omitempty"` // [,outbound-if6=name]
-	Dns               string `yaml:",omitempty"` // [,dns=addr]
-	Search            string `yaml:",omitempty"` // [,search=list]
-	Fqdn              string `yaml:",omitempty"` // [,fqdn=name]
-	Dhcp_dns          string `yaml:",omitempty"` // [,dhcp-dns=on|off]
-	Dhcp_search       string `yaml:",omitempty"` // [,dhcp-search=on|off]
-	Map_host_loopback string `yaml:",omitempty"` // [,map-host-loopback=addr]
-	Map_guest_addr    string `yaml:",omitempty"` // [,map-guest-addr=addr]
-	Dns_forward       string `yaml:",omitempty"` // [,dns-forward=addr]
-	Dns_host          string `yaml:",omitempty"` // [,dns-host=addr]
-	Tcp               string `yaml:",omitempty"` // [,tcp=on|off]
-	Udp               string `yaml:",omitempty"` // [,udp=on|off]
-	Icmp              string `yaml:",omitempty"` // [,icmp=on|off]
-	Dhcp              string `yaml:",omitempty"` // [,dhcp=on|off]
-	Ndp               string `yaml:",omitempty"` // [,ndp=on|off]
-	Dhcpv6            string `yaml:",omitempty"` // [,dhcpv6=on|off]
-	RA                string `yaml:",omitempty"` // [,ra=on|off]
-	Freebind          string `yaml:",omitempty"` // [,freebind=on|off]
-	Ipv4              string `yaml:",omitempty"` // [,ipv4=on|off]
-	Ipv6              string `yaml:",omitempty"` // [,ipv6=on|off]
-	Tcp_ports         string `yaml:",omitempty"` // [,tcp-ports=spec]
-	Udp_ports         string `yaml:",omitempty"` // [,udp-ports=spec]
-	Param             string `yaml:",omitempty"` // [,param=list]
+	ID                string `yaml:",omitempty" qemu:"id"`                // id=str
+	Path              string `yaml:",omitempty" qemu:"path"`              // [,path=file]
+	Quiet             string `yaml:",omitempty" qemu:"quiet"`             // [,quiet=on|off]
+	Vhost             string `yaml:",omitempty" qemu:"vhost-user"`        // [,vhost-user=on|off]
+	Mtu               string `yaml:",omitempty" qemu:"mtu"`               // [,mtu=mtu]
+	Address           string `yaml:",omitempty" qemu:"address"`           // [,address=addr]
+	Netmask           string `yaml:",omitempty" qemu:"netmask"`           // [,netmask=mask]
+	Mac               string `yaml:",omitempty" qemu:"mac"`               // [,mac=addr]
+	Gateway           string `yaml:",omitempty" qemu:"gateway"`           // [,gateway=addr]
+	Interface         string `yaml:",omitempty" qemu:"interface"`         // [,interface=name]
+	Outbound          string `yaml:",omitempty" qemu:"outbound"`          // [,outbound=address]
+	Outbound_if4      string `yaml:",omitempty" qemu:"outbound-if4"`      // [,outbound-if4=name]
+	Outbound_if6      string `yaml:",omitempty" qemu:"outbound-if6"`      // [,outbound-if6=name]
+	Dns               string `yaml:",omitempty" qemu:"dns"`               // [,dns=addr]
+	Search            string `yaml:",omitempty" qemu:"search"`            // [,search=list]
+	Fqdn              string `yaml:",omitempty" qemu:"fqdn"`              // [,fqdn=name]
+	Dhcp_dns          string `yaml:",omitempty" qemu:"dhcp-dns"`          // [,dhcp-dns=on|off]
+	Dhcp_search       string `yaml:",omitempty" qemu:"dhcp-search"`       // [,dhcp-search=on|off]
+	Map_host_loopback string `yaml:",omitempty" qemu:"map-host-loopback"` // [,map-host-loopback=addr]
+	Map_guest_addr    string `yaml:",omitempty" qemu:"map-guest-addr"`    // [,map-guest-addr=addr]
+	Dns_forward       string `yaml:",omitempty" qemu:"dns-forward"`       // [,dns-forward=addr]
+	Dns_host          string `yaml:",omitempty" qemu:"dns-host"`          // [,dns-host=addr]
+	Tcp               string `yaml:",omitempty" qemu:"tcp"`               // [,tcp=on|off]
+	Udp               string `yaml:",omitempty" qemu:"udp"`               // [,udp=on|off]
+	Icmp              string `yaml:",omitempty" qemu:"icmp"`              // [,icmp=on|off]
+	Dhcp              string `yaml:",omitempty" qemu:"dhcp"`              // [,dhcp=on|off]
+	Ndp               string `yaml:",omitempty" qemu:"ndp"`               // [,ndp=on|off]
+	Dhcpv6            string `yaml:",omitempty" qemu:"dhcpv6"`            // [,dhcpv6=on|off]
+	RA                string `yaml:",omitempty" qemu:"ra"`                // [,ra=on|off]
+	Freebind          string `yaml:",omitempty" qemu:"freebind"`          // [,freebind=on|off]
+	Ipv4              string `yaml:",omitempty" qemu:"ipv4"`              // [,ipv4=on|off]
+	Ipv6              string `yaml:",omitempty" qemu:"ipv6"`              // [,ipv6=on|off]
+	Tcp_ports         string `yaml:",omitempty" qemu:"tcp-ports"`         // [,tcp-ports=spec]
+	Udp_ports         string `yaml:",omitempty" qemu:"udp-ports"`         // [,udp-ports=spec]
+	Param             string `yaml:",omitempty" qemu:"param"`             // [,param=list]
 }
 
-func (n *Netdev_PasstOptions) ToArgs() []string {
-	args := []string{"passt"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Path != "" {
-		args = append(args, fmt.Sprintf("path=%s", n.Path))
-	}
-	if n.Quiet != "" {
-		args = append(args, fmt.Sprintf("quiet=%s", n.Quiet))
-	}
-	if n.Vhost != "" {
-		args = append(args, fmt.Sprintf("vhost=%s", n.Vhost))
-	}
-	if n.Mtu != "" {
-		args = append(args, fmt.Sprintf("mtu=%s", n.Mtu))
-	}
-	if n.Address != "" {
-		args = append(args, fmt.Sprintf("address=%s", n.Address))
-	}
-	if n.Netmask != "" {
-		args = append(args, fmt.Sprintf("nettmask=%s", n.Netmask))
-	}
-	if n.Mac != "" {
-		args = append(args, fmt.Sprintf("mac=%s", n.Mac))
-	}
-	if n.Gateway != "" {
-		args = append(args, fmt.Sprintf("gateway=%s", n.Gateway))
-	}
-	if n.Interface != "" {
-		args = append(args, fmt.Sprintf("interface=%s", n.Interface))
-	}
-	if n.Outbound != "" {
-		args = append(args, fmt.Sprintf("outbound=%s", n.Outbound))
-	}
-	if n.Outbound_if4 != "" {
-		args = append(args, fmt.Sprintf("outbound-if4=%s", n.Outbound_if4))
-	}
-	if n.Outbound_if6 != "" {
-		args = append(args, fmt.Sprintf("outbound-if6=%s", n.Outbound_if6))
-	}
-	if n.Dns != "" {
-		args = append(args, fmt.Sprintf("dns=%s", n.Dns))
-	}
-	if n.Search != "" {
-		args = append(args, fmt.Sprintf("search=%s", n.Search))
-	}
-	if n.Fqdn != "" {
-		args = append(args, fmt.Sprintf("fqdn=%s", n.Fqdn))
-	}
-	if n.Dhcp_dns != "" {
-		args = append(args, fmt.Sprintf("dhcp-dns=%s", n.Dhcp_dns))
-	}
-	if n.Dhcp_search != "" {
-		args = append(args, fmt.Sprintf("dhcp-search=%s", n.Dhcp_search))
-	}
-	if n.Map_host_loopback != "" {
-		args = append(args, fmt.Sprintf("map-host-loopback=%s", n.Map_host_loopback))
-	}
-	if n.Map_guest_addr != "" {
-		args = append(args, fmt.Sprintf("map-guest-addr=%s", n.Map_guest_addr))
-	}
-	if n.Dns_forward != "" {
-		args = append(args, fmt.Sprintf("dns-forward=%s", n.Dns_forward))
-	}
-	if n.Dns_host != "" {
-		args = append(args, fmt.Sprintf("dns-host=%s", n.Dns_host))
-	}
-	if n.Tcp != "" {
-		args = append(args, fmt.Sprintf("tcp=%s", n.Tcp))
-	}
-	if n.Udp != "" {
-		args = append(args, fmt.Sprintf("udp=%s", n.Udp))
-	}
-	if n.Icmp != "" {
-		args = append(args, fmt.Sprintf("icmp=%s", n.Icmp))
-	}
-	if n.Dhcp != "" {
-		args = append(args, fmt.Sprintf("dhcp=%s", n.Dhcp))
-	}
-	if n.Ndp != "" {
-		args = append(args, fmt.Sprintf("ndp=%s", n.Ndp))
-	}
-	if n.Dhcpv6 != "" {
-		args = append(args, fmt.Sprintf("dhcpv6=%s", n.Dhcpv6))
-	}
-	if n.RA != "" {
-		args = append(args, fmt.Sprintf("ra=%s", n.RA))
-	}
-	if n.Freebind != "" {
-		args = append(args, fmt.Sprintf("freebind=%s", n.Freebind))
-	}
-	if n.Ipv4 != "" {
-		args = append(args, fmt.Sprintf("ipv4=%s", n.Ipv4))
-	}
-	if n.Ipv6 != "" {
-		args = append(args, fmt.Sprintf("ipv6=%s", n.Ipv6))
-	}
-	if n.Tcp_ports != "" {
-		args = append(args, fmt.Sprintf("tcp-ports=%s", n.Tcp_ports))
-	}
-	if n.Udp_ports != "" {
-		args = append(args, fmt.Sprintf("udp-ports=%s", n.Udp_ports))
-	}
-	if n.Param != "" {
-		args = append(args, fmt.Sprintf("param=%s", n.Param))
-	}
-	return []string{"-netdev", strings.Join(args, "")}
+func (n *Netdev_PasstOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "passt", n)
 }
 
 /*
@@ -556,23 +341,13 @@ original command
 	using the program 'helper (default=/usr/lib/qemu/qemu-bridge-helper)
 */
 type NetDev_BridgeOptions struct {
-	ID     string `yaml:",omitempty"` // id=str
-	Br     string `yaml:",omitempty"` // [,br=bridge]
-	Helper string `yaml:",omitempty"` // [,helper=helper]
+	ID     string `yaml:",omitempty" qemu:"id"`     // id=str
+	Br     string `yaml:",omitempty" qemu:"br"`     // [,br=bridge]
+	Helper string `yaml:",omitempty" qemu:"helper"` // [,helper=helper]
 }
 
-func (n *NetDev_BridgeOptions) ToArgs() []string {
-	args := []string{"bridge"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Br != "" {
-		args = append(args, fmt.Sprintf("br=%s", n.Br))
-	}
-	if n.Helper != "" {
-		args = append(args, fmt.Sprintf("helper=%s", n.Helper))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *NetDev_BridgeOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "bridge", n)
 }
 
 /*
@@ -582,25 +357,13 @@ original command:
 			configure a vhost-user network, backed by a chardev 'dev'
 */
 type Netdev_Vhost_userOptions struct {
-	ID         string `yaml:",omitempty"` // id=str
-	Chardev    string `yaml:",omitempty"` // chardev=dev
-	Vhostforce string `yaml:",omitempty"` // [,vhostforce=on|off]
+	ID         string `yaml:",omitempty" qemu:"id"`         // id=str
+	Chardev    string `yaml:",omitempty" qemu:"chardev"`    // chardev=dev
+	Vhostforce string `yaml:",omitempty" qemu:"vhostforce"` // [,vhostforce=on|off]
 }
 
-func (n *Netdev_Vhost_userOptions) ToArgs() []string {
-	args := []string{"vhost-user"}
-
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Chardev != "" {
-		args = append(args, fmt.Sprintf("chardev=%s", n.Chardev))
-	}
-	if n.Vhostforce != "" {
-		args = append(args, fmt.Sprintf("vhostforce=%s", n.Vhostforce))
-	}
-
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_Vhost_userOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "vhost-user", n)
 }
 
 /*
@@ -612,20 +375,13 @@ original command:
 	                use 'vhostfd=h' to connect to an already opened vhost vdpa device
 */
 type Netdev_Vhost_vdpaOptions struct {
-	ID       string `yaml:",omitempty"` // id=str
-	Vhostdev string `yaml:",omitempty"` // [,vhostdev=/path/to/dev]
-	Vhostfd  string `yaml:",omitempty"` // [,vhostfd=h]
+	ID       string `yaml:",omitempty" qemu:"id"`       // id=str
+	Vhostdev string `yaml:",omitempty" qemu:"vhostdev"` // [,vhostdev=/path/to/dev]
+	Vhostfd  string `yaml:",omitempty" qemu:"vhostfd"`  // [,vhostfd=h]
 }
 
-func (n *Netdev_Vhost_vdpaOptions) ToArgs() []string {
-	args := []string{"vhost-vdpa"}
-	if n.Vhostdev != "" {
-		args = append(args, fmt.Sprintf("vhostdev=%s", n.Vhostdev))
-	}
-	if n.Vhostfd != "" {
-		args = append(args, fmt.Sprintf("vhostfd=%s", n.Vhostfd))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_Vhost_vdpaOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "vhost-vdpa", n)
 }
 
 /*
@@ -635,23 +391,13 @@ original command
 		configure a hub port on the hub with ID 'n'
 */
 type Netdev_HubportOptions struct {
-	ID     string `yaml:",omitempty"` // id=str
-	Hubid  string `yaml:",omitempty"` // hubid=n
-	Netdev string `yaml:",omitempty"` // [,netdev=nd]
+	ID     string `yaml:",omitempty" qemu:"id"`     // id=str
+	Hubid  string `yaml:",omitempty" qemu:"hubid"`  // hubid=n
+	Netdev string `yaml:",omitempty" qemu:"netdev"` // [,netdev=nd]
 }
 
-func (n *Netdev_HubportOptions) ToArgs() []string {
-	args := []string{"hubport"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Hubid != "" {
-		args = append(args, fmt.Sprintf("hubid=%s", n.Hubid))
-	}
-	if n.Netdev != "" {
-		args = append(args, fmt.Sprintf("netdev=%s", n.Netdev))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_HubportOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "hubport", n)
 }
 
 /*
@@ -665,29 +411,13 @@ original command:
 	ownership and permissions for communication port.
 */
 type Netdev_VdeOptions struct {
-	ID    string `yaml:",omitempty"` //  id=str
-	Sock  string `yaml:",omitempty"` // [,sock=socketpath]
-	Port  string `yaml:",omitempty"` // [,port=n]
-	Group string `yaml:",omitempty"` // [,group=groupname]
-	Mode  string `yaml:",omitempty"` // [,mode=octalmode]
+	ID    string `yaml:",omitempty" qemu:"id"`    //  id=str
+	Sock  string `yaml:",omitempty" qemu:"sock"`  // [,sock=socketpath]
+	Port  string `yaml:",omitempty" qemu:"port"`  // [,port=n]
+	Group string `yaml:",omitempty" qemu:"group"` // [,group=groupname]
+	Mode  string `yaml:",omitempty" qemu:"mode"`  // [,mode=octalmode]
 }
 
-func (n *Netdev_VdeOptions) ToArgs() []string {
-	args := []string{"vde"}
-	if n.ID != "" {
-		args = append(args, fmt.Sprintf("id=%s", n.ID))
-	}
-	if n.Sock != "" {
-		args = append(args, fmt.Sprintf("sock=%s", n.Sock))
-	}
-	if n.Port != "" {
-		args = append(args, fmt.Sprintf("port=%s", n.Port))
-	}
-	if n.Group != "" {
-		args = append(args, fmt.Sprintf("group=%s", n.Group))
-	}
-	if n.Mode != "" {
-		args = append(args, fmt.Sprintf("mode=%s", n.Mode))
-	}
-	return []string{"-netdev", strings.Join(args, ",")}
+func (n *Netdev_VdeOptions) ToArgs() ([]string, error) {
+	return qemuArg("-netdev", "vde", n)
 }
@@ -0,0 +1,24 @@
+package virt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNicTypeYAML(t *testing.T) {
+	g := &Guest{Name: "vm1", Nic: &NicOptions{Type: Bridge, Option: "br=br0"}}
+	data, err := marshalGuest(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "type: bridge") {
+		t.Errorf("nic not marshaled by name:\n%s", data)
+	}
+	got, err := unmarshalGuest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.Nic != *g.Nic {
+		t.Errorf("nic %+v, want %+v", got.Nic, g.Nic)
+	}
+}
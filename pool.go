@@ -0,0 +1,364 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	DefaultPool = "default" // the pool over StoragePath
+
+	ErrVolumeInUse = errors.New("volume em uso")
+)
+
+// a disk image or block device of a pool
+type Volume struct {
+	Pool       string
+	Name       string
+	Path       string         // as used in DriveOptions.File and BlockFileOptions.Filename
+	Format     BlockdevDriver // qcow2, raw ...
+	Capacity   int64          // virtual size, bytes
+	Allocation int64          // bytes used in the pool
+}
+
+type PoolInfo struct {
+	Name       string
+	Capacity   int64 // bytes
+	Allocation int64 // bytes used by the volumes
+	Available  int64 // bytes free
+}
+
+/*
+named storage for guest volumes. Volume and Delete return os.ErrNotExist for
+unknown volumes, Allocate, Clone and Import os.ErrExist when the name is
+taken. Import copies an image from outside the pool (a backup chain, a
+downloaded template), Lookup finds the volume behind a path used by a guest.
+
+DirPool keeps one image file per volume, other backends (LVM volume group,
+pre-existing block devices) implement the same interface.
+*/
+type Pool interface {
+	Name() string
+	Info() (*PoolInfo, error)
+	Volumes() ([]*Volume, error)
+	Volume(name string) (*Volume, error)
+	Lookup(file string) (name string, ok bool)
+	Allocate(name string, opts *ImageOptions) (*Volume, error)
+	Clone(src, dst string) (*Volume, error)
+	Import(name, file string, opts *ConvertOptions) (*Volume, error)
+	Delete(name string) error
+}
+
+/*
+usage:
+
+	pool := virt.NewDirPool("fast", "/srv/nvme/virt")
+	vol, err := pool.Allocate("web01.qcow2", &virt.ImageOptions{Size: 20 << 30})
+
+a directory of image files, the volume name is the file name
+*/
+type DirPool struct {
+	PoolName string
+	Dir      string
+}
+
+func NewDirPool(name, dir string) *DirPool { return &DirPool{PoolName: name, Dir: dir} }
+
+func (p *DirPool) Name() string { return p.PoolName }
+
+func (p *DirPool) file(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/\x00") || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: volume %q", ErrDiskOptions, name)
+	}
+	return path.Join(p.Dir, name), nil
+}
+
+func (p *DirPool) Info() (*PoolInfo, error) {
+	capacity, available, err := fsUsage(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+	info := &PoolInfo{Name: p.PoolName, Capacity: capacity, Available: available}
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if fi, err := e.Info(); err == nil && fi.Mode().IsRegular() {
+			info.Allocation += fileAllocation(fi)
+		}
+	}
+	return info, nil
+}
+
+func (p *DirPool) Volumes() ([]*Volume, error) {
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+	vols := []*Volume{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		v, err := p.Volume(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		vols = append(vols, v)
+	}
+	return vols, nil
+}
+
+func (p *DirPool) Volume(name string) (*Volume, error) {
+	file, err := p.file(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := InspectImage(file)
+	if err != nil {
+		return nil, err
+	}
+	return &Volume{
+		Pool:       p.PoolName,
+		Name:       name,
+		Path:       file,
+		Format:     BlockdevDriver(info.Format),
+		Capacity:   info.VirtualSize,
+		Allocation: fileAllocation(fi),
+	}, nil
+}
+
+func (p *DirPool) Lookup(file string) (string, bool) {
+	dir, err1 := filepath.Abs(p.Dir)
+	f, err2 := filepath.Abs(file)
+	if err1 != nil || err2 != nil || filepath.Dir(f) != dir {
+		return "", false
+	}
+	return filepath.Base(f), true
+}
+
+func (p *DirPool) Allocate(name string, opts *ImageOptions) (*Volume, error) {
+	file, err := p.file(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file); err == nil {
+		return nil, fmt.Errorf("%w: volume %s", os.ErrExist, name)
+	}
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := CreateImage(file, opts); err != nil {
+		return nil, err
+	}
+	return p.Volume(name)
+}
+
+// full copy of src in the same format, without its backing chain
+func (p *DirPool) Clone(src, dst string) (*Volume, error) {
+	s, err := p.Volume(src)
+	if err != nil {
+		return nil, err
+	}
+	return p.Import(dst, s.Path, &ConvertOptions{Format: s.Format})
+}
+
+// qemu-img convert of file and its backing chain into a new volume
+func (p *DirPool) Import(name, file string, opts *ConvertOptions) (*Volume, error) {
+	dst, err := p.file(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("%w: volume %s", os.ErrExist, name)
+	}
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := ConvertImage(file, dst, opts); err != nil {
+		return nil, err
+	}
+	return p.Volume(name)
+}
+
+func (p *DirPool) Delete(name string) error {
+	file, err := p.file(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// the default pool over StoragePath followed by Config.Pools
+func (m *Manager) Pools() []Pool {
+	pools := []Pool{NewDirPool(DefaultPool, m.storagePath())}
+	for _, p := range m.cfg.Pools {
+		if p.Name() == DefaultPool {
+			pools[0] = p
+		} else {
+			pools = append(pools, p)
+		}
+	}
+	return pools
+}
+
+func (m *Manager) FindPool(name string) (Pool, error) {
+	for _, p := range m.Pools() {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: pool %s", os.ErrNotExist, name)
+}
+
+// the pool and volume name behind a path used by a guest
+func (m *Manager) lookupVolume(file string) (Pool, string, bool) {
+	for _, p := range m.Pools() {
+		if name, ok := p.Lookup(file); ok {
+			return p, name, true
+		}
+	}
+	return nil, "", false
+}
+
+// the image paths of the guest, cdroms excluded
+func (g *Guest) diskFiles() []*string {
+	b := g.BlockDevices
+	if b == nil {
+		return nil
+	}
+	files := []*string{&b.Fda, &b.Fdb, &b.Hda, &b.Hdb, &b.Hdc, &b.Hdd}
+	for _, d := range b.Drive {
+		files = append(files, &d.File)
+	}
+	for _, n := range b.Nodes {
+		if n.File != nil {
+			files = append(files, &n.File.Filename)
+		}
+	}
+	return files
+}
+
+// disk images and block devices used by the guest
+func guestDisks(g *Guest) []string {
+	files := []string{}
+	for _, f := range g.diskFiles() {
+		if *f != "" {
+			files = append(files, *f)
+		}
+	}
+	return files
+}
+
+func sameFile(a, b string) bool {
+	a, err1 := filepath.Abs(a)
+	b, err2 := filepath.Abs(b)
+	return err1 == nil && err2 == nil && a == b
+}
+
+// file and the images below it, following the backing files
+func imageChain(file string) []string {
+	chain := []string{file}
+	for len(chain) < 64 {
+		info, err := InspectImage(chain[len(chain)-1])
+		if err != nil || info.FullBackingFilename == "" {
+			break
+		}
+		chain = append(chain, info.FullBackingFilename)
+	}
+	return chain
+}
+
+/*
+usage:
+
+	users, err := virt.VolumeUsers("disks/web01.qcow2")
+
+names of the guests whose drives or blockdev nodes use file, directly or as
+the backing image of an overlay (linked clones)
+*/
+func (m *Manager) VolumeUsers(file string) ([]string, error) {
+	idx, err := m.volumeIndex()
+	if err != nil {
+		return nil, err
+	}
+	return idx.users(file), nil
+}
+
+/*
+the users of every file the guests use, backing images included. Walking the
+chains runs qemu-img for each image: an operation checking several files
+builds the index once.
+*/
+type volumeIndex map[string][]string
+
+func (m *Manager) volumeIndex() (volumeIndex, error) {
+	guests, err := m.Store().List()
+	if err != nil {
+		return nil, err
+	}
+	idx := volumeIndex{}
+	for _, g := range guests {
+		for _, d := range guestDisks(g) {
+			for _, f := range imageChain(d) {
+				if f, err := filepath.Abs(f); err == nil && !slices.Contains(idx[f], g.Name) {
+					idx[f] = append(idx[f], g.Name)
+				}
+			}
+		}
+	}
+	return idx, nil
+}
+
+func (idx volumeIndex) users(file string) []string {
+	f, err := filepath.Abs(file)
+	if err != nil {
+		return []string{}
+	}
+	return append([]string{}, idx[f]...)
+}
+
+// remove a volume, ErrVolumeInUse while a guest references it
+func (m *Manager) DeleteVolume(pool, name string) error {
+	p, err := m.FindPool(pool)
+	if err != nil {
+		return err
+	}
+	v, err := p.Volume(name)
+	if err != nil {
+		return err
+	}
+	users, err := m.VolumeUsers(v.Path)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("%w: %s por %s", ErrVolumeInUse, name, strings.Join(users, ", "))
+	}
+	if err := p.Delete(name); err != nil {
+		return err
+	}
+	m.log.Info("volume deleted", "pool", pool, "volume", name)
+	return nil
+}
+
+// the default pool over VmStoragePath, see Manager.Pools
+func Pools() []Pool { return defaultManager.Pools() }
+
+func FindPool(name string) (Pool, error) { return defaultManager.FindPool(name) }
+
+// names of the guests whose drives or blockdev nodes use file, directly or as a backing image
+func VolumeUsers(file string) ([]string, error) { return defaultManager.VolumeUsers(file) }
+
+// remove a volume, ErrVolumeInUse while a guest references it
+func DeleteVolume(pool, name string) error { return defaultManager.DeleteVolume(pool, name) }
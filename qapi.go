@@ -0,0 +1,92 @@
+package virt
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+)
+
+// typed QMP bindings in qapi_gen.go are generated from the vendored schema in testdata/qapi
+
+//go:generate go run ./cmd/qapi-gen -schema testdata/qapi/qapi-schema.json -o qapi_gen.go -commands query-status,stop,cont,quit,system_powerdown,system_reset,system_wakeup,human-monitor-command,blockdev-add,blockdev-del,device_del,migrate-set-parameters,blockdev-snapshot-sync,snapshot-save,snapshot-load,snapshot-delete,query-jobs,job-dismiss,job-cancel,job-pause,job-resume,job-complete,job-finalize,query-block-jobs,block-job-set-speed,block-dirty-bitmap-add,block-dirty-bitmap-remove,block-dirty-bitmap-disable,block-dirty-bitmap-merge,blockdev-backup,transaction,nbd-server-start,nbd-server-stop,block-export-add,block-export-del,query-block-exports,blockdev-mirror,query-block,block_set_io_throttle,qom-set
+
+// nil pointers must not be sent as "arguments": null
+func qapiArgs[T any](args *T) any {
+	if args == nil {
+		return nil
+	}
+	return args
+}
+
+// merge the members of a union branch into its base members
+func qapiMerge(base, branch any) ([]byte, error) {
+	data, err := json.Marshal(base)
+	if err != nil || branch == nil {
+		return data, err
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	bdata, err := json.Marshal(branch)
+	if err != nil {
+		return nil, err
+	}
+	bm := map[string]json.RawMessage{}
+	if err := json.Unmarshal(bdata, &bm); err != nil {
+		return nil, err
+	}
+	maps.Copy(m, bm)
+	return json.Marshal(m)
+}
+
+// JSON type of a raw value: 'o'bject, 'a'rray, 's'tring, 'd'igit, 'b'ool, 'n'ull
+func qapiKind(data []byte) byte {
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return 'o'
+		case '[':
+			return 'a'
+		case '"':
+			return 's'
+		case 't', 'f':
+			return 'b'
+		case 'n':
+			return 'n'
+		}
+		return 'd'
+	}
+	return 0
+}
+
+/*
+usage:
+
+	err := c.DeviceAdd(ctx, &virt.DeviceAddArguments{
+		Driver: "virtio-blk-pci",
+		ID:     "disk1",
+		Props:  map[string]any{"drive": "disk1-node"},
+	})
+
+device_add is not generated ('gen': false in qdev.json), the driver properties are free form
+*/
+type DeviceAddArguments struct {
+	Driver string         `json:"driver"`
+	Bus    string         `json:"bus,omitempty"`
+	ID     string         `json:"id,omitempty"`
+	Props  map[string]any `json:"-"` // driver properties
+}
+
+func (d DeviceAddArguments) MarshalJSON() ([]byte, error) {
+	type base DeviceAddArguments
+	return qapiMerge(base(d), d.Props)
+}
+
+// QAPI command 'device_add'
+func (c *Client) DeviceAdd(ctx context.Context, args *DeviceAddArguments) error {
+	_, err := c.Execute(ctx, "device_add", qapiArgs(args))
+	return err
+}
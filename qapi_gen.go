@@ -0,0 +1,1986 @@
+// Code generated by qapi-gen. DO NOT EDIT.
+
+package virt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// OnOffAuto: An enumeration of three options: on, off, and auto
+//
+// QAPI enum 'OnOffAuto'
+type OnOffAuto string
+
+const (
+	// QEMU selects the value between on and off
+	OnOffAutoAuto OnOffAuto = "auto"
+	// Enabled
+	OnOffAutoOn OnOffAuto = "on"
+	// Disabled
+	OnOffAutoOff OnOffAuto = "off"
+)
+
+// String: A fat type wrapping 'str', to be embedded in lists.
+//
+// QAPI struct 'String'
+type String struct {
+	Str string `json:"str"`
+}
+
+// StrOrNull: This is a string value or the explicit lack of a string (null pointer in C).  Intended for cases when 'optional absent' already has a different meaning.
+//
+// QAPI alternate 'StrOrNull'
+type StrOrNull struct {
+	S *string
+	N bool // null
+}
+
+func (a StrOrNull) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.S != nil:
+		return json.Marshal(a.S)
+	case a.N:
+		return []byte("null"), nil
+	}
+	return nil, fmt.Errorf("StrOrNull: no value set")
+}
+
+func (a *StrOrNull) UnmarshalJSON(data []byte) error {
+	switch qapiKind(data) {
+	case 's':
+		a.S = new(string)
+		return json.Unmarshal(data, a.S)
+	case 'n':
+		a.N = true
+		return nil
+	}
+	return fmt.Errorf("StrOrNull: unexpected %s", data)
+}
+
+// InetSocketAddress: Captures a socket address or address range in the Internet namespace.
+//
+// QAPI struct 'InetSocketAddress'
+type InetSocketAddress struct {
+	// host part of the address
+	Host string `json:"host"`
+	// port part of the address
+	Port string `json:"port"`
+	// true if the host/port are guaranteed to be numeric, false if name resolution should be attempted.  Defaults to false.
+	Numeric *bool `json:"numeric,omitempty"`
+	// If present, this is range of possible addresses, with port between @port and @to.
+	To *uint16 `json:"to,omitempty"`
+	// whether to accept IPv4 addresses, default try both IPv4 and IPv6
+	IPv4 *bool `json:"ipv4,omitempty"`
+	// whether to accept IPv6 addresses, default try both IPv4 and IPv6
+	IPv6 *bool `json:"ipv6,omitempty"`
+	// enable keep-alive when connecting to this socket.  Not supported for passive sockets.  (Since 4.2)
+	KeepAlive *bool `json:"keep-alive,omitempty"`
+}
+
+// UnixSocketAddress: Captures a socket address in the local ("Unix socket") namespace.
+//
+// QAPI struct 'UnixSocketAddress'
+type UnixSocketAddress struct {
+	// filesystem path to use
+	Path string `json:"path"`
+	// if true, this is a Linux abstract socket address.  @path will be prefixed by a null byte, and optionally padded with null bytes.  Defaults to false.  (Since 5.1)
+	Abstract *bool `json:"abstract,omitempty"`
+	// if false, pad an abstract socket address with enough null bytes to make it fill struct sockaddr_un member sun_path. Defaults to true.  (Since 5.1)
+	Tight *bool `json:"tight,omitempty"`
+}
+
+// VsockSocketAddress: Captures a socket address in the vsock namespace.
+//
+// QAPI struct 'VsockSocketAddress'
+type VsockSocketAddress struct {
+	// unique host identifier
+	Cid string `json:"cid"`
+	// port
+	Port string `json:"port"`
+}
+
+// SocketAddressType: Available SocketAddress types
+//
+// QAPI enum 'SocketAddressType'
+type SocketAddressType string
+
+const (
+	// Internet address
+	SocketAddressTypeInet SocketAddressType = "inet"
+	// Unix domain socket
+	SocketAddressTypeUnix SocketAddressType = "unix"
+	// VMCI address
+	SocketAddressTypeVsock SocketAddressType = "vsock"
+	// Socket file descriptor
+	SocketAddressTypeFd SocketAddressType = "fd"
+)
+
+// SocketAddress: Captures the address of a socket, which could also be a socket file descriptor
+//
+// QAPI union 'SocketAddress'
+//
+// only the field matching Type is sent
+type SocketAddress struct {
+	// Transport type
+	Type SocketAddressType `json:"type"`
+
+	Inet  *InetSocketAddress  `json:"-"` // type=inet
+	Unix  *UnixSocketAddress  `json:"-"` // type=unix
+	Vsock *VsockSocketAddress `json:"-"` // type=vsock
+	Fd    *String             `json:"-"` // type=fd
+}
+
+func (u SocketAddress) MarshalJSON() ([]byte, error) {
+	type base SocketAddress
+	var branch any
+	switch u.Type {
+	case "inet":
+		if u.Inet != nil {
+			branch = u.Inet
+		}
+	case "unix":
+		if u.Unix != nil {
+			branch = u.Unix
+		}
+	case "vsock":
+		if u.Vsock != nil {
+			branch = u.Vsock
+		}
+	case "fd":
+		if u.Fd != nil {
+			branch = u.Fd
+		}
+	}
+	return qapiMerge(base(u), branch)
+}
+
+func (u *SocketAddress) UnmarshalJSON(data []byte) error {
+	type base SocketAddress
+	if err := json.Unmarshal(data, (*base)(u)); err != nil {
+		return err
+	}
+	switch u.Type {
+	case "inet":
+		u.Inet = &InetSocketAddress{}
+		return json.Unmarshal(data, u.Inet)
+	case "unix":
+		u.Unix = &UnixSocketAddress{}
+		return json.Unmarshal(data, u.Unix)
+	case "vsock":
+		u.Vsock = &VsockSocketAddress{}
+		return json.Unmarshal(data, u.Vsock)
+	case "fd":
+		u.Fd = &String{}
+		return json.Unmarshal(data, u.Fd)
+	}
+	return nil
+}
+
+// QAPI struct 'InetSocketAddressWrapper'
+type InetSocketAddressWrapper struct {
+	// internet domain socket address
+	Data *InetSocketAddress `json:"data"`
+}
+
+// QAPI struct 'UnixSocketAddressWrapper'
+type UnixSocketAddressWrapper struct {
+	// UNIX domain socket address
+	Data *UnixSocketAddress `json:"data"`
+}
+
+// QAPI struct 'VsockSocketAddressWrapper'
+type VsockSocketAddressWrapper struct {
+	// VSOCK domain socket address
+	Data *VsockSocketAddress `json:"data"`
+}
+
+// QAPI struct 'StringWrapper'
+type StringWrapper struct {
+	// the value
+	Data *String `json:"data"`
+}
+
+// SocketAddressLegacy: Captures the address of a socket, which could also be a named file descriptor
+//
+// QAPI union 'SocketAddressLegacy'
+//
+// only the field matching Type is sent
+type SocketAddressLegacy struct {
+	// Transport type
+	Type SocketAddressType `json:"type"`
+
+	Inet  *InetSocketAddressWrapper  `json:"-"` // type=inet
+	Unix  *UnixSocketAddressWrapper  `json:"-"` // type=unix
+	Vsock *VsockSocketAddressWrapper `json:"-"` // type=vsock
+	Fd    *StringWrapper             `json:"-"` // type=fd
+}
+
+func (u SocketAddressLegacy) MarshalJSON() ([]byte, error) {
+	type base SocketAddressLegacy
+	var branch any
+	switch u.Type {
+	case "inet":
+		if u.Inet != nil {
+			branch = u.Inet
+		}
+	case "unix":
+		if u.Unix != nil {
+			branch = u.Unix
+		}
+	case "vsock":
+		if u.Vsock != nil {
+			branch = u.Vsock
+		}
+	case "fd":
+		if u.Fd != nil {
+			branch = u.Fd
+		}
+	}
+	return qapiMerge(base(u), branch)
+}
+
+func (u *SocketAddressLegacy) UnmarshalJSON(data []byte) error {
+	type base SocketAddressLegacy
+	if err := json.Unmarshal(data, (*base)(u)); err != nil {
+		return err
+	}
+	switch u.Type {
+	case "inet":
+		u.Inet = &InetSocketAddressWrapper{}
+		return json.Unmarshal(data, u.Inet)
+	case "unix":
+		u.Unix = &UnixSocketAddressWrapper{}
+		return json.Unmarshal(data, u.Unix)
+	case "vsock":
+		u.Vsock = &VsockSocketAddressWrapper{}
+		return json.Unmarshal(data, u.Vsock)
+	case "fd":
+		u.Fd = &StringWrapper{}
+		return json.Unmarshal(data, u.Fd)
+	}
+	return nil
+}
+
+// RunState: An enumeration of VM run states.
+//
+// QAPI enum 'RunState'
+type RunState string
+
+const (
+	// QEMU is running on a debugger
+	RunStateDebug RunState = "debug"
+	// guest is paused waiting for an incoming migration.
+	RunStateInmigrate RunState = "inmigrate"
+	// An internal error that prevents further guest execution has occurred
+	RunStateInternalError RunState = "internal-error"
+	// the last IOP has failed and the device is configured to pause on I/O errors
+	RunStateIOError RunState = "io-error"
+	// guest has been paused via the 'stop' command
+	RunStatePaused RunState = "paused"
+	// guest is paused following a successful 'migrate'
+	RunStatePostmigrate RunState = "postmigrate"
+	// QEMU was started with -S and guest has not started
+	RunStatePrelaunch RunState = "prelaunch"
+	// guest is paused to finish the migration process
+	RunStateFinishMigrate RunState = "finish-migrate"
+	// guest is paused to restore VM state
+	RunStateRestoreVM RunState = "restore-vm"
+	// guest is actively running
+	RunStateRunning RunState = "running"
+	// guest is paused to save the VM state
+	RunStateSaveVM RunState = "save-vm"
+	// guest is shut down (and -no-shutdown is in use)
+	RunStateShutdown RunState = "shutdown"
+	// guest is suspended (ACPI S3)
+	RunStateSuspended RunState = "suspended"
+	// the watchdog action is configured to pause and has been triggered
+	RunStateWatchdog RunState = "watchdog"
+	// guest has been panicked as a result of guest OS panic
+	RunStateGuestPanicked RunState = "guest-panicked"
+	// guest is paused to save/restore VM state under colo checkpoint, VM can not get into this state unless colo capability is enabled for migration.  (since 2.8)
+	RunStateColo RunState = "colo"
+)
+
+// StatusInfo: Information about VM run state
+//
+// QAPI struct 'StatusInfo'
+type StatusInfo struct {
+	// true if all VCPUs are runnable, false if not runnable
+	Running bool `json:"running"`
+	// the virtual machine @RunState
+	Status RunState `json:"status"`
+}
+
+// JobType: Type of a background job.
+//
+// QAPI enum 'JobType'
+type JobType string
+
+const (
+	// block commit job type, see "block-commit"
+	JobTypeCommit JobType = "commit"
+	// block stream job type, see "block-stream"
+	JobTypeStream JobType = "stream"
+	// drive mirror job type, see "drive-mirror"
+	JobTypeMirror JobType = "mirror"
+	// drive backup job type, see "drive-backup"
+	JobTypeBackup JobType = "backup"
+	// image creation job type, see "blockdev-create" (since 3.0)
+	JobTypeCreate JobType = "create"
+	// image options amend job type, see "x-blockdev-amend" (since 5.1)
+	JobTypeAmend JobType = "amend"
+	// snapshot load job type, see "snapshot-load" (since 6.0)
+	JobTypeSnapshotLoad JobType = "snapshot-load"
+	// snapshot save job type, see "snapshot-save" (since 6.0)
+	JobTypeSnapshotSave JobType = "snapshot-save"
+	// snapshot delete job type, see "snapshot-delete" (since 6.0)
+	JobTypeSnapshotDelete JobType = "snapshot-delete"
+)
+
+// JobStatus: Indicates the present state of a given job in its lifetime.
+//
+// QAPI enum 'JobStatus'
+type JobStatus string
+
+const (
+	// Erroneous, default state.  Should not ever be visible.
+	JobStatusUndefined JobStatus = "undefined"
+	// The job has been created, but not yet started.
+	JobStatusCreated JobStatus = "created"
+	// The job is currently running.
+	JobStatusRunning JobStatus = "running"
+	// The job is running, but paused.  The pause may be requested by either the QMP user or by internal processes.
+	JobStatusPaused JobStatus = "paused"
+	// The job is running, but is ready for the user to signal completion.  This is used for long-running jobs like mirror that are designed to run indefinitely.
+	JobStatusReady JobStatus = "ready"
+	// The job is ready, but paused.  This is nearly identical to
+	JobStatusStandby JobStatus = "standby"
+	// The job is waiting for other jobs in the transaction to converge to the waiting state.  This status will likely not be visible for the last job in a transaction.
+	JobStatusWaiting JobStatus = "waiting"
+	// The job has finished its work, but has finalization steps that it needs to make prior to completing.  These changes will require manual intervention via @job-finalize if auto-finalize was set to false.  These pending changes may still fail.
+	JobStatusPending JobStatus = "pending"
+	// The job is in the process of being aborted, and will finish with an error.  The job will afterwards report that it is
+	JobStatusAborting JobStatus = "aborting"
+	// The job has finished all work.  If auto-dismiss was set to false, the job will remain in the query list until it is dismissed via @job-dismiss.
+	JobStatusConcluded JobStatus = "concluded"
+	// The job is in the process of being dismantled.  This state should not ever be visible externally.
+	JobStatusNull JobStatus = "null"
+)
+
+// JobInfo: Information about a job.
+//
+// QAPI struct 'JobInfo'
+type JobInfo struct {
+	// The job identifier
+	ID string `json:"id"`
+	// The kind of job that is being performed
+	Type JobType `json:"type"`
+	// Current job state/status
+	Status JobStatus `json:"status"`
+	// Progress made until now.  The unit is arbitrary and the value can only meaningfully be used for the ratio of the current progress to @total-progress.  The value is monotonically increasing.
+	CurrentProgress int64 `json:"current-progress"`
+	// Estimated @current-progress value at the completion of the job.  This value can arbitrarily change while the job is running, in both directions.
+	TotalProgress int64 `json:"total-progress"`
+	// If this field is present, the job failed; if it is still missing in the CONCLUDED state, this indicates successful completion.
+	Error string `json:"error,omitempty"`
+}
+
+// BlockdevDiscardOptions: Determines how to handle discard requests.
+//
+// QAPI enum 'BlockdevDiscardOptions'
+type BlockdevDiscardOptions string
+
+const (
+	// Ignore the request
+	BlockdevDiscardOptionsIgnore BlockdevDiscardOptions = "ignore"
+	// Forward as an unmap request
+	BlockdevDiscardOptionsUnmap BlockdevDiscardOptions = "unmap"
+)
+
+// BlockdevDetectZeroesOptions: Describes the operation mode for the automatic conversion of plain zero writes by the OS to driver specific optimized zero write commands.
+//
+// QAPI enum 'BlockdevDetectZeroesOptions'
+type BlockdevDetectZeroesOptions string
+
+const (
+	// Disabled (default)
+	BlockdevDetectZeroesOptionsOff BlockdevDetectZeroesOptions = "off"
+	// Enabled
+	BlockdevDetectZeroesOptionsOn BlockdevDetectZeroesOptions = "on"
+	// Enabled and even try to unmap blocks if possible.  This requires also that @BlockdevDiscardOptions is set to unmap for this device.
+	BlockdevDetectZeroesOptionsUnmap BlockdevDetectZeroesOptions = "unmap"
+)
+
+// BlockdevAioOptions: Selects the AIO backend to handle I/O requests
+//
+// QAPI enum 'BlockdevAioOptions'
+type BlockdevAioOptions string
+
+const (
+	// Use qemu's thread pool
+	BlockdevAioOptionsThreads BlockdevAioOptions = "threads"
+	// Use native AIO backend (only Linux and Windows)
+	BlockdevAioOptionsNative BlockdevAioOptions = "native"
+	// Use linux io_uring (since 5.0)
+	BlockdevAioOptionsIOUring BlockdevAioOptions = "io_uring"
+)
+
+// BlockdevCacheOptions: Includes cache-related options for block devices
+//
+// QAPI struct 'BlockdevCacheOptions'
+type BlockdevCacheOptions struct {
+	// enables use of O_DIRECT (bypass the host page cache; default: false)
+	Direct *bool `json:"direct,omitempty"`
+	// ignore any flush requests for the device (default: false)
+	NoFlush *bool `json:"no-flush,omitempty"`
+}
+
+// BlockdevDriver: Drivers that are supported in block device operations.
+//
+// QAPI enum 'BlockdevDriver'
+type BlockdevDriver string
+
+const (
+	BlockdevDriverBlkdebug        BlockdevDriver = "blkdebug"
+	BlockdevDriverBlklogwrites    BlockdevDriver = "blklogwrites"
+	BlockdevDriverBlkreplay       BlockdevDriver = "blkreplay"
+	BlockdevDriverBlkverify       BlockdevDriver = "blkverify"
+	BlockdevDriverBochs           BlockdevDriver = "bochs"
+	BlockdevDriverCloop           BlockdevDriver = "cloop"
+	BlockdevDriverCompress        BlockdevDriver = "compress"
+	BlockdevDriverCopyBeforeWrite BlockdevDriver = "copy-before-write"
+	BlockdevDriverCopyOnRead      BlockdevDriver = "copy-on-read"
+	BlockdevDriverDmg             BlockdevDriver = "dmg"
+	BlockdevDriverFile            BlockdevDriver = "file"
+	BlockdevDriverSnapshotAccess  BlockdevDriver = "snapshot-access"
+	BlockdevDriverFtp             BlockdevDriver = "ftp"
+	BlockdevDriverFtps            BlockdevDriver = "ftps"
+	BlockdevDriverGluster         BlockdevDriver = "gluster"
+	BlockdevDriverHostCdrom       BlockdevDriver = "host_cdrom"
+	BlockdevDriverHostDevice      BlockdevDriver = "host_device"
+	BlockdevDriverHttp            BlockdevDriver = "http"
+	BlockdevDriverHttps           BlockdevDriver = "https"
+	BlockdevDriverIOUring         BlockdevDriver = "io_uring"
+	BlockdevDriverIscsi           BlockdevDriver = "iscsi"
+	BlockdevDriverLUKS            BlockdevDriver = "luks"
+	// Since 2.9
+	BlockdevDriverNBD         BlockdevDriver = "nbd"
+	BlockdevDriverNfs         BlockdevDriver = "nfs"
+	BlockdevDriverNullAio     BlockdevDriver = "null-aio"
+	BlockdevDriverNullCo      BlockdevDriver = "null-co"
+	BlockdevDriverNvme        BlockdevDriver = "nvme"
+	BlockdevDriverNvmeIOUring BlockdevDriver = "nvme-io_uring"
+	BlockdevDriverParallels   BlockdevDriver = "parallels"
+	BlockdevDriverPreallocate BlockdevDriver = "preallocate"
+	BlockdevDriverQcow        BlockdevDriver = "qcow"
+	BlockdevDriverQcow2       BlockdevDriver = "qcow2"
+	BlockdevDriverQed         BlockdevDriver = "qed"
+	BlockdevDriverQuorum      BlockdevDriver = "quorum"
+	BlockdevDriverRaw         BlockdevDriver = "raw"
+	BlockdevDriverRbd         BlockdevDriver = "rbd"
+	BlockdevDriverReplication BlockdevDriver = "replication"
+	BlockdevDriverSsh         BlockdevDriver = "ssh"
+	// Since 2.11
+	BlockdevDriverThrottle           BlockdevDriver = "throttle"
+	BlockdevDriverVdi                BlockdevDriver = "vdi"
+	BlockdevDriverVhdx               BlockdevDriver = "vhdx"
+	BlockdevDriverVirtioBlkVfioPci   BlockdevDriver = "virtio-blk-vfio-pci"
+	BlockdevDriverVirtioBlkVhostUser BlockdevDriver = "virtio-blk-vhost-user"
+	BlockdevDriverVirtioBlkVhostVdpa BlockdevDriver = "virtio-blk-vhost-vdpa"
+	BlockdevDriverVmdk               BlockdevDriver = "vmdk"
+	BlockdevDriverVpc                BlockdevDriver = "vpc"
+	BlockdevDriverVvfat              BlockdevDriver = "vvfat"
+)
+
+// BlockdevOptionsFile: Driver specific block device options for the file backend.
+//
+// QAPI struct 'BlockdevOptionsFile'
+type BlockdevOptionsFile struct {
+	// path to the image file
+	Filename string `json:"filename"`
+	// the id for the object that will handle persistent reservations for this device (default: none, forward the commands via SG_IO; since 2.11)
+	PrManager string `json:"pr-manager,omitempty"`
+	// whether to enable file locking.  If set to 'auto', only enable when Open File Descriptor (OFD) locking API is available (default: auto, since 2.10)
+	Locking OnOffAuto `json:"locking,omitempty"`
+	// AIO backend (default: threads) (since: 2.8)
+	Aio BlockdevAioOptions `json:"aio,omitempty"`
+	// maximum number of requests to batch together into a single submission in the AIO backend.  The smallest value between this and the aio-max-batch value of the IOThread object is chosen.  0 means that the AIO backend will handle it automatically.  (default: 0, since 6.2)
+	AioMaxBatch *int64 `json:"aio-max-batch,omitempty"`
+	// invalidate page cache during live migration.  This prevents stale data on the migration destination with cache.direct=off.  Currently only supported on Linux hosts. (default: on, since: 4.0)
+	DropCache *bool `json:"drop-cache,omitempty"`
+}
+
+// BlockdevOptionsNull: Driver specific block device options for the null backend.
+//
+// QAPI struct 'BlockdevOptionsNull'
+type BlockdevOptionsNull struct {
+	// size of the device in bytes.
+	Size *int64 `json:"size,omitempty"`
+	// emulated latency (in nanoseconds) in processing requests.  Default to zero which completes requests immediately. (Since 2.4)
+	LatencyNs *uint64 `json:"latency-ns,omitempty"`
+	// if true, reads from the device produce zeroes; if false, the buffer is left unchanged. (default: false; since: 4.1)
+	ReadZeroes *bool `json:"read-zeroes,omitempty"`
+}
+
+// BlockdevOptionsLUKS: Driver specific block device options for LUKS.
+//
+// QAPI struct 'BlockdevOptionsLUKS'
+type BlockdevOptionsLUKS struct {
+	// reference to or definition of the data source block device
+	File *BlockdevRef `json:"file"`
+	// the ID of a QCryptoSecret object providing the decryption key (since 2.6).  Mandatory except when doing a metadata-only probe of the image.
+	KeySecret string `json:"key-secret,omitempty"`
+	// block device holding a detached LUKS header.  (since 9.0)
+	Header *BlockdevRef `json:"header,omitempty"`
+}
+
+// BlockdevOptionsQcow2: Driver specific block device options for qcow2.
+//
+// QAPI struct 'BlockdevOptionsQcow2'
+type BlockdevOptionsQcow2 struct {
+	// reference to or definition of the data source block device
+	File *BlockdevRef `json:"file"`
+	// reference to or definition of the backing file block device, null disables the backing file entirely.  Defaults to the backing file stored the image file.
+	Backing *BlockdevRefOrNull `json:"backing,omitempty"`
+	// whether to enable the lazy refcounts feature (default is taken from the image file)
+	LazyRefcounts *bool `json:"lazy-refcounts,omitempty"`
+	// whether discard requests to the qcow2 device should be forwarded to the data source
+	PassDiscardRequest *bool `json:"pass-discard-request,omitempty"`
+	// whether discard requests for the data source should be issued when a snapshot operation (e.g. deleting a snapshot) frees clusters in the qcow2 file
+	PassDiscardSnapshot *bool `json:"pass-discard-snapshot,omitempty"`
+	// whether discard requests for the data source should be issued on other occasions where a cluster gets freed
+	PassDiscardOther *bool `json:"pass-discard-other,omitempty"`
+	// when enabled, data clusters will remain preallocated when they are no longer used, e.g. because they are discarded or converted to zero clusters.  (default: off, since 8.2)
+	DiscardNoUnref *bool `json:"discard-no-unref,omitempty"`
+	// the maximum total size of the L2 table and refcount block caches in bytes (since 2.2)
+	CacheSize *int64 `json:"cache-size,omitempty"`
+	// the maximum size of the L2 table cache in bytes (since 2.2)
+	L2CacheSize *int64 `json:"l2-cache-size,omitempty"`
+	// the size of each entry in the L2 cache in bytes.  It must be a power of two between 512 and the cluster size.  The default value is the cluster size (since 2.12)
+	L2CacheEntrySize *int64 `json:"l2-cache-entry-size,omitempty"`
+	// the maximum size of the refcount block cache in bytes (since 2.2)
+	RefcountCacheSize *int64 `json:"refcount-cache-size,omitempty"`
+	// clean unused entries in the L2 and refcount caches.  The interval is in seconds.  The default value is 600 on supporting platforms, and 0 on other platforms.  0 disables this feature.  (since 2.5)
+	CacheCleanInterval *int64 `json:"cache-clean-interval,omitempty"`
+	// reference to or definition of the external data file. This may only be specified for images that require an external data file.  If it is not specified for such an image, the data file name is loaded from the image file.  (since 4.0)
+	DataFile *BlockdevRef `json:"data-file,omitempty"`
+}
+
+// BlockdevOptionsRaw: Driver specific block device options for the raw driver.
+//
+// QAPI struct 'BlockdevOptionsRaw'
+type BlockdevOptionsRaw struct {
+	// reference to or definition of the data source block device
+	File *BlockdevRef `json:"file"`
+	// position where the block device starts
+	Offset *int64 `json:"offset,omitempty"`
+	// the assumed size of the device
+	Size *int64 `json:"size,omitempty"`
+}
+
+// BlockdevOptionsNbd: Driver specific block device options for NBD.
+//
+// QAPI struct 'BlockdevOptionsNbd'
+type BlockdevOptionsNbd struct {
+	// NBD server address
+	Server *SocketAddress `json:"server"`
+	// export name
+	Export string `json:"export,omitempty"`
+	// TLS credentials ID
+	TLSCreds string `json:"tls-creds,omitempty"`
+	// TLS hostname override for certificate validation (Since 7.0)
+	TLSHostname string `json:"tls-hostname,omitempty"`
+	// A metadata context name such as "qemu:dirty-bitmap:NAME" or "qemu:allocation-depth" to query in place of the traditional "base:allocation" block status (since 3.0; mutually exclusive with @x-dirty-bitmap)
+	XDirtyBitmap string `json:"x-dirty-bitmap,omitempty"`
+	// On an unexpected disconnect, the nbd client tries to connect again until succeeding or encountering a serious error.  During the first @reconnect-delay seconds, all requests are paused and will be rerun on a successful reconnect.  After that time, any delayed requests and all future requests before a successful reconnect will immediately fail.  Default 0 (Since 4.2)
+	ReconnectDelay *uint32 `json:"reconnect-delay,omitempty"`
+	// In seconds.  If zero, the nbd driver tries the connection only once, and fails to open if the connection fails. If non-zero, the nbd driver will repeat connection attempts until successful or until @open-timeout seconds have elapsed. Default 0 (Since 7.0)
+	OpenTimeout *uint32 `json:"open-timeout,omitempty"`
+}
+
+// BlockdevOptionsThrottle: Driver specific block device options for the throttle driver
+//
+// QAPI struct 'BlockdevOptionsThrottle'
+type BlockdevOptionsThrottle struct {
+	// the name of the throttle-group object to use.  It must already exist.
+	ThrottleGroup string `json:"throttle-group"`
+	// reference to or definition of the data source block device
+	File *BlockdevRef `json:"file"`
+}
+
+// BlockdevOptions: Options for creating a block device.  Many options are available for all block devices, independent of the block driver:
+//
+// QAPI union 'BlockdevOptions'
+//
+// only the field matching Driver is sent
+type BlockdevOptions struct {
+	// block driver name
+	Driver BlockdevDriver `json:"driver"`
+	// the node name of the new node.  This option is required on the top level of blockdev-add.  Valid node names start with an alphabetic character and may contain only alphanumeric characters, '-', '.' and '_'.  Their maximum length is 31 characters.
+	NodeName string `json:"node-name,omitempty"`
+	// discard-related options (default: ignore)
+	Discard BlockdevDiscardOptions `json:"discard,omitempty"`
+	// cache-related options
+	Cache *BlockdevCacheOptions `json:"cache,omitempty"`
+	// whether the block device should be read-only (default: false).  Note that some block drivers support only read-only access, either generally or in certain configurations.  In this case, the default value does not work and the option must be specified explicitly.
+	ReadOnly *bool `json:"read-only,omitempty"`
+	// if true and @read-only is false, QEMU may automatically decide not to open the image read-write as requested, but fall back to read-only instead (and switch between the modes later), e.g. depending on whether the image file is writable or whether a writing user is attached to the node (default: false, since 3.1)
+	AutoReadOnly *bool `json:"auto-read-only,omitempty"`
+	// force share all permission on added nodes.  Requires read-only=true.  (Since 2.10)
+	ForceShare *bool `json:"force-share,omitempty"`
+	// detect and optimize zero writes (Since 2.1) (default: off)
+	DetectZeroes BlockdevDetectZeroesOptions `json:"detect-zeroes,omitempty"`
+
+	File       *BlockdevOptionsFile     `json:"-"` // driver=file
+	HostDevice *BlockdevOptionsFile     `json:"-"` // driver=host_device
+	LUKS       *BlockdevOptionsLUKS     `json:"-"` // driver=luks
+	NBD        *BlockdevOptionsNbd      `json:"-"` // driver=nbd
+	NullAio    *BlockdevOptionsNull     `json:"-"` // driver=null-aio
+	NullCo     *BlockdevOptionsNull     `json:"-"` // driver=null-co
+	Qcow2      *BlockdevOptionsQcow2    `json:"-"` // driver=qcow2
+	Raw        *BlockdevOptionsRaw      `json:"-"` // driver=raw
+	Throttle   *BlockdevOptionsThrottle `json:"-"` // driver=throttle
+}
+
+func (u BlockdevOptions) MarshalJSON() ([]byte, error) {
+	type base BlockdevOptions
+	var branch any
+	switch u.Driver {
+	case "file":
+		if u.File != nil {
+			branch = u.File
+		}
+	case "host_device":
+		if u.HostDevice != nil {
+			branch = u.HostDevice
+		}
+	case "luks":
+		if u.LUKS != nil {
+			branch = u.LUKS
+		}
+	case "nbd":
+		if u.NBD != nil {
+			branch = u.NBD
+		}
+	case "null-aio":
+		if u.NullAio != nil {
+			branch = u.NullAio
+		}
+	case "null-co":
+		if u.NullCo != nil {
+			branch = u.NullCo
+		}
+	case "qcow2":
+		if u.Qcow2 != nil {
+			branch = u.Qcow2
+		}
+	case "raw":
+		if u.Raw != nil {
+			branch = u.Raw
+		}
+	case "throttle":
+		if u.Throttle != nil {
+			branch = u.Throttle
+		}
+	}
+	return qapiMerge(base(u), branch)
+}
+
+func (u *BlockdevOptions) UnmarshalJSON(data []byte) error {
+	type base BlockdevOptions
+	if err := json.Unmarshal(data, (*base)(u)); err != nil {
+		return err
+	}
+	switch u.Driver {
+	case "file":
+		u.File = &BlockdevOptionsFile{}
+		return json.Unmarshal(data, u.File)
+	case "host_device":
+		u.HostDevice = &BlockdevOptionsFile{}
+		return json.Unmarshal(data, u.HostDevice)
+	case "luks":
+		u.LUKS = &BlockdevOptionsLUKS{}
+		return json.Unmarshal(data, u.LUKS)
+	case "nbd":
+		u.NBD = &BlockdevOptionsNbd{}
+		return json.Unmarshal(data, u.NBD)
+	case "null-aio":
+		u.NullAio = &BlockdevOptionsNull{}
+		return json.Unmarshal(data, u.NullAio)
+	case "null-co":
+		u.NullCo = &BlockdevOptionsNull{}
+		return json.Unmarshal(data, u.NullCo)
+	case "qcow2":
+		u.Qcow2 = &BlockdevOptionsQcow2{}
+		return json.Unmarshal(data, u.Qcow2)
+	case "raw":
+		u.Raw = &BlockdevOptionsRaw{}
+		return json.Unmarshal(data, u.Raw)
+	case "throttle":
+		u.Throttle = &BlockdevOptionsThrottle{}
+		return json.Unmarshal(data, u.Throttle)
+	}
+	return nil
+}
+
+// BlockdevRef: Reference to a block device.
+//
+// QAPI alternate 'BlockdevRef'
+type BlockdevRef struct {
+	Definition *BlockdevOptions
+	Reference  *string
+}
+
+func (a BlockdevRef) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.Definition != nil:
+		return json.Marshal(a.Definition)
+	case a.Reference != nil:
+		return json.Marshal(a.Reference)
+	}
+	return nil, fmt.Errorf("BlockdevRef: no value set")
+}
+
+func (a *BlockdevRef) UnmarshalJSON(data []byte) error {
+	switch qapiKind(data) {
+	case 'o':
+		a.Definition = new(BlockdevOptions)
+		return json.Unmarshal(data, a.Definition)
+	case 's':
+		a.Reference = new(string)
+		return json.Unmarshal(data, a.Reference)
+	}
+	return fmt.Errorf("BlockdevRef: unexpected %s", data)
+}
+
+// BlockdevRefOrNull: Reference to a block device.
+//
+// QAPI alternate 'BlockdevRefOrNull'
+type BlockdevRefOrNull struct {
+	Definition *BlockdevOptions
+	Reference  *string
+	Null       bool // null
+}
+
+func (a BlockdevRefOrNull) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.Definition != nil:
+		return json.Marshal(a.Definition)
+	case a.Reference != nil:
+		return json.Marshal(a.Reference)
+	case a.Null:
+		return []byte("null"), nil
+	}
+	return nil, fmt.Errorf("BlockdevRefOrNull: no value set")
+}
+
+func (a *BlockdevRefOrNull) UnmarshalJSON(data []byte) error {
+	switch qapiKind(data) {
+	case 'o':
+		a.Definition = new(BlockdevOptions)
+		return json.Unmarshal(data, a.Definition)
+	case 's':
+		a.Reference = new(string)
+		return json.Unmarshal(data, a.Reference)
+	case 'n':
+		a.Null = true
+		return nil
+	}
+	return fmt.Errorf("BlockdevRefOrNull: unexpected %s", data)
+}
+
+// NewImageMode: An enumeration that tells QEMU how to set the backing file path in a new image file.
+//
+// QAPI enum 'NewImageMode'
+type NewImageMode string
+
+const (
+	// QEMU should look for an existing image file.
+	NewImageModeExisting NewImageMode = "existing"
+	// QEMU should create a new image with absolute paths for the backing file.  If there is no backing file available, the new image will not be backed either.
+	NewImageModeAbsolutePaths NewImageMode = "absolute-paths"
+)
+
+// BlockdevSnapshotSync: Either @device or @node-name must be set but not both.
+//
+// QAPI struct 'BlockdevSnapshotSync'
+type BlockdevSnapshotSync struct {
+	// the name of the device to take a snapshot of.
+	Device string `json:"device,omitempty"`
+	// graph node name to generate the snapshot from (Since 2.0)
+	NodeName string `json:"node-name,omitempty"`
+	// the target of the new overlay image.  If the file exists, or if it is a device, the overlay will be created in the existing file/device.  Otherwise, a new file will be created.
+	SnapshotFile string `json:"snapshot-file"`
+	// the graph node name of the new image (Since 2.0)
+	SnapshotNodeName string `json:"snapshot-node-name,omitempty"`
+	// the format of the overlay image, default is 'qcow2'.
+	Format string `json:"format,omitempty"`
+	// whether and how QEMU should create a new image, default is 'absolute-paths'.
+	Mode NewImageMode `json:"mode,omitempty"`
+}
+
+// MirrorSyncMode: An enumeration of possible behaviors for the initial synchronization phase of storage mirroring.
+//
+// QAPI enum 'MirrorSyncMode'
+type MirrorSyncMode string
+
+const (
+	// copies data in the topmost image to the destination
+	MirrorSyncModeTop MirrorSyncMode = "top"
+	// copies data from all images to the destination
+	MirrorSyncModeFull MirrorSyncMode = "full"
+	// only copy data written from now on
+	MirrorSyncModeNone MirrorSyncMode = "none"
+	// only copy data described by the dirty bitmap. (since: 2.4)
+	MirrorSyncModeIncremental MirrorSyncMode = "incremental"
+	// only copy data described by the dirty bitmap.  (since: 4.2) Behavior on completion is determined by the BitmapSyncMode.
+	MirrorSyncModeBitmap MirrorSyncMode = "bitmap"
+)
+
+// BitmapSyncMode: An enumeration of possible behaviors for the synchronization of a bitmap when used for data copy operations.
+//
+// QAPI enum 'BitmapSyncMode'
+type BitmapSyncMode string
+
+const (
+	// The bitmap is only synced when the operation is successful.  This is the behavior always used for 'INCREMENTAL' backups.
+	BitmapSyncModeOnSuccess BitmapSyncMode = "on-success"
+	// The bitmap is never synchronized with the operation, and is treated solely as a read-only manifest of blocks to copy.
+	BitmapSyncModeNever BitmapSyncMode = "never"
+	// The bitmap is always synchronized with the operation, regardless of whether or not the operation was successful.
+	BitmapSyncModeAlways BitmapSyncMode = "always"
+)
+
+// QAPI struct 'BlockdevBackup'
+type BlockdevBackup struct {
+	// identifier for the newly-created block job.  If omitted, the device name will be used.  (Since 2.7)
+	JobID string `json:"job-id,omitempty"`
+	// the device name or node-name of a root node which should be copied.
+	Device string `json:"device"`
+	// what parts of the disk image should be copied to the destination (all the disk, only the sectors allocated in the topmost image, from a dirty bitmap, or only new I/O).
+	Sync MirrorSyncMode `json:"sync"`
+	// the maximum speed, in bytes per second.  The default is 0, for unlimited.
+	Speed *int64 `json:"speed,omitempty"`
+	// The name of a dirty bitmap to use.  Must be present if sync is "bitmap" or "incremental".  Can be present if sync is "full" or "top".  Must not be present otherwise.  (Since 2.4 (drive-backup), 3.1 (blockdev-backup))
+	Bitmap string `json:"bitmap,omitempty"`
+	// Specifies the type of data the bitmap should contain after the operation concludes.  Must be present if a bitmap was provided, Must NOT be present otherwise.  (Since 4.2)
+	BitmapMode BitmapSyncMode `json:"bitmap-mode,omitempty"`
+	// true to compress data, if the target format supports it. (default: false) (since 2.8)
+	Compress *bool `json:"compress,omitempty"`
+	// When false, this job will wait in a PENDING state after it has finished its work, waiting for block-job-finalize before making any block graph changes.  When true, this job will automatically perform its abort or commit actions.  Defaults to true.  (Since 2.12)
+	AutoFinalize *bool `json:"auto-finalize,omitempty"`
+	// When false, this job will wait in a CONCLUDED state after it has completely ceased all work, and awaits block-job-dismiss.  When true, this job will automatically disappear from the query list without user intervention. Defaults to true.  (Since 2.12)
+	AutoDismiss *bool `json:"auto-dismiss,omitempty"`
+	// the device name or node-name of the backup target node.
+	Target string `json:"target"`
+}
+
+// BlockdevOnError: An enumeration of possible behaviors for errors on I/O operations. The exact meaning depends on whether the I/O was initiated by a guest or by a block job
+//
+// QAPI enum 'BlockdevOnError'
+type BlockdevOnError string
+
+const (
+	// for guest operations, report the error to the guest; for jobs, cancel the job
+	BlockdevOnErrorReport BlockdevOnError = "report"
+	// ignore the error, only report a QMP event (BLOCK_IO_ERROR or BLOCK_JOB_ERROR).  The backup, mirror and commit block jobs retry the failing request later and may still complete successfully.  The stream block job continues to stream and will complete with an error.
+	BlockdevOnErrorIgnore BlockdevOnError = "ignore"
+	// same as @stop on ENOSPC, same as @report otherwise.
+	BlockdevOnErrorEnospc BlockdevOnError = "enospc"
+	// for guest operations, stop the virtual machine; for jobs, pause the job
+	BlockdevOnErrorStop BlockdevOnError = "stop"
+	// inherit the error handling policy of the backend (since: 2.7)
+	BlockdevOnErrorAuto BlockdevOnError = "auto"
+)
+
+// MirrorCopyMode: An enumeration whose values tell the mirror block job when to trigger writes to the target.
+//
+// QAPI enum 'MirrorCopyMode'
+type MirrorCopyMode string
+
+const (
+	// copy data in background only.
+	MirrorCopyModeBackground MirrorCopyMode = "background"
+	// when data is written to the source, write it (synchronously) to the target as well.  In addition, data is copied in background just like in @background mode.
+	MirrorCopyModeWriteBlocking MirrorCopyMode = "write-blocking"
+)
+
+// QAPI struct 'BlockDirtyBitmap'
+type BlockDirtyBitmap struct {
+	// name of device/node which the bitmap is tracking
+	Node string `json:"node"`
+	// name of the dirty bitmap
+	Name string `json:"name"`
+}
+
+// QAPI struct 'BlockDirtyBitmapAdd'
+type BlockDirtyBitmapAdd struct {
+	// name of device/node which the bitmap is tracking
+	Node string `json:"node"`
+	// name of the dirty bitmap (must be less than 1024 bytes)
+	Name string `json:"name"`
+	// the bitmap granularity, default is 64k for block-dirty-bitmap-add
+	Granularity *uint32 `json:"granularity,omitempty"`
+	// the bitmap is persistent, i.e. it will be saved to the corresponding block device image file on its close.  For now only Qcow2 disks support persistent bitmaps.  Default is false for block-dirty-bitmap-add.  (Since: 2.10)
+	Persistent *bool `json:"persistent,omitempty"`
+	// the bitmap is created in the disabled state, which means that it will not track drive changes.  The bitmap may be enabled with block-dirty-bitmap-enable.  Default is false.  (Since: 4.0)
+	Disabled *bool `json:"disabled,omitempty"`
+}
+
+// QAPI struct 'BlockDirtyBitmapMerge'
+type BlockDirtyBitmapMerge struct {
+	// name of device/node which the target bitmap is tracking
+	Node string `json:"node"`
+	// name of the destination dirty bitmap
+	Target string `json:"target"`
+	// name(s) of the source dirty bitmap(s) at @node and/or fully specified BlockDirtyBitmap elements.  The latter are supported since 4.1.
+	Bitmaps []string `json:"bitmaps"`
+}
+
+// BlockDeviceIoStatus: An enumeration of block device I/O status.
+//
+// QAPI enum 'BlockDeviceIoStatus'
+type BlockDeviceIoStatus string
+
+const (
+	// The last I/O operation has succeeded
+	BlockDeviceIoStatusOk BlockDeviceIoStatus = "ok"
+	// The last I/O operation has failed
+	BlockDeviceIoStatusFailed BlockDeviceIoStatus = "failed"
+	// The last I/O operation has failed due to a no-space condition
+	BlockDeviceIoStatusNospace BlockDeviceIoStatus = "nospace"
+)
+
+// BlockdevCacheInfo: Cache mode information for a block device
+//
+// QAPI struct 'BlockdevCacheInfo'
+type BlockdevCacheInfo struct {
+	// true if writeback mode is enabled
+	Writeback bool `json:"writeback"`
+	// true if the host page cache is bypassed (O_DIRECT)
+	Direct bool `json:"direct"`
+	// true if flush requests are ignored for the device
+	NoFlush bool `json:"no-flush"`
+}
+
+// BlockDeviceInfo: Information about the backing device for a block device.
+//
+// QAPI struct 'BlockDeviceInfo'
+type BlockDeviceInfo struct {
+	// the filename of the backing device
+	File string `json:"file"`
+	// the name of the block driver node (Since 2.0)
+	NodeName string `json:"node-name,omitempty"`
+	// true if the backing device was open read-only
+	Ro bool `json:"ro"`
+	// the name of the block format used to open the backing device.
+	Drv string `json:"drv"`
+	// the name of the backing file (for copy-on-write)
+	BackingFile string `json:"backing_file,omitempty"`
+	// number of files in the backing file chain (since: 1.2)
+	BackingFileDepth int64 `json:"backing_file_depth"`
+	// true if the backing device is encrypted
+	Encrypted bool `json:"encrypted"`
+	// detect and optimize zero writes (Since 2.1)
+	DetectZeroes BlockdevDetectZeroesOptions `json:"detect_zeroes"`
+	// total throughput limit in bytes per second is specified
+	BPS int64 `json:"bps"`
+	// read throughput limit in bytes per second is specified
+	BPSRd int64 `json:"bps_rd"`
+	// write throughput limit in bytes per second is specified
+	BPSWr int64 `json:"bps_wr"`
+	// total I/O operations per second is specified
+	IOPS int64 `json:"iops"`
+	// read I/O operations per second is specified
+	IOPSRd int64 `json:"iops_rd"`
+	// write I/O operations per second is specified
+	IOPSWr int64 `json:"iops_wr"`
+	// total throughput limit during bursts, in bytes (Since 1.7)
+	BPSMax *int64 `json:"bps_max,omitempty"`
+	// read throughput limit during bursts, in bytes (Since 1.7)
+	BPSRdMax *int64 `json:"bps_rd_max,omitempty"`
+	// write throughput limit during bursts, in bytes (Since 1.7)
+	BPSWrMax *int64 `json:"bps_wr_max,omitempty"`
+	// total I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSMax *int64 `json:"iops_max,omitempty"`
+	// read I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSRdMax *int64 `json:"iops_rd_max,omitempty"`
+	// write I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSWrMax *int64 `json:"iops_wr_max,omitempty"`
+	// maximum length of the @bps_max burst period, in seconds.  (Since 2.6)
+	BPSMaxLength *int64 `json:"bps_max_length,omitempty"`
+	// maximum length of the @bps_rd_max burst period, in seconds.  (Since 2.6)
+	BPSRdMaxLength *int64 `json:"bps_rd_max_length,omitempty"`
+	// maximum length of the @bps_wr_max burst period, in seconds.  (Since 2.6)
+	BPSWrMaxLength *int64 `json:"bps_wr_max_length,omitempty"`
+	// maximum length of the @iops burst period, in seconds.  (Since 2.6)
+	IOPSMaxLength *int64 `json:"iops_max_length,omitempty"`
+	// maximum length of the @iops_rd_max burst period, in seconds.  (Since 2.6)
+	IOPSRdMaxLength *int64 `json:"iops_rd_max_length,omitempty"`
+	// maximum length of the @iops_wr_max burst period, in seconds.  (Since 2.6)
+	IOPSWrMaxLength *int64 `json:"iops_wr_max_length,omitempty"`
+	// an I/O size in bytes (Since 1.7)
+	IOPSSize *int64 `json:"iops_size,omitempty"`
+	// throttle group name (Since 2.4)
+	Group string `json:"group,omitempty"`
+	// the cache mode used for the block device (since: 2.3)
+	Cache *BlockdevCacheInfo `json:"cache"`
+	// configured write threshold for the device.  0 if disabled.  (Since 2.3)
+	WriteThreshold int64 `json:"write_threshold"`
+}
+
+// BlockInfo: Block device information.  This structure describes a virtual device and the backing device associated with it.
+//
+// QAPI struct 'BlockInfo'
+type BlockInfo struct {
+	// The device name associated with the virtual device.
+	Device string `json:"device"`
+	// The qdev ID, or if no ID is assigned, the QOM path of the block device.  (since 2.10)
+	Qdev string `json:"qdev,omitempty"`
+	// This field is returned only for compatibility reasons, it should not be used (always returns 'unknown')
+	Type string `json:"type"`
+	// True if the device supports removable media.
+	Removable bool `json:"removable"`
+	// True if the guest has locked this device from having its media removed
+	Locked bool `json:"locked"`
+	// the backing device (only present if a disk is inserted)
+	Inserted *BlockDeviceInfo `json:"inserted,omitempty"`
+	// True if the device's tray is open (only present if it has a tray)
+	TrayOpen *bool `json:"tray_open,omitempty"`
+	// the I/O status of the device (only present if the device supports it and the VM is configured to stop on errors)
+	IOStatus BlockDeviceIoStatus `json:"io-status,omitempty"`
+}
+
+// BlockIOThrottle: A set of parameters describing block throttling.
+//
+// QAPI struct 'BlockIOThrottle'
+type BlockIOThrottle struct {
+	// Block device name
+	Device string `json:"device,omitempty"`
+	// The name or QOM path of the guest device (since: 2.8)
+	ID string `json:"id,omitempty"`
+	// total throughput limit in bytes per second
+	BPS int64 `json:"bps"`
+	// read throughput limit in bytes per second
+	BPSRd int64 `json:"bps_rd"`
+	// write throughput limit in bytes per second
+	BPSWr int64 `json:"bps_wr"`
+	// total I/O operations per second
+	IOPS int64 `json:"iops"`
+	// read I/O operations per second
+	IOPSRd int64 `json:"iops_rd"`
+	// write I/O operations per second
+	IOPSWr int64 `json:"iops_wr"`
+	// total throughput limit during bursts, in bytes (Since 1.7)
+	BPSMax *int64 `json:"bps_max,omitempty"`
+	// read throughput limit during bursts, in bytes (Since 1.7)
+	BPSRdMax *int64 `json:"bps_rd_max,omitempty"`
+	// write throughput limit during bursts, in bytes (Since 1.7)
+	BPSWrMax *int64 `json:"bps_wr_max,omitempty"`
+	// total I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSMax *int64 `json:"iops_max,omitempty"`
+	// read I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSRdMax *int64 `json:"iops_rd_max,omitempty"`
+	// write I/O operations per second during bursts, in bytes (Since 1.7)
+	IOPSWrMax *int64 `json:"iops_wr_max,omitempty"`
+	// maximum length of the @bps_max burst period, in seconds.  It must only be set if @bps_max is set as well. Defaults to 1.  (Since 2.6)
+	BPSMaxLength *int64 `json:"bps_max_length,omitempty"`
+	// maximum length of the @bps_rd_max burst period, in seconds.  It must only be set if @bps_rd_max is set as well. Defaults to 1.  (Since 2.6)
+	BPSRdMaxLength *int64 `json:"bps_rd_max_length,omitempty"`
+	// maximum length of the @bps_wr_max burst period, in seconds.  It must only be set if @bps_wr_max is set as well. Defaults to 1.  (Since 2.6)
+	BPSWrMaxLength *int64 `json:"bps_wr_max_length,omitempty"`
+	// maximum length of the @iops burst period, in seconds.  It must only be set if @iops_max is set as well. Defaults to 1.  (Since 2.6)
+	IOPSMaxLength *int64 `json:"iops_max_length,omitempty"`
+	// maximum length of the @iops_rd_max burst period, in seconds.  It must only be set if @iops_rd_max is set as well.  Defaults to 1.  (Since 2.6)
+	IOPSRdMaxLength *int64 `json:"iops_rd_max_length,omitempty"`
+	// maximum length of the @iops_wr_max burst period, in seconds.  It must only be set if @iops_wr_max is set as well.  Defaults to 1.  (Since 2.6)
+	IOPSWrMaxLength *int64 `json:"iops_wr_max_length,omitempty"`
+	// an I/O size in bytes (Since 1.7)
+	IOPSSize *int64 `json:"iops_size,omitempty"`
+	// throttle group name (Since 2.4)
+	Group string `json:"group,omitempty"`
+}
+
+// BlockJobInfo: Information about a long-running block device operation.
+//
+// QAPI struct 'BlockJobInfo'
+type BlockJobInfo struct {
+	// the job type ('stream' for image streaming)
+	Type string `json:"type"`
+	// The job identifier.  Originally the device name but other values are allowed since QEMU 2.7
+	Device string `json:"device"`
+	// Estimated offset value at the completion of the job.  This value can arbitrarily change while the job is running, in both directions.
+	Len int64 `json:"len"`
+	// Progress made until now.  The unit is arbitrary and the value can only meaningfully be used for the ratio of offset to len.  The value is monotonically increasing.
+	Offset int64 `json:"offset"`
+	// false if the job is known to be in a quiescent state, with no pending I/O.  (Since 1.3)
+	Busy bool `json:"busy"`
+	// whether the job is paused or, if busy is true, will pause itself as soon as possible.  (Since 1.3)
+	Paused bool `json:"paused"`
+	// the rate limit, bytes per second
+	Speed int64 `json:"speed"`
+	// the status of the job (since 1.3)
+	IOStatus BlockDeviceIoStatus `json:"io-status"`
+	// true if the job may be completed (since 2.2)
+	Ready bool `json:"ready"`
+	// Current job state/status (since 2.12)
+	Status JobStatus `json:"status"`
+	// Job will finalize itself when PENDING, moving to the CONCLUDED state.  (since 2.12)
+	AutoFinalize bool `json:"auto-finalize"`
+	// Job will dismiss itself when CONCLUDED, moving to the NULL state and disappearing from the query list.  (since 2.12)
+	AutoDismiss bool `json:"auto-dismiss"`
+	// Error information if the job did not complete successfully. Not set if the job completed successfully.  (since 2.12.1)
+	Error string `json:"error,omitempty"`
+}
+
+// BlockExportType: An enumeration of block export types
+//
+// QAPI enum 'BlockExportType'
+type BlockExportType string
+
+const (
+	// NBD export
+	BlockExportTypeNBD BlockExportType = "nbd"
+)
+
+// BlockExportOptionsNbd: An NBD block export (distinct options used in the NBD branch of block-export-add).
+//
+// QAPI struct 'BlockExportOptionsNbd'
+type BlockExportOptionsNbd struct {
+	// Export name.  If unspecified, the device parameter is used as the export name.  (Since 2.12)
+	Name string `json:"name,omitempty"`
+	// Free-form description of the export, up to 4096 bytes.  (Since 5.0)
+	Description string `json:"description,omitempty"`
+	// Also export each of the named dirty bitmaps reachable from the export node, as "qemu:dirty-bitmap:BITMAP" metadata contexts. The bitmaps must not be enabled while the export is read-only.
+	Bitmaps []string `json:"bitmaps,omitempty"`
+	// Also export the allocation depth map for the export node, as the "qemu:allocation-depth" metadata context. (since 5.2)
+	AllocationDepth *bool `json:"allocation-depth,omitempty"`
+}
+
+// BlockExportOptions: Describes a block export, i.e. how single node should be exported on an external interface.
+//
+// QAPI union 'BlockExportOptions'
+//
+// only the field matching Type is sent
+type BlockExportOptions struct {
+	// Block export type
+	Type BlockExportType `json:"type"`
+	// A unique identifier for the block export (across all export types)
+	ID string `json:"id"`
+	// The node name of the block node to be exported
+	NodeName string `json:"node-name"`
+	// True if clients should be able to write to the export (default false)
+	Writable *bool `json:"writable,omitempty"`
+	// If true, caches are flushed after every write request to the export before completion is signalled.  (since: 5.2; default: false)
+	Writethrough *bool `json:"writethrough,omitempty"`
+
+	NBD *BlockExportOptionsNbd `json:"-"` // type=nbd
+}
+
+func (u BlockExportOptions) MarshalJSON() ([]byte, error) {
+	type base BlockExportOptions
+	var branch any
+	switch u.Type {
+	case "nbd":
+		if u.NBD != nil {
+			branch = u.NBD
+		}
+	}
+	return qapiMerge(base(u), branch)
+}
+
+func (u *BlockExportOptions) UnmarshalJSON(data []byte) error {
+	type base BlockExportOptions
+	if err := json.Unmarshal(data, (*base)(u)); err != nil {
+		return err
+	}
+	switch u.Type {
+	case "nbd":
+		u.NBD = &BlockExportOptionsNbd{}
+		return json.Unmarshal(data, u.NBD)
+	}
+	return nil
+}
+
+// BlockExportRemoveMode: Mode for removing a block export.
+//
+// QAPI enum 'BlockExportRemoveMode'
+type BlockExportRemoveMode string
+
+const (
+	// Remove export if there are no existing connections, fail otherwise.
+	BlockExportRemoveModeSafe BlockExportRemoveMode = "safe"
+	// Drop all connections immediately and remove export.
+	BlockExportRemoveModeHard BlockExportRemoveMode = "hard"
+)
+
+// BlockExportInfo: Information about a single block export.
+//
+// QAPI struct 'BlockExportInfo'
+type BlockExportInfo struct {
+	// The unique identifier for the block export
+	ID string `json:"id"`
+	// The block export type
+	Type BlockExportType `json:"type"`
+	// The node name of the block node that is exported
+	NodeName string `json:"node-name"`
+	// True if the export is shutting down (e.g. after a block-export-del command, but before the shutdown has completed)
+	ShuttingDown bool `json:"shutting-down"`
+}
+
+// ActionCompletionMode: An enumeration of Transactional completion modes.
+//
+// QAPI enum 'ActionCompletionMode'
+type ActionCompletionMode string
+
+const (
+	// Do not attempt to cancel any other Actions if any Actions fail after the Transaction request succeeds.  All Actions that can complete successfully will do so without waiting on others.  This is the default.
+	ActionCompletionModeIndividual ActionCompletionMode = "individual"
+	// If any Action fails after the Transaction succeeds, cancel all Actions.  Actions do not complete until all Actions are ready to complete.  May be rejected by Actions that do not support this completion mode.
+	ActionCompletionModeGrouped ActionCompletionMode = "grouped"
+)
+
+// QAPI enum 'TransactionActionKind'
+type TransactionActionKind string
+
+const (
+	// Since 1.6
+	TransactionActionKindAbort TransactionActionKind = "abort"
+	// Since 2.5
+	TransactionActionKindBlockDirtyBitmapAdd TransactionActionKind = "block-dirty-bitmap-add"
+	// Since 4.2
+	TransactionActionKindBlockDirtyBitmapRemove TransactionActionKind = "block-dirty-bitmap-remove"
+	// Since 2.5
+	TransactionActionKindBlockDirtyBitmapClear TransactionActionKind = "block-dirty-bitmap-clear"
+	// Since 4.0
+	TransactionActionKindBlockDirtyBitmapEnable TransactionActionKind = "block-dirty-bitmap-enable"
+	// Since 4.0
+	TransactionActionKindBlockDirtyBitmapDisable TransactionActionKind = "block-dirty-bitmap-disable"
+	// Since 4.0
+	TransactionActionKindBlockDirtyBitmapMerge TransactionActionKind = "block-dirty-bitmap-merge"
+	// Since 2.3
+	TransactionActionKindBlockdevBackup TransactionActionKind = "blockdev-backup"
+	// Since 2.5
+	TransactionActionKindBlockdevSnapshot TransactionActionKind = "blockdev-snapshot"
+	// Since 1.7
+	TransactionActionKindBlockdevSnapshotInternalSync TransactionActionKind = "blockdev-snapshot-internal-sync"
+	// since 1.1
+	TransactionActionKindBlockdevSnapshotSync TransactionActionKind = "blockdev-snapshot-sync"
+	// Since 1.6
+	TransactionActionKindDriveBackup TransactionActionKind = "drive-backup"
+)
+
+// QAPI struct 'BlockDirtyBitmapAddWrapper'
+type BlockDirtyBitmapAddWrapper struct {
+	// Information about the transaction action to perform.
+	Data *BlockDirtyBitmapAdd `json:"data"`
+}
+
+// QAPI struct 'BlockDirtyBitmapWrapper'
+type BlockDirtyBitmapWrapper struct {
+	// Information about the transaction action to perform.
+	Data *BlockDirtyBitmap `json:"data"`
+}
+
+// QAPI struct 'BlockDirtyBitmapMergeWrapper'
+type BlockDirtyBitmapMergeWrapper struct {
+	// Information about the transaction action to perform.
+	Data *BlockDirtyBitmapMerge `json:"data"`
+}
+
+// QAPI struct 'BlockdevBackupWrapper'
+type BlockdevBackupWrapper struct {
+	// Information about the transaction action to perform.
+	Data *BlockdevBackup `json:"data"`
+}
+
+// TransactionAction: A discriminated record of operations that can be performed with
+//
+// QAPI union 'TransactionAction'
+//
+// only the field matching Type is sent
+type TransactionAction struct {
+	// the operation to be performed
+	Type TransactionActionKind `json:"type"`
+
+	BlockDirtyBitmapAdd     *BlockDirtyBitmapAddWrapper   `json:"-"` // type=block-dirty-bitmap-add
+	BlockDirtyBitmapRemove  *BlockDirtyBitmapWrapper      `json:"-"` // type=block-dirty-bitmap-remove
+	BlockDirtyBitmapClear   *BlockDirtyBitmapWrapper      `json:"-"` // type=block-dirty-bitmap-clear
+	BlockDirtyBitmapEnable  *BlockDirtyBitmapWrapper      `json:"-"` // type=block-dirty-bitmap-enable
+	BlockDirtyBitmapDisable *BlockDirtyBitmapWrapper      `json:"-"` // type=block-dirty-bitmap-disable
+	BlockDirtyBitmapMerge   *BlockDirtyBitmapMergeWrapper `json:"-"` // type=block-dirty-bitmap-merge
+	BlockdevBackup          *BlockdevBackupWrapper        `json:"-"` // type=blockdev-backup
+}
+
+func (u TransactionAction) MarshalJSON() ([]byte, error) {
+	type base TransactionAction
+	var branch any
+	switch u.Type {
+	case "block-dirty-bitmap-add":
+		if u.BlockDirtyBitmapAdd != nil {
+			branch = u.BlockDirtyBitmapAdd
+		}
+	case "block-dirty-bitmap-remove":
+		if u.BlockDirtyBitmapRemove != nil {
+			branch = u.BlockDirtyBitmapRemove
+		}
+	case "block-dirty-bitmap-clear":
+		if u.BlockDirtyBitmapClear != nil {
+			branch = u.BlockDirtyBitmapClear
+		}
+	case "block-dirty-bitmap-enable":
+		if u.BlockDirtyBitmapEnable != nil {
+			branch = u.BlockDirtyBitmapEnable
+		}
+	case "block-dirty-bitmap-disable":
+		if u.BlockDirtyBitmapDisable != nil {
+			branch = u.BlockDirtyBitmapDisable
+		}
+	case "block-dirty-bitmap-merge":
+		if u.BlockDirtyBitmapMerge != nil {
+			branch = u.BlockDirtyBitmapMerge
+		}
+	case "blockdev-backup":
+		if u.BlockdevBackup != nil {
+			branch = u.BlockdevBackup
+		}
+	}
+	return qapiMerge(base(u), branch)
+}
+
+func (u *TransactionAction) UnmarshalJSON(data []byte) error {
+	type base TransactionAction
+	if err := json.Unmarshal(data, (*base)(u)); err != nil {
+		return err
+	}
+	switch u.Type {
+	case "block-dirty-bitmap-add":
+		u.BlockDirtyBitmapAdd = &BlockDirtyBitmapAddWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapAdd)
+	case "block-dirty-bitmap-remove":
+		u.BlockDirtyBitmapRemove = &BlockDirtyBitmapWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapRemove)
+	case "block-dirty-bitmap-clear":
+		u.BlockDirtyBitmapClear = &BlockDirtyBitmapWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapClear)
+	case "block-dirty-bitmap-enable":
+		u.BlockDirtyBitmapEnable = &BlockDirtyBitmapWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapEnable)
+	case "block-dirty-bitmap-disable":
+		u.BlockDirtyBitmapDisable = &BlockDirtyBitmapWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapDisable)
+	case "block-dirty-bitmap-merge":
+		u.BlockDirtyBitmapMerge = &BlockDirtyBitmapMergeWrapper{}
+		return json.Unmarshal(data, u.BlockDirtyBitmapMerge)
+	case "blockdev-backup":
+		u.BlockdevBackup = &BlockdevBackupWrapper{}
+		return json.Unmarshal(data, u.BlockdevBackup)
+	}
+	return nil
+}
+
+// TransactionProperties: Optional arguments to modify the behavior of a Transaction.
+//
+// QAPI struct 'TransactionProperties'
+type TransactionProperties struct {
+	// Controls how jobs launched asynchronously by Actions will complete or fail as a group.  See
+	CompletionMode ActionCompletionMode `json:"completion-mode,omitempty"`
+}
+
+// MultiFDCompression: An enumeration of multifd compression methods.
+//
+// QAPI enum 'MultiFDCompression'
+type MultiFDCompression string
+
+const (
+	// no compression.
+	MultiFDCompressionNone MultiFDCompression = "none"
+	// use zlib compression method.
+	MultiFDCompressionZlib MultiFDCompression = "zlib"
+	// use zstd compression method.
+	MultiFDCompressionZstd MultiFDCompression = "zstd"
+	// use qpl compression method.  Query Processing Library(qpl) is based on the deflate compression algorithm and use the Intel In-Memory Analytics Accelerator(IAA) accelerated compression and decompression.  (Since 9.1)
+	MultiFDCompressionQpl MultiFDCompression = "qpl"
+	// use UADK library compression method.  (Since 9.1)
+	MultiFDCompressionUadk MultiFDCompression = "uadk"
+)
+
+// QAPI enum 'MigMode'
+type MigMode string
+
+const (
+	// the original form of migration.  (since 8.2)
+	MigModeNormal MigMode = "normal"
+	// The migrate command stops the VM and saves state to the URI.  After quitting QEMU, the user resumes by running QEMU -incoming.  (since 8.2)
+	MigModeCprReboot MigMode = "cpr-reboot"
+)
+
+// QAPI struct 'MigrateSetParameters'
+type MigrateSetParameters struct {
+	// Initial delay (in milliseconds) before sending the first announce (Since 4.0)
+	AnnounceInitial *uint64 `json:"announce-initial,omitempty"`
+	// Maximum delay (in milliseconds) between packets in the announcement (Since 4.0)
+	AnnounceMax *uint64 `json:"announce-max,omitempty"`
+	// Number of self-announce packets sent after migration (Since 4.0)
+	AnnounceRounds *uint64 `json:"announce-rounds,omitempty"`
+	// Increase in delay (in milliseconds) between subsequent packets in the announcement (Since 4.0)
+	AnnounceStep *uint64 `json:"announce-step,omitempty"`
+	// The ratio of bytes_dirty_period and bytes_xfer_period to trigger throttling.  It is expressed as percentage.  The default value is 50.  (Since 5.0)
+	ThrottleTriggerThreshold *uint8 `json:"throttle-trigger-threshold,omitempty"`
+	// Initial percentage of time guest cpus are throttled when migration auto-converge is activated.  The default value is 20.  (Since 2.7)
+	CPUThrottleInitial *uint8 `json:"cpu-throttle-initial,omitempty"`
+	// throttle percentage increase each time auto-converge detects that migration is not making progress. The default value is 10.  (Since 2.7)
+	CPUThrottleIncrement *uint8 `json:"cpu-throttle-increment,omitempty"`
+	// Make CPU throttling slower at tail stage. (Since 5.1)
+	CPUThrottleTailslow *bool `json:"cpu-throttle-tailslow,omitempty"`
+	// ID of the 'tls-creds' object that provides credentials for establishing a TLS connection over the migration data channel.  Setting this to an empty string disables TLS. (Since 2.7)
+	TLSCreds *StrOrNull `json:"tls-creds,omitempty"`
+	// migration target's hostname for validating the server's x509 certificate identity.  (Since 2.7)
+	TLSHostname *StrOrNull `json:"tls-hostname,omitempty"`
+	// ID of the 'authz' object subclass that provides access control checking of the TLS x509 certificate distinguished name. (Since 4.0)
+	TLSAuthz *StrOrNull `json:"tls-authz,omitempty"`
+	// maximum speed for migration, in bytes per second. (Since 2.8)
+	MaxBandwidth *uint64 `json:"max-bandwidth,omitempty"`
+	// to set the available bandwidth that migration can use during switchover phase.  (Since 8.2)
+	AvailSwitchoverBandwidth *uint64 `json:"avail-switchover-bandwidth,omitempty"`
+	// set maximum tolerated downtime for migration. maximum downtime in milliseconds (Since 2.8)
+	DowntimeLimit *uint64 `json:"downtime-limit,omitempty"`
+	// The delay time (in ms) between two COLO checkpoints in periodic mode.  (Since 2.8)
+	XCheckpointDelay *uint32 `json:"x-checkpoint-delay,omitempty"`
+	// Number of channels used to migrate data in parallel.  The default value is 2 (since 4.0)
+	MultifdChannels *uint8 `json:"multifd-channels,omitempty"`
+	// cache size to be used by XBZRLE migration. (Since 2.11)
+	XbzrleCacheSize *uint64 `json:"xbzrle-cache-size,omitempty"`
+	// Background transfer bandwidth during postcopy.  Defaults to 0 (unlimited).  In bytes per second. (Since 3.0)
+	MaxPostcopyBandwidth *uint64 `json:"max-postcopy-bandwidth,omitempty"`
+	// maximum cpu throttle percentage.  The default value is 99.  (Since 3.1)
+	MaxCPUThrottle *uint8 `json:"max-cpu-throttle,omitempty"`
+	// Which compression method to use.  Defaults to none.  (Since 5.0)
+	MultifdCompression MultiFDCompression `json:"multifd-compression,omitempty"`
+	// Set the compression level to be used in live migration, the compression level is an integer between 0 and 9. (Since 5.0)
+	MultifdZlibLevel *uint8 `json:"multifd-zlib-level,omitempty"`
+	// Set the compression level to be used in live migration, the compression level is an integer between 0 and 20. (Since 5.0)
+	MultifdZstdLevel *uint8 `json:"multifd-zstd-level,omitempty"`
+	// Migration mode.  See description in @MigMode.  Default is 'normal'.  (Since 8.2)
+	Mode MigMode `json:"mode,omitempty"`
+}
+
+// BlockDirtyBitmapAdd: Create a dirty bitmap with a name on the node, and start tracking the writes.
+//
+// QAPI command 'block-dirty-bitmap-add'
+func (c *Client) BlockDirtyBitmapAdd(ctx context.Context, args *BlockDirtyBitmapAdd) error {
+	_, err := c.Execute(ctx, "block-dirty-bitmap-add", qapiArgs(args))
+	return err
+}
+
+// BlockDirtyBitmapDisable: Disables a dirty bitmap so that it will stop tracking disk changes.
+//
+// QAPI command 'block-dirty-bitmap-disable'
+func (c *Client) BlockDirtyBitmapDisable(ctx context.Context, args *BlockDirtyBitmap) error {
+	_, err := c.Execute(ctx, "block-dirty-bitmap-disable", qapiArgs(args))
+	return err
+}
+
+// BlockDirtyBitmapMerge: Merge dirty bitmaps listed in @bitmaps to the @target dirty bitmap. Dirty bitmaps in @bitmaps will be unchanged, except if it also appears as the @target bitmap.  Any bits already set in @target will still be set after the merge, i.e., this operation does not clear the target.  On error, @target is unchanged.
+//
+// QAPI command 'block-dirty-bitmap-merge'
+func (c *Client) BlockDirtyBitmapMerge(ctx context.Context, args *BlockDirtyBitmapMerge) error {
+	_, err := c.Execute(ctx, "block-dirty-bitmap-merge", qapiArgs(args))
+	return err
+}
+
+// BlockDirtyBitmapRemove: Stop write tracking and remove the dirty bitmap that was created with block-dirty-bitmap-add.  If the bitmap is persistent, remove it from its storage too.
+//
+// QAPI command 'block-dirty-bitmap-remove'
+func (c *Client) BlockDirtyBitmapRemove(ctx context.Context, args *BlockDirtyBitmap) error {
+	_, err := c.Execute(ctx, "block-dirty-bitmap-remove", qapiArgs(args))
+	return err
+}
+
+// BlockExportAdd: Creates a new block export.
+//
+// QAPI command 'block-export-add'
+func (c *Client) BlockExportAdd(ctx context.Context, args *BlockExportOptions) error {
+	_, err := c.Execute(ctx, "block-export-add", qapiArgs(args))
+	return err
+}
+
+// arguments of BlockExportDel
+type BlockExportDelArguments struct {
+	// Block export id.
+	ID string `json:"id"`
+	// Mode of command operation.  See BlockExportRemoveMode description.  Default is 'safe'.
+	Mode BlockExportRemoveMode `json:"mode,omitempty"`
+}
+
+// BlockExportDel: Request to remove a block export.  This drops the user's reference to the export, but the export may still stay around after this command returns until the shutdown of the export has completed.
+//
+// QAPI command 'block-export-del'
+func (c *Client) BlockExportDel(ctx context.Context, args *BlockExportDelArguments) error {
+	_, err := c.Execute(ctx, "block-export-del", qapiArgs(args))
+	return err
+}
+
+// arguments of BlockJobSetSpeed
+type BlockJobSetSpeedArguments struct {
+	// The job identifier.  This used to be a device name (hence the name of the parameter), but since QEMU 2.7 it can have other values.
+	Device string `json:"device"`
+	// the maximum speed, in bytes per second, or 0 for unlimited. Defaults to 0.
+	Speed int64 `json:"speed"`
+}
+
+// BlockJobSetSpeed: Set maximum speed for a background block operation.
+//
+// QAPI command 'block-job-set-speed'
+func (c *Client) BlockJobSetSpeed(ctx context.Context, args *BlockJobSetSpeedArguments) error {
+	_, err := c.Execute(ctx, "block-job-set-speed", qapiArgs(args))
+	return err
+}
+
+// BlockSetIOThrottle: Change I/O throttle limits for a block drive.
+//
+// QAPI command 'block_set_io_throttle'
+func (c *Client) BlockSetIOThrottle(ctx context.Context, args *BlockIOThrottle) error {
+	_, err := c.Execute(ctx, "block_set_io_throttle", qapiArgs(args))
+	return err
+}
+
+// BlockdevAdd: Creates a new block device.
+//
+// QAPI command 'blockdev-add'
+func (c *Client) BlockdevAdd(ctx context.Context, args *BlockdevOptions) error {
+	_, err := c.Execute(ctx, "blockdev-add", qapiArgs(args))
+	return err
+}
+
+// BlockdevBackup: Start a point-in-time copy of a block device to a new destination. The status of ongoing blockdev-backup operations can be checked with query-block-jobs where the BlockJobInfo.type field has the value 'backup'.  The operation can be stopped before it has completed using the block-job-cancel command.
+//
+// QAPI command 'blockdev-backup'
+func (c *Client) BlockdevBackup(ctx context.Context, args *BlockdevBackup) error {
+	_, err := c.Execute(ctx, "blockdev-backup", qapiArgs(args))
+	return err
+}
+
+// arguments of BlockdevDel
+type BlockdevDelArguments struct {
+	// Name of the graph node to delete.
+	NodeName string `json:"node-name"`
+}
+
+// BlockdevDel: Deletes a block device that has been added using blockdev-add.  The command will fail if the node is attached to a device or is otherwise being used.
+//
+// QAPI command 'blockdev-del'
+func (c *Client) BlockdevDel(ctx context.Context, args *BlockdevDelArguments) error {
+	_, err := c.Execute(ctx, "blockdev-del", qapiArgs(args))
+	return err
+}
+
+// arguments of BlockdevMirror
+type BlockdevMirrorArguments struct {
+	// identifier for the newly-created block job.  If omitted, the device name will be used.  (Since 2.7)
+	JobID string `json:"job-id,omitempty"`
+	// The device name or node-name of a root node whose writes should be mirrored.
+	Device string `json:"device"`
+	// the id or node-name of the block device to mirror to. This mustn't be attached to guest.
+	Target string `json:"target"`
+	// with sync=full graph node name to be replaced by the new image when a whole image copy is done.  This can be used to repair broken Quorum files.  By default, @device is replaced, although implicitly created filters on it are kept.
+	Replaces string `json:"replaces,omitempty"`
+	// what parts of the disk image should be copied to the destination (all the disk, only the sectors allocated in the topmost image, or only new I/O).
+	Sync MirrorSyncMode `json:"sync"`
+	// the maximum speed, in bytes per second
+	Speed *int64 `json:"speed,omitempty"`
+	// granularity of the dirty bitmap, default is 64K if the image format doesn't have clusters, 4K if the clusters are smaller than that, else the cluster size.  Must be a power of 2 between 512 and 64M
+	Granularity *uint32 `json:"granularity,omitempty"`
+	// maximum amount of data in flight from source to target
+	BufSize *int64 `json:"buf-size,omitempty"`
+	// the action to take on an error on the source, default 'report'.  'stop' and 'enospc' can only be used if the block device supports io-status (see BlockInfo).
+	OnSourceError BlockdevOnError `json:"on-source-error,omitempty"`
+	// the action to take on an error on the target, default 'report' (no limitations, since this applies to a different block device than @device).
+	OnTargetError BlockdevOnError `json:"on-target-error,omitempty"`
+	// the node name that should be assigned to the filter driver that the mirror job inserts into the graph above @device.  If this option is not given, a node name is autogenerated.  (Since: 2.9)
+	FilterNodeName string `json:"filter-node-name,omitempty"`
+	// when to copy data to the destination; defaults to 'background' (Since: 3.0)
+	CopyMode MirrorCopyMode `json:"copy-mode,omitempty"`
+	// When false, this job will wait in a PENDING state after it has finished its work, waiting for block-job-finalize before making any block graph changes.  When true, this job will automatically perform its abort or commit actions.  Defaults to true.  (Since 3.1)
+	AutoFinalize *bool `json:"auto-finalize,omitempty"`
+	// When false, this job will wait in a CONCLUDED state after it has completely ceased all work, and awaits block-job-dismiss.  When true, this job will automatically disappear from the query list without user intervention. Defaults to true.  (Since 3.1)
+	AutoDismiss *bool `json:"auto-dismiss,omitempty"`
+}
+
+// BlockdevMirror: Start mirroring a block device's writes to a new destination.
+//
+// QAPI command 'blockdev-mirror'
+func (c *Client) BlockdevMirror(ctx context.Context, args *BlockdevMirrorArguments) error {
+	_, err := c.Execute(ctx, "blockdev-mirror", qapiArgs(args))
+	return err
+}
+
+// BlockdevSnapshotSync: Takes a synchronous snapshot of a block device.
+//
+// QAPI command 'blockdev-snapshot-sync'
+func (c *Client) BlockdevSnapshotSync(ctx context.Context, args *BlockdevSnapshotSync) error {
+	_, err := c.Execute(ctx, "blockdev-snapshot-sync", qapiArgs(args))
+	return err
+}
+
+// Cont: Resume guest VM execution.
+//
+// QAPI command 'cont'
+func (c *Client) Cont(ctx context.Context) error {
+	_, err := c.Execute(ctx, "cont", nil)
+	return err
+}
+
+// arguments of DeviceDel
+type DeviceDelArguments struct {
+	// the device's ID or QOM path
+	ID string `json:"id"`
+}
+
+// DeviceDel: Remove a device from a guest
+//
+// QAPI command 'device_del'
+func (c *Client) DeviceDel(ctx context.Context, args *DeviceDelArguments) error {
+	_, err := c.Execute(ctx, "device_del", qapiArgs(args))
+	return err
+}
+
+// arguments of HumanMonitorCommand
+type HumanMonitorCommandArguments struct {
+	// the command to execute in the human monitor
+	CommandLine string `json:"command-line"`
+	// The CPU to use for commands that require an implicit CPU
+	CPUIndex *int64 `json:"cpu-index,omitempty"`
+}
+
+// HumanMonitorCommand: Execute a command on the human monitor and return the output.
+//
+// QAPI command 'human-monitor-command'
+func (c *Client) HumanMonitorCommand(ctx context.Context, args *HumanMonitorCommandArguments) (string, error) {
+	var v string
+	ret, err := c.Execute(ctx, "human-monitor-command", qapiArgs(args))
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// arguments of JobCancel
+type JobCancelArguments struct {
+	// The job identifier.
+	ID string `json:"id"`
+}
+
+// JobCancel: Instruct an active background job to cancel at the next opportunity. This command returns immediately after marking the active job for cancellation.
+//
+// QAPI command 'job-cancel'
+func (c *Client) JobCancel(ctx context.Context, args *JobCancelArguments) error {
+	_, err := c.Execute(ctx, "job-cancel", qapiArgs(args))
+	return err
+}
+
+// arguments of JobComplete
+type JobCompleteArguments struct {
+	// The job identifier.
+	ID string `json:"id"`
+}
+
+// JobComplete: Manually trigger completion of an active job in the READY state.
+//
+// QAPI command 'job-complete'
+func (c *Client) JobComplete(ctx context.Context, args *JobCompleteArguments) error {
+	_, err := c.Execute(ctx, "job-complete", qapiArgs(args))
+	return err
+}
+
+// arguments of JobDismiss
+type JobDismissArguments struct {
+	// The job identifier.
+	ID string `json:"id"`
+}
+
+// JobDismiss: Deletes the job from the job list.  This is only allowed for jobs in the concluded state.
+//
+// QAPI command 'job-dismiss'
+func (c *Client) JobDismiss(ctx context.Context, args *JobDismissArguments) error {
+	_, err := c.Execute(ctx, "job-dismiss", qapiArgs(args))
+	return err
+}
+
+// arguments of JobFinalize
+type JobFinalizeArguments struct {
+	// The identifier of any job in the transaction, or of a job that is not part of any transaction.
+	ID string `json:"id"`
+}
+
+// JobFinalize: Instructs all jobs in a transaction (or a single job if it is not part of any transaction) to finalize any graph changes and do any necessary cleanup.  This command requires that all involved jobs are in the PENDING state.
+//
+// QAPI command 'job-finalize'
+func (c *Client) JobFinalize(ctx context.Context, args *JobFinalizeArguments) error {
+	_, err := c.Execute(ctx, "job-finalize", qapiArgs(args))
+	return err
+}
+
+// arguments of JobPause
+type JobPauseArguments struct {
+	// The job identifier.
+	ID string `json:"id"`
+}
+
+// JobPause: Pause an active job.
+//
+// QAPI command 'job-pause'
+func (c *Client) JobPause(ctx context.Context, args *JobPauseArguments) error {
+	_, err := c.Execute(ctx, "job-pause", qapiArgs(args))
+	return err
+}
+
+// arguments of JobResume
+type JobResumeArguments struct {
+	// The job identifier.
+	ID string `json:"id"`
+}
+
+// JobResume: Resume a paused job.
+//
+// QAPI command 'job-resume'
+func (c *Client) JobResume(ctx context.Context, args *JobResumeArguments) error {
+	_, err := c.Execute(ctx, "job-resume", qapiArgs(args))
+	return err
+}
+
+// MigrateSetParameters: Set various migration parameters.
+//
+// QAPI command 'migrate-set-parameters'
+func (c *Client) MigrateSetParameters(ctx context.Context, args *MigrateSetParameters) error {
+	_, err := c.Execute(ctx, "migrate-set-parameters", qapiArgs(args))
+	return err
+}
+
+// arguments of NBDServerStart
+type NBDServerStartArguments struct {
+	// Address on which to listen.
+	Addr *SocketAddressLegacy `json:"addr"`
+	// ID of the TLS credentials object (since 2.6).
+	TLSCreds string `json:"tls-creds,omitempty"`
+	// ID of the QAuthZ authorization object used to validate the client's x509 distinguished name.  This object is is only resolved at time of use, so can be deleted and recreated on the fly while the NBD server is active.  If missing, it will default to denying access (since 4.0).
+	TLSAuthz string `json:"tls-authz,omitempty"`
+	// The maximum number of connections to allow at the same time, 0 for unlimited.  Setting this to 1 also stops the server from advertising multiple client support (since 5.2; default: 100)
+	MaxConnections *uint32 `json:"max-connections,omitempty"`
+}
+
+// NBDServerStart: Start an NBD server listening on the given host and port.  Block devices can then be exported using block-export-add.  The NBD server will present them as named exports; for example, another QEMU instance could refer to them as "nbd:HOST:PORT:exportname=NAME".
+//
+// QAPI command 'nbd-server-start'
+func (c *Client) NBDServerStart(ctx context.Context, args *NBDServerStartArguments) error {
+	_, err := c.Execute(ctx, "nbd-server-start", qapiArgs(args))
+	return err
+}
+
+// NBDServerStop: Stop QEMU's embedded NBD server, and unregister all devices previously added via nbd-server-add.
+//
+// QAPI command 'nbd-server-stop'
+func (c *Client) NBDServerStop(ctx context.Context) error {
+	_, err := c.Execute(ctx, "nbd-server-stop", nil)
+	return err
+}
+
+// arguments of QomSet
+type QomSetArguments struct {
+	// see qom-get for a description of this parameter
+	Path string `json:"path"`
+	// the property name to set
+	Property string `json:"property"`
+	// a value who's type is appropriate for the property type. See qom-get for a description of type mapping.
+	Value any `json:"value"`
+}
+
+// QomSet: This command will set a property from a object model path.
+//
+// QAPI command 'qom-set'
+func (c *Client) QomSet(ctx context.Context, args *QomSetArguments) error {
+	_, err := c.Execute(ctx, "qom-set", qapiArgs(args))
+	return err
+}
+
+// QueryBlock: Get a list of BlockInfo for all virtual block devices.
+//
+// QAPI command 'query-block'
+func (c *Client) QueryBlock(ctx context.Context) ([]*BlockInfo, error) {
+	var v []*BlockInfo
+	ret, err := c.Execute(ctx, "query-block", nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// QueryBlockExports: Returns: A list of BlockExportInfo describing all block exports
+//
+// QAPI command 'query-block-exports'
+func (c *Client) QueryBlockExports(ctx context.Context) ([]*BlockExportInfo, error) {
+	var v []*BlockExportInfo
+	ret, err := c.Execute(ctx, "query-block-exports", nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// QueryBlockJobs: Return information about long-running block device operations.
+//
+// QAPI command 'query-block-jobs'
+func (c *Client) QueryBlockJobs(ctx context.Context) ([]*BlockJobInfo, error) {
+	var v []*BlockJobInfo
+	ret, err := c.Execute(ctx, "query-block-jobs", nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// QueryJobs: Return information about jobs.
+//
+// QAPI command 'query-jobs'
+func (c *Client) QueryJobs(ctx context.Context) ([]*JobInfo, error) {
+	var v []*JobInfo
+	ret, err := c.Execute(ctx, "query-jobs", nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// QueryStatus: Query the run status of the VM
+//
+// QAPI command 'query-status'
+func (c *Client) QueryStatus(ctx context.Context) (*StatusInfo, error) {
+	var v *StatusInfo
+	ret, err := c.Execute(ctx, "query-status", nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(ret, &v)
+	return v, err
+}
+
+// Quit: This command will cause the QEMU process to exit gracefully.  While every attempt is made to send the QMP response before terminating, this is not guaranteed.
+//
+// QAPI command 'quit'
+func (c *Client) Quit(ctx context.Context) error {
+	_, err := c.Execute(ctx, "quit", nil)
+	return err
+}
+
+// arguments of SnapshotDelete
+type SnapshotDeleteArguments struct {
+	// identifier for the newly created job
+	JobID string `json:"job-id"`
+	// name of the snapshot to delete.
+	Tag string `json:"tag"`
+	// list of block device node names to delete a snapshot from
+	Devices []string `json:"devices"`
+}
+
+// SnapshotDelete: Delete a VM snapshot
+//
+// QAPI command 'snapshot-delete'
+func (c *Client) SnapshotDelete(ctx context.Context, args *SnapshotDeleteArguments) error {
+	_, err := c.Execute(ctx, "snapshot-delete", qapiArgs(args))
+	return err
+}
+
+// arguments of SnapshotLoad
+type SnapshotLoadArguments struct {
+	// identifier for the newly created job
+	JobID string `json:"job-id"`
+	// name of the snapshot to load.
+	Tag string `json:"tag"`
+	// block device node name to load vmstate from
+	Vmstate string `json:"vmstate"`
+	// list of block device node names to load a snapshot from
+	Devices []string `json:"devices"`
+}
+
+// SnapshotLoad: Load a VM snapshot
+//
+// QAPI command 'snapshot-load'
+func (c *Client) SnapshotLoad(ctx context.Context, args *SnapshotLoadArguments) error {
+	_, err := c.Execute(ctx, "snapshot-load", qapiArgs(args))
+	return err
+}
+
+// arguments of SnapshotSave
+type SnapshotSaveArguments struct {
+	// identifier for the newly created job
+	JobID string `json:"job-id"`
+	// name of the snapshot to create
+	Tag string `json:"tag"`
+	// block device node name to save vmstate to
+	Vmstate string `json:"vmstate"`
+	// list of block device node names to save a snapshot to
+	Devices []string `json:"devices"`
+}
+
+// SnapshotSave: Save a VM snapshot
+//
+// QAPI command 'snapshot-save'
+func (c *Client) SnapshotSave(ctx context.Context, args *SnapshotSaveArguments) error {
+	_, err := c.Execute(ctx, "snapshot-save", qapiArgs(args))
+	return err
+}
+
+// Stop: Stop guest VM execution.
+//
+// QAPI command 'stop'
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.Execute(ctx, "stop", nil)
+	return err
+}
+
+// SystemPowerdown: Requests that a guest perform a powerdown operation.
+//
+// QAPI command 'system_powerdown'
+func (c *Client) SystemPowerdown(ctx context.Context) error {
+	_, err := c.Execute(ctx, "system_powerdown", nil)
+	return err
+}
+
+// SystemReset: Performs a hard reset of a guest.
+//
+// QAPI command 'system_reset'
+func (c *Client) SystemReset(ctx context.Context) error {
+	_, err := c.Execute(ctx, "system_reset", nil)
+	return err
+}
+
+// SystemWakeup: Wake up guest from suspend.
+//
+// QAPI command 'system_wakeup'
+func (c *Client) SystemWakeup(ctx context.Context) error {
+	_, err := c.Execute(ctx, "system_wakeup", nil)
+	return err
+}
+
+// arguments of Transaction
+type TransactionArguments struct {
+	// List of @TransactionAction; information needed for the respective operations.
+	Actions []*TransactionAction `json:"actions"`
+	// structure of additional options to control the execution of the transaction.  See @TransactionProperties for additional detail.
+	Properties *TransactionProperties `json:"properties,omitempty"`
+}
+
+// Transaction: Executes a number of transactionable QMP commands atomically.  If any operation fails, then the entire set of actions will be abandoned and the appropriate error returned.
+//
+// QAPI command 'transaction'
+func (c *Client) Transaction(ctx context.Context, args *TransactionArguments) error {
+	_, err := c.Execute(ctx, "transaction", qapiArgs(args))
+	return err
+}
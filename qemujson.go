@@ -0,0 +1,334 @@
+package virt
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// set a dotted key ("file.filename") in nested maps
+func setPath(m map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// remove a dotted key from nested maps, dropping the parents left empty
+func takePath(m map[string]any, key string) (any, bool) {
+	head, tail, nested := strings.Cut(key, ".")
+	if !nested {
+		v, ok := m[key]
+		delete(m, key)
+		return v, ok
+	}
+	child, ok := m[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok := takePath(child, tail)
+	if len(child) == 0 {
+		delete(m, head)
+	}
+	return v, ok
+}
+
+func mergeMaps(dst, src map[string]any) {
+	for k, v := range src {
+		s, sok := v.(map[string]any)
+		d, dok := dst[k].(map[string]any)
+		if sok && dok {
+			mergeMaps(d, s)
+		} else {
+			dst[k] = v
+		}
+	}
+}
+
+// nested maps and lists as dotted keys: file.driver=..., server.0.host=...
+func flattenOpts(prefix string, v any, opts []qemuOpt) []qemuOpt {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch x := v.(type) {
+	case nil:
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			opts = flattenOpts(key(k), x[k], opts)
+		}
+	case []any:
+		for i, e := range x {
+			opts = flattenOpts(key(strconv.Itoa(i)), e, opts)
+		}
+	case bool:
+		s := "off"
+		if x {
+			s = "on"
+		}
+		opts = append(opts, qemuOpt{prefix, s})
+	default:
+		opts = append(opts, qemuOpt{prefix, fmt.Sprint(x)})
+	}
+	return opts
+}
+
+// on/off of a boolean option given as a string
+func onOff(s string) bool {
+	return s == "on" || s == "true"
+}
+
+// keys of a raw option string whose JSON type is known, by their last
+// dotted part; any other value stays a string (serial=0001, name=on)
+var jsonOptKinds = map[string]reflect.Kind{
+	"read-only":            reflect.Bool,
+	"auto-read-only":       reflect.Bool,
+	"force-share":          reflect.Bool,
+	"share-rw":             reflect.Bool,
+	"packed":               reflect.Bool,
+	"event_idx":            reflect.Bool,
+	"indirect_desc":        reflect.Bool,
+	"bootindex":            reflect.Int,
+	"port":                 reflect.Int,
+	"chassis":              reflect.Int,
+	"lun":                  reflect.Int,
+	"scsi-id":              reflect.Int,
+	"channel":              reflect.Int,
+	"vectors":              reflect.Int,
+	"num-queues":           reflect.Int,
+	"queue-size":           reflect.Int,
+	"logical_block_size":   reflect.Int,
+	"physical_block_size":  reflect.Int,
+	"cache-size":           reflect.Int,
+	"l2-cache-size":        reflect.Int,
+	"refcount-cache-size":  reflect.Int,
+	"cache-clean-interval": reflect.Int,
+}
+
+// JSON value of a raw option, typed only for the keys of jsonOptKinds
+func jsonOptValue(key, s string) any {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	switch jsonOptKinds[key] {
+	case reflect.Bool:
+		switch s {
+		case "on", "true":
+			return true
+		case "off", "false":
+			return false
+		}
+	case reflect.Int:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
+
+func jsonQemuValue(f qemuField, v reflect.Value) (any, error) {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if f.boolean {
+			return onOff(v.String()), nil
+		}
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	}
+	return nil, fmt.Errorf("%w: tipo %s", ErrQemuOpts, v.Type())
+}
+
+// json.Number as int64 or float64, so the extra map survives YAML
+func jsonNumbers(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, e := range x {
+			x[k] = jsonNumbers(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = jsonNumbers(e)
+		}
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
+
+/*
+usage:
+
+	b, err := virt.MarshalQemuJSON(&virt.BlockDev{
+		Driver:   "qcow2",
+		NodeName: "disk0",
+		Options: map[string]any{
+			"file": map[string]any{"driver": "host_device", "filename": "/dev/sdb"},
+		},
+	})
+	// {"driver":"qcow2","file":{"driver":"host_device","filename":"/dev/sdb"},"node-name":"disk0"}
+
+encode a struct with `qemu` tags in the JSON form accepted by -blockdev and
+-device. Dotted keys become nested objects, the extra map is merged as is and
+the values of a raw option string stay strings, but for a few well known keys
+(read-only, bootindex, ...).
+*/
+func MarshalQemuJSON(v any) ([]byte, error) {
+	obj, err := qemuJSONObject(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func qemuJSONObject(v any) (map[string]any, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T não é struct", ErrQemuOpts, v)
+	}
+	obj := map[string]any{}
+	for _, f := range qemuFieldsOf(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil || (fv.IsZero() && !f.always) {
+			continue
+		}
+		switch {
+		case f.extra:
+			if m, ok := fv.Interface().(map[string]any); ok {
+				mergeMaps(obj, m)
+			}
+		case f.raw:
+			for _, o := range parseOpts(fv.String(), "") {
+				setPath(obj, o.Key, jsonOptValue(o.Key, o.Value))
+			}
+		default:
+			val, err := jsonQemuValue(f, fv)
+			if err != nil {
+				return nil, err
+			}
+			setPath(obj, f.key, val)
+		}
+	}
+	return obj, nil
+}
+
+/*
+usage:
+
+	b := &virt.BlockDev{}
+	err := virt.UnmarshalQemuJSON([]byte(`{"driver":"raw","file":{"driver":"file","filename":"a.img"}}`), b)
+
+decode the JSON form of -blockdev and -device. Keys without a field go to the
+extra map with their JSON types, then to the raw field, or fail with
+ErrUnknownOpt; v is left untouched on error.
+*/
+func UnmarshalQemuJSON(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T não é ponteiro para struct", ErrQemuOpts, v)
+	}
+	rv = rv.Elem()
+
+	obj := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return fmt.Errorf("%w: %s", ErrQemuOpts, err)
+	}
+
+	fields := qemuFieldsOf(rv.Type())
+	byKey, raw, extra := qemuKeys(fields)
+	out := reflect.New(rv.Type()).Elem()
+	for _, f := range byKey {
+		val, ok := takePath(obj, f.key)
+		if !ok {
+			continue
+		}
+		s := ""
+		switch x := val.(type) {
+		case string:
+			s = x
+		case json.Number:
+			s = x.String()
+		case bool:
+			s = "off"
+			if x {
+				s = "on"
+			}
+		default:
+			return fmt.Errorf("%w: %s", ErrQemuOpts, f.key)
+		}
+		if err := parseQemuValue(f, s, qemuFieldValue(out, f.index)); err != nil {
+			return err
+		}
+	}
+
+	if rest := flattenOpts("", obj, nil); len(rest) > 0 {
+		switch {
+		case extra != nil:
+			out.FieldByIndex(extra.index).Set(reflect.ValueOf(jsonNumbers(obj)))
+		case raw != nil:
+			out.FieldByIndex(raw.index).SetString(joinOpts(rest))
+		default:
+			return fmt.Errorf("%w: %s", ErrUnknownOpt, rest[0].Key)
+		}
+	}
+	rv.Set(out)
+	return nil
+}
+
+// the JSON form as dotted options, the values lose their JSON types
+func jsonOpts(data []byte) ([]qemuOpt, error) {
+	obj := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrQemuOpts, err)
+	}
+	return flattenOpts("", obj, nil), nil
+}
+
+// []string{flag, json}, as used by the ToJSONArgs methods
+func qemuJSONArg(flag string, v any) ([]string, error) {
+	b, err := MarshalQemuJSON(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flag, err)
+	}
+	return []string{flag, string(b)}, nil
+}
@@ -0,0 +1,443 @@
+package virt
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrQemuOpts   = errors.New("opção QEMU inválida")
+	ErrUnknownOpt = errors.New("opção QEMU desconhecida")
+)
+
+/*
+struct tags understood by MarshalQemuOpts and UnmarshalQemuOpts:
+
+	Poll   string         `qemu:"poll-us"`             // poll-us=<value>
+	Driver string         `qemu:"driver,implied"`      // leading value without key: "virtio-net,..."
+	Type   NicType        `qemu:"type,implied,always"` // never omitted, even when zero
+	Size   int            `qemu:"size,mb"`             // megabytes, encoded as 512M, decoded from 512M, 2G, 512 ...
+	Max    int            `qemu:"maxmem,mb,bytes"`     // megabytes, but a value without suffix is decoded as bytes
+	Cache  *Cache         `qemu:"cache"`               // nested struct: cache.direct=...
+	Option string         `qemu:",raw"`                // already an option string, keeps the unknown keys
+	RO     string         `qemu:"read-only,bool"`      // on/off kept in a string, a boolean in JSON
+	Extra  map[string]any `qemu:",extra"`              // any other key, nested maps are dotted keys
+
+fields without tag are ignored. Zero values are omitted, booleans are on/off,
+commas inside values are doubled and the order is the order of the fields,
+then the sorted keys of the extra map.
+*/
+type qemuField struct {
+	key     string
+	index   []int
+	implied bool
+	always  bool
+	raw     bool
+	mb      bool
+	bytes   bool // mb without suffix is bytes
+	boolean bool
+	extra   bool
+}
+
+type qemuOpt struct {
+	Key   string
+	Value string
+}
+
+var qemuFieldCache sync.Map // reflect.Type -> []qemuField
+
+func qemuFieldsOf(t reflect.Type) []qemuField {
+	if f, ok := qemuFieldCache.Load(t); ok {
+		return f.([]qemuField)
+	}
+	f := qemuFields(t, "", nil)
+	qemuFieldCache.Store(t, f)
+	return f
+}
+
+func qemuFields(t reflect.Type, prefix string, index []int) []qemuField {
+	fields := []qemuField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("qemu")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+		name, flags, _ := strings.Cut(tag, ",")
+		f := qemuField{key: prefix + name, index: idx}
+		for _, o := range strings.Split(flags, ",") {
+			switch o {
+			case "implied":
+				f.implied = prefix == ""
+			case "always":
+				f.always = true
+			case "raw":
+				f.raw = true
+			case "mb":
+				f.mb = true
+			case "bytes":
+				f.bytes = true
+			case "bool":
+				f.boolean = true
+			case "extra":
+				f.extra = sf.Type.Kind() == reflect.Map
+			}
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !isQemuScalar(sf.Type) {
+			fields = append(fields, qemuFields(ft, f.key+".", idx)...)
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+var (
+	textMarshaler   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func isQemuScalar(t reflect.Type) bool {
+	return t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+// split a QEMU option string at the commas, ",," is a literal comma
+func splitOpts(s string) []string {
+	parts := []string{}
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' {
+			if i+1 < len(s) && s[i+1] == ',' {
+				b.WriteByte(',')
+				i++
+				continue
+			}
+			parts = append(parts, b.String())
+			b.Reset()
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return append(parts, b.String())
+}
+
+/*
+key=value pairs of a QEMU option string. A leading element without '=' is
+returned under the implied key ('driver' in "virtio-net,id=n0"), the other
+elements without '=' are flags ("server") with the value "on".
+*/
+func parseOpts(s, implied string) []qemuOpt {
+	opts := []qemuOpt{}
+	for i, p := range splitOpts(s) {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			if i == 0 && implied != "" {
+				k, v = implied, p
+			} else {
+				v = "on"
+			}
+		}
+		opts = append(opts, qemuOpt{k, v})
+	}
+	return opts
+}
+
+func escapeOpt(s string) string { return strings.ReplaceAll(s, ",", ",,") }
+
+// inverse of parseOpts
+func joinOpts(opts []qemuOpt) string {
+	parts := make([]string, len(opts))
+	for i, o := range opts {
+		parts[i] = escapeOpt(o.Key) + "=" + escapeOpt(o.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatQemuValue(v reflect.Value) (string, error) {
+	if v.Type().Implements(textMarshaler) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "on", nil
+		}
+		return "off", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Pointer:
+		return formatQemuValue(v.Elem())
+	}
+	return "", fmt.Errorf("%w: tipo %s", ErrQemuOpts, v.Type())
+}
+
+func parseQemuValue(f qemuField, s string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch s {
+		case "on", "yes", "true":
+			v.SetBool(true)
+		case "off", "no", "false":
+			v.SetBool(false)
+		default:
+			return fmt.Errorf("%w: %s=%s", ErrQemuOpts, f.key, s)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		var err error
+		if f.mb {
+			var m int
+			m, err = parseMegs(s, f.bytes)
+			n = int64(m)
+		} else {
+			n, err = strconv.ParseInt(s, 0, v.Type().Bits())
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s=%s", ErrQemuOpts, f.key, s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %s=%s", ErrQemuOpts, f.key, s)
+		}
+		v.SetUint(n)
+	default:
+		return fmt.Errorf("%w: tipo %s", ErrQemuOpts, v.Type())
+	}
+	return nil
+}
+
+/*
+QEMU size in megabytes. Without suffix -m size is already in megabytes (a
+legacy of QEMU) and the other sizes, maxmem, are in bytes.
+*/
+func parseMegs(s string, bytes bool) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("%w: tamanho vazio", ErrQemuOpts)
+	}
+	mul, div := 1, 1
+	num := s[:len(s)-1]
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "B":
+		div = 1 << 20
+	case "K":
+		div = 1 << 10
+	case "M":
+	case "G":
+		mul = 1 << 10
+	case "T":
+		mul = 1 << 20
+	default:
+		num = s
+		if bytes {
+			div = 1 << 20
+		}
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 || n*mul%div != 0 {
+		return 0, fmt.Errorf("%w: tamanho %q", ErrQemuOpts, s)
+	}
+	return n * mul / div, nil
+}
+
+/*
+usage:
+
+	s, err := virt.MarshalQemuOpts(&virt.Netdev_TapOptions{ID: "n0", Ifname: "tap0"})
+	// id=n0,ifname=tap0
+
+encode a struct with `qemu` tags as a QEMU option string
+*/
+func MarshalQemuOpts(v any) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return "", fmt.Errorf("%w: %T não é struct", ErrQemuOpts, v)
+	}
+	parts := []string{}
+	for _, f := range qemuFieldsOf(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil { // nil parent struct
+			continue
+		}
+		if fv.IsZero() && !f.always {
+			continue
+		}
+		s := ""
+		if !f.extra {
+			s, err = formatQemuValue(fv)
+		}
+		if err != nil {
+			return "", err
+		}
+		if f.mb {
+			// QEMU reads maxmem=4096 as bytes
+			s += "M"
+		}
+		if f.extra {
+			parts = append(parts, joinOpts(flattenOpts(f.key, fv.Interface(), nil)))
+			continue
+		}
+		switch {
+		case f.raw:
+			if s != "" {
+				parts = append(parts, s)
+			}
+		case f.implied && len(parts) == 0:
+			parts = append(parts, escapeOpt(s))
+		default:
+			parts = append(parts, escapeOpt(f.key)+"="+escapeOpt(s))
+		}
+	}
+	return strings.Join(parts, ","), nil
+}
+
+/*
+usage:
+
+	tap := &virt.Netdev_TapOptions{}
+	err := virt.UnmarshalQemuOpts("id=n0,ifname=tap0", tap)
+
+decode a QEMU option string into a struct with `qemu` tags. Keys without a
+field go to the raw field, then to the extra map, or fail with ErrUnknownOpt;
+v is left untouched on error.
+*/
+func UnmarshalQemuOpts(s string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T não é ponteiro para struct", ErrQemuOpts, v)
+	}
+	implied := ""
+	if f := qemuFieldsOf(rv.Elem().Type()); len(f) > 0 && f[0].implied {
+		implied = f[0].key
+	}
+	return decodeQemuOpts(parseOpts(s, implied), v)
+}
+
+func decodeQemuOpts(opts []qemuOpt, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	fields := qemuFieldsOf(rv.Type())
+	byKey, raw, extra := qemuKeys(fields)
+
+	out := reflect.New(rv.Type()).Elem()
+	unknown := []qemuOpt{}
+	extras := map[string]any{}
+	for _, o := range opts {
+		f, ok := byKey[o.Key]
+		switch {
+		case ok:
+			if err := parseQemuValue(f, o.Value, qemuFieldValue(out, f.index)); err != nil {
+				return err
+			}
+		case raw != nil:
+			unknown = append(unknown, o)
+		case extra != nil:
+			setPath(extras, o.Key, jsonOptValue(o.Key, o.Value))
+		default:
+			return fmt.Errorf("%w: %s", ErrUnknownOpt, o.Key)
+		}
+	}
+	if len(unknown) > 0 {
+		out.FieldByIndex(raw.index).SetString(joinOpts(unknown))
+	}
+	if len(extras) > 0 {
+		out.FieldByIndex(extra.index).Set(reflect.ValueOf(extras))
+	}
+	rv.Set(out)
+	return nil
+}
+
+func qemuKeys(fields []qemuField) (byKey map[string]qemuField, raw, extra *qemuField) {
+	byKey = map[string]qemuField{}
+	for i, f := range fields {
+		switch {
+		case f.raw:
+			raw = &fields[i]
+		case f.extra:
+			extra = &fields[i]
+		default:
+			byKey[f.key] = f
+		}
+	}
+	return byKey, raw, extra
+}
+
+// the field at index, allocating the nil parent structs
+func qemuFieldValue(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// []string{flag, "prefix,opts"}, as used by the ToArgs methods
+func qemuArg(flag, prefix string, v any) ([]string, error) {
+	s, err := MarshalQemuOpts(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flag, err)
+	}
+	switch {
+	case prefix == "":
+	case s == "":
+		s = prefix
+	default:
+		s = prefix + "," + s
+	}
+	return []string{flag, s}, nil
+}
+
+// the arguments of the ToArgs methods, the first error is kept
+type argsBuilder struct {
+	args []string
+	err  error
+}
+
+func (b *argsBuilder) add(args []string, err error) {
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	b.args = append(b.args, args...)
+}
+
+func (b *argsBuilder) put(args ...string) { b.args = append(b.args, args...) }
+
+func (b *argsBuilder) result() ([]string, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.args, nil
+}
@@ -0,0 +1,125 @@
+package virt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryOptionsSizes(t *testing.T) {
+	tests := []struct {
+		opts string
+		want MemoryOptions
+	}{
+		{"2G,maxmem=8G", MemoryOptions{Size: 2048, Maxmen: 8192}},
+		{"512", MemoryOptions{Size: 512}},
+		{"size=512,slots=4,maxmem=4294967296", MemoryOptions{Size: 512, Slots: "4", Maxmen: 4096}},
+		{"1024M,maxmem=2097152K", MemoryOptions{Size: 1024, Maxmen: 2048}},
+	}
+	for _, tt := range tests {
+		m := MemoryOptions{}
+		if err := UnmarshalQemuOpts(tt.opts, &m); err != nil {
+			t.Errorf("%s: %v", tt.opts, err)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.opts, m, tt.want)
+		}
+	}
+
+	args, err := (&MemoryOptions{Size: 2048, Slots: "4", Maxmen: 8192}).ToArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-m", "2048M,slots=4,maxmem=8192M"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args %q, want %q", args, want)
+	}
+
+	for _, bad := range []string{"size=", "maxmem=", "1G,maxmem=100", "size=x"} {
+		if err := UnmarshalQemuOpts(bad, &MemoryOptions{}); !errors.Is(err, ErrQemuOpts) {
+			t.Errorf("%s: err = %v, want ErrQemuOpts", bad, err)
+		}
+		if _, _, err := ParseArgs([]string{"qemu-system-x86_64", "-m", bad}); err == nil {
+			t.Errorf("ParseArgs -m %s: no error", bad)
+		}
+	}
+}
+
+func TestGuestArgsError(t *testing.T) {
+	g := &Guest{Name: "vm1", JSONSyntax: true, Devices: []*DeviceOptions{
+		{Driver: "virtio-blk-pci", Props: map[string]any{"drive": "disk0", "bad": make(chan int)}},
+	}}
+	args, err := g.ToArgs()
+	if err == nil || args != nil {
+		t.Errorf("ToArgs = %q, %v, want an error", args, err)
+	}
+
+	g.JSONSyntax = false
+	g.Devices[0].Props["bad"] = "x"
+	if _, err := g.ToArgs(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQmpCdromArgs(t *testing.T) {
+	g := &Guest{
+		Name:         "vm1",
+		Qmp:          &QmpOptions{ProtoPath: "unix:/run/a,b.qmp", Serve: true},
+		BlockDevices: &BlockDevicesOptions{Cdrom: []*CdromOptions{{File: "/iso/x,y.iso"}}},
+	}
+	args, err := g.ToArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]string{
+		{"-qmp", "unix:/run/a,,b.qmp,server=on,wait=off"},
+		{"-drive", "index=2,media=cdrom,file=/iso/x,,y.iso"},
+	} {
+		if !containsPair(args, want) {
+			t.Errorf("args %q without %q", args, want)
+		}
+	}
+
+	parsed, _, err := ParseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed.Qmp != *g.Qmp || len(parsed.BlockDevices.Cdrom) != 1 || parsed.BlockDevices.Cdrom[0].File != "/iso/x,y.iso" {
+		t.Errorf("parsed qmp %+v, block devices %+v", parsed.Qmp, parsed.BlockDevices)
+	}
+
+	if args, _ := (&QmpOptions{ProtoPath: "stdio"}).ToArgs(); !reflect.DeepEqual(args, []string{"-qmp", "stdio"}) {
+		t.Errorf("stdio args %q", args)
+	}
+}
+
+// flag followed by value in args
+func containsPair(args, pair []string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == pair[0] && args[i+1] == pair[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestQemuJSONRawValues(t *testing.T) {
+	const opts = "serial=0001,id=1,name=on,bootindex=2,share-rw=on"
+	want := `{"bootindex":2,"driver":"virtio-blk-pci","id":"1","name":"on","serial":"0001","share-rw":true}`
+
+	d := &DeviceOptions{Driver: "virtio-blk-pci", Properties: opts}
+	if b, err := MarshalQemuJSON(d); err != nil || string(b) != want {
+		t.Errorf("raw: %s, %v", b, err)
+	}
+
+	var e struct {
+		Driver string         `qemu:"driver,implied"`
+		Extra  map[string]any `qemu:",extra"`
+	}
+	if err := UnmarshalQemuOpts("virtio-blk-pci,"+opts, &e); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := MarshalQemuJSON(&e); err != nil || string(b) != want {
+		t.Errorf("extra: %s, %v", b, err)
+	}
+}
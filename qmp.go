@@ -0,0 +1,279 @@
+package virt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrQmpStdio  = errors.New("qmp: stdio não suportado, use unix: ou tcp:")
+	ErrQmpProto  = errors.New("qmp: protocolo inválido")
+	ErrQmpClosed = errors.New("qmp: conexão fechada")
+)
+
+/*
+greeting sent by QEMU as soon as the monitor is connected:
+
+	{"QMP": {"version": {"qemu": {"micro": 0, "minor": 2, "major": 9}, "package": ""}, "capabilities": ["oob"]}}
+*/
+type QmpGreeting struct {
+	QMP struct {
+		Version struct {
+			Qemu struct {
+				Major int `json:"major"`
+				Minor int `json:"minor"`
+				Micro int `json:"micro"`
+			} `json:"qemu"`
+			Package string `json:"package"`
+		} `json:"version"`
+		Capabilities []string `json:"capabilities"`
+	} `json:"QMP"`
+}
+
+// {"error": {"class": "GenericError", "desc": "..."}}
+type QmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+func (e *QmpError) Error() string { return fmt.Sprintf("qmp: %s: %s", e.Class, e.Desc) }
+
+type qmpCommand struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+	ID        uint64 `json:"id"`
+}
+
+// any message read from the monitor: a reply (return|error + id) or an event
+type qmpMessage struct {
+	ID        *uint64         `json:"id,omitempty"`
+	Return    json.RawMessage `json:"return,omitempty"`
+	Error     *QmpError       `json:"error,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp *struct {
+		Seconds      int64 `json:"seconds"`
+		Microseconds int64 `json:"microseconds"`
+	} `json:"timestamp,omitempty"`
+}
+
+/*
+usage:
+
+	c, err := virt.DialQmp(ctx, "unix:sock/guest.qmp")
+	if err != nil { ... }
+	defer c.Close()
+	status, err := c.Execute(ctx, "query-status", nil)
+
+a QMP connection after capabilities negotiation
+*/
+type Client struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	wmu      sync.Mutex // serialize writes
+	mu       sync.Mutex // pending, subs
+	pending  map[uint64]chan *qmpMessage
+	subs     map[*Subscription]struct{}
+	lastID   atomic.Uint64
+	closed   atomic.Bool
+	done     chan struct{}
+	err      error // read error, valid after done is closed
+	Greeting QmpGreeting
+}
+
+// parse the same value used by QmpOptions.ProtoPath (unix:path | tcp:host:port)
+func qmpNetwork(protoPath string) (network, address string, err error) {
+	if strings.ToLower(protoPath) == "stdio" {
+		return "", "", ErrQmpStdio
+	}
+	proto, addr, ok := strings.Cut(protoPath, ":")
+	if !ok || addr == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrQmpProto, protoPath)
+	}
+	switch strings.ToLower(proto) {
+	case "unix":
+		return "unix", addr, nil
+	case "tcp":
+		return "tcp", addr, nil
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrQmpProto, protoPath)
+}
+
+// dial the monitor at protoPath (unix:path | tcp:host:port) and negotiate capabilities
+func DialQmp(ctx context.Context, protoPath string) (*Client, error) {
+	network, address, err := qmpNetwork(protoPath)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial the monitor of a guest started with '-qmp ...,server'
+func (q *QmpOptions) Dial(ctx context.Context) (*Client, error) { return DialQmp(ctx, q.ProtoPath) }
+
+/*
+usage:
+
+	l, err := virt.CreateListener("sock/guest.qmp") // guest started with -qmp unix:sock/guest.qmp (no server)
+	conn, err := l.Accept()
+	c, err := virt.NewClient(ctx, conn)
+
+wrap an already open connection, reading the greeting and sending qmp_capabilities
+*/
+func NewClient(ctx context.Context, conn net.Conn) (*Client, error) {
+	c := &Client{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		pending: map[uint64]chan *qmpMessage{},
+		subs:    map[*Subscription]struct{}{},
+		done:    make(chan struct{}),
+	}
+
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(d)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	line, err := c.r.ReadBytes('\n')
+	stop()
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(line, &c.Greeting); err != nil {
+		return nil, fmt.Errorf("%w: greeting: %v", ErrQmpProto, err)
+	}
+
+	go c.readLoop()
+
+	if _, err := c.Execute(ctx, "qmp_capabilities", nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// route replies to the caller waiting on its id and events to subscribers
+func (c *Client) readLoop() {
+	var err error
+	for {
+		var line []byte
+		line, err = c.r.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		msg := &qmpMessage{}
+		if err = json.Unmarshal(line, msg); err != nil {
+			err = fmt.Errorf("%w: %v", ErrQmpProto, err)
+			break
+		}
+		if msg.Event != "" {
+			c.publish(msg)
+			continue
+		}
+		if msg.ID == nil {
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[*msg.ID]
+		delete(c.pending, *msg.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg // buffered
+		}
+	}
+
+	if c.closed.Load() {
+		err = ErrQmpClosed
+	}
+	// done is closed under mu, a Subscribe after the drain sees it
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for s := range c.subs {
+		delete(c.subs, s)
+		close(s.c)
+	}
+	close(c.done)
+}
+
+/*
+usage:
+
+	ret, err := c.Execute(ctx, "human-monitor-command", map[string]any{"command-line": "info status"})
+
+run a QMP command and return the raw 'return' value
+*/
+func (c *Client) Execute(ctx context.Context, command string, args any) (json.RawMessage, error) {
+	if c.closed.Load() {
+		return nil, ErrQmpClosed
+	}
+	id := c.lastID.Add(1)
+	data, err := json.Marshal(qmpCommand{Execute: command, Arguments: args, ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(chan *qmpMessage, 1)
+	c.mu.Lock()
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	c.wmu.Lock()
+	if d, ok := ctx.Deadline(); ok {
+		c.conn.SetWriteDeadline(d)
+	}
+	_, err = c.conn.Write(append(data, '\n'))
+	c.conn.SetWriteDeadline(time.Time{})
+	c.wmu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Return, nil
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// closed when the connection is lost or closed
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+func (c *Client) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	return c.conn.Close()
+}
@@ -0,0 +1,213 @@
+package virt
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// size of each subscription buffer, events are dropped when a subscriber falls behind
+var EventBufferSize = 64
+
+const (
+	EventShutdown          = "SHUTDOWN"
+	EventPowerdown         = "POWERDOWN"
+	EventReset             = "RESET"
+	EventStop              = "STOP"
+	EventResume            = "RESUME"
+	EventSuspend           = "SUSPEND"
+	EventWakeup            = "WAKEUP"
+	EventGuestPanicked     = "GUEST_PANICKED"
+	EventDeviceDeleted     = "DEVICE_DELETED"
+	EventBlockJobCompleted = "BLOCK_JOB_COMPLETED"
+	EventBlockJobCancelled = "BLOCK_JOB_CANCELLED"
+	EventBlockJobError     = "BLOCK_JOB_ERROR"
+	EventBlockJobReady     = "BLOCK_JOB_READY"
+	EventJobStatusChange   = "JOB_STATUS_CHANGE"
+	EventMigration         = "MIGRATION"
+	EventMigrationPass     = "MIGRATION_PASS"
+)
+
+// an asynchronous message sent by QEMU, Data is kept raw, use Decode for the typed form
+type Event struct {
+	Name      string
+	Data      json.RawMessage
+	Timestamp time.Time
+}
+
+// SHUTDOWN: guest=true when initiated by the guest
+type ShutdownEvent struct {
+	Guest  bool   `json:"guest"`
+	Reason string `json:"reason"` // host-qmp-quit | guest-shutdown | host-signal ...
+}
+
+// RESET
+type ResetEvent struct {
+	Guest  bool   `json:"guest"`
+	Reason string `json:"reason"`
+}
+
+// STOP
+type StopEvent struct{}
+
+// RESUME
+type ResumeEvent struct{}
+
+// GUEST_PANICKED
+type GuestPanickedEvent struct {
+	Action string          `json:"action"` // pause | poweroff | run
+	Info   json.RawMessage `json:"info,omitempty"`
+}
+
+// DEVICE_DELETED
+type DeviceDeletedEvent struct {
+	Device string `json:"device,omitempty"`
+	Path   string `json:"path"`
+}
+
+// BLOCK_JOB_COMPLETED | BLOCK_JOB_CANCELLED | BLOCK_JOB_READY
+type BlockJobEvent struct {
+	Type   string `json:"type"` // commit | stream | mirror | backup | create
+	Device string `json:"device"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Speed  int64  `json:"speed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BLOCK_JOB_ERROR
+type BlockJobErrorEvent struct {
+	Device    string `json:"device"`
+	Operation string `json:"operation"` // read | write
+	Action    string `json:"action"`    // ignore | report | stop
+}
+
+// JOB_STATUS_CHANGE
+type JobStatusChangeEvent struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // created | running | paused | ready | standby | waiting | pending | aborting | concluded | undefined | null
+}
+
+// MIGRATION
+type MigrationEvent struct {
+	Status string `json:"status"`
+}
+
+// MIGRATION_PASS
+type MigrationPassEvent struct {
+	Pass int `json:"pass"`
+}
+
+/*
+usage:
+
+	v, err := ev.Decode()
+	switch e := v.(type) {
+	case *virt.ShutdownEvent:
+	case virt.Event: // not typed
+	}
+
+return a pointer to the typed struct of known events or the Event itself
+*/
+func (e Event) Decode() (any, error) {
+	var v any
+	switch e.Name {
+	default:
+		return e, nil
+	case EventShutdown:
+		v = &ShutdownEvent{}
+	case EventReset:
+		v = &ResetEvent{}
+	case EventStop:
+		return &StopEvent{}, nil
+	case EventResume:
+		return &ResumeEvent{}, nil
+	case EventGuestPanicked:
+		v = &GuestPanickedEvent{}
+	case EventDeviceDeleted:
+		v = &DeviceDeletedEvent{}
+	case EventBlockJobCompleted, EventBlockJobCancelled, EventBlockJobReady:
+		v = &BlockJobEvent{}
+	case EventBlockJobError:
+		v = &BlockJobErrorEvent{}
+	case EventJobStatusChange:
+		v = &JobStatusChangeEvent{}
+	case EventMigration:
+		v = &MigrationEvent{}
+	case EventMigrationPass:
+		v = &MigrationPassEvent{}
+	}
+	if len(e.Data) == 0 {
+		return v, nil
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+/*
+usage:
+
+	sub := c.Subscribe(virt.EventShutdown, virt.EventReset)
+	defer sub.Close()
+	for ev := range sub.C { ... }
+
+events delivered to a subscriber, C is closed by Close or when the connection is lost
+*/
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	names  map[string]bool
+	client *Client
+}
+
+// subscribe to the given events, all events when names is empty
+func (c *Client) Subscribe(names ...string) *Subscription {
+	ch := make(chan Event, EventBufferSize)
+	s := &Subscription{C: ch, c: ch, client: c}
+	if len(names) > 0 {
+		s.names = map[string]bool{}
+		for _, n := range names {
+			s.names[n] = true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		close(ch)
+	default:
+		c.subs[s] = struct{}{}
+	}
+	return s
+}
+
+func (s *Subscription) Close() {
+	c := s.client
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.subs[s]; ok {
+		delete(c.subs, s)
+		close(s.c)
+	}
+}
+
+func (c *Client) publish(msg *qmpMessage) {
+	ev := Event{Name: msg.Event, Data: msg.Data}
+	if msg.Timestamp != nil {
+		ev.Timestamp = time.Unix(msg.Timestamp.Seconds, msg.Timestamp.Microseconds*1000)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for s := range c.subs {
+		if s.names != nil && !s.names[ev.Name] {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default: // subscriber is full
+		}
+	}
+}
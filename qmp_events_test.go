@@ -0,0 +1,54 @@
+package virt
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribeWhileClosing(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "qmp.sock")
+	newFakeQmp(t, sock)
+	for range 50 {
+		c, err := DialQmp(context.Background(), "unix:"+sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// subscribe until a subscription comes closed, the ones before must be closed too
+		var mu sync.Mutex
+		var subs []*Subscription
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					s := c.Subscribe(EventShutdown)
+					mu.Lock()
+					subs = append(subs, s)
+					mu.Unlock()
+					select {
+					case <-s.C:
+						return
+					default:
+					}
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		c.Close()
+		wg.Wait()
+		for _, s := range subs {
+			select {
+			case _, ok := <-s.C:
+				if ok {
+					t.Fatal("event on a closed client")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("subscription of a closed client left open")
+			}
+		}
+	}
+}
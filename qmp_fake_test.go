@@ -0,0 +1,181 @@
+package virt
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+)
+
+const fakeGreeting = `{"QMP": {"version": {"qemu": {"micro": 0, "minor": 2, "major": 9}, "package": ""}, "capabilities": ["oob"]}}`
+
+// one exchange of a scripted QMP session: the command expected, its reply and the events sent after it
+type qmpStep struct {
+	cmd    string
+	ret    string   // JSON of the return value, {} when empty
+	err    string   // desc of a GenericError replied instead of ret
+	events []string // JSON of each event
+}
+
+type qmpCall struct {
+	cmd  string
+	args map[string]any
+}
+
+/*
+a QMP endpoint on a unix socket replaying a script: the greeting, then the
+reply and the events of each step as its command arrives. qmp_capabilities
+is answered outside the script, the steps are shared by every connection. A
+command out of the script fails the test and gets a GenericError.
+*/
+type fakeQmp struct {
+	t        *testing.T
+	greeting string
+	mu       sync.Mutex
+	steps    []qmpStep
+	calls    []qmpCall
+	conns    map[net.Conn]func(string)
+}
+
+func newFakeQmp(t *testing.T, sock string, steps ...qmpStep) *fakeQmp {
+	t.Helper()
+	f := &fakeQmp{t: t, greeting: fakeGreeting, steps: steps, conns: map[net.Conn]func(string){}}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		l.Close()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, s := range f.steps {
+			t.Errorf("qmp: %s not executed", s.cmd)
+		}
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// append steps to the script
+func (f *fakeQmp) script(steps ...qmpStep) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.steps = append(f.steps, steps...)
+}
+
+// the commands received so far, without qmp_capabilities
+func (f *fakeQmp) received() []qmpCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]qmpCall{}, f.calls...)
+}
+
+// the arguments of the last cmd received
+func (f *fakeQmp) args(cmd string) map[string]any {
+	calls := f.received()
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].cmd == cmd {
+			return calls[i].args
+		}
+	}
+	f.t.Errorf("qmp: %s not received", cmd)
+	return nil
+}
+
+// send events to the negotiated connections, returns how many got them
+func (f *fakeQmp) emit(events ...string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, send := range f.conns {
+		for _, ev := range events {
+			send(ev)
+		}
+	}
+	return len(f.conns)
+}
+
+// close every connection, as QEMU does when it exits
+func (f *fakeQmp) hangup() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeQmp) next(cmd string, args map[string]any) qmpStep {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cmd == "qmp_capabilities" {
+		return qmpStep{cmd: cmd}
+	}
+	f.calls = append(f.calls, qmpCall{cmd, args})
+	if len(f.steps) == 0 {
+		f.t.Errorf("qmp: unexpected %s %v", cmd, args)
+		return qmpStep{cmd: cmd, err: "not in the script"}
+	}
+	s := f.steps[0]
+	if s.cmd != cmd {
+		f.t.Errorf("qmp: got %s %v, want %s", cmd, args, s.cmd)
+		return qmpStep{cmd: cmd, err: "not in the script"}
+	}
+	f.steps = f.steps[1:]
+	return s
+}
+
+func (f *fakeQmp) serve(conn net.Conn) {
+	defer conn.Close()
+	w := bufio.NewWriter(conn)
+	var wmu sync.Mutex
+	send := func(line string) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		w.WriteString(line + "\n")
+		w.Flush()
+	}
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+	}()
+	send(f.greeting)
+	dec := json.NewDecoder(conn)
+	for {
+		var msg struct {
+			Execute   string          `json:"execute"`
+			Arguments map[string]any  `json:"arguments"`
+			ID        json.RawMessage `json:"id"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		s := f.next(msg.Execute, msg.Arguments)
+		reply := map[string]any{"id": msg.ID}
+		if s.err != "" {
+			reply["error"] = map[string]any{"class": "GenericError", "desc": s.err}
+		} else if s.ret != "" {
+			reply["return"] = json.RawMessage(s.ret)
+		} else {
+			reply["return"] = map[string]any{}
+		}
+		b, _ := json.Marshal(reply)
+		send(string(b))
+		if msg.Execute == "qmp_capabilities" {
+			f.mu.Lock()
+			f.conns[conn] = send
+			f.mu.Unlock()
+		}
+		for _, ev := range s.events {
+			send(ev)
+		}
+	}
+}
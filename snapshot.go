@@ -0,0 +1,604 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var ErrSnapshot = errors.New("snapshot inválido")
+
+type SnapshotKind int
+
+const (
+	SnapshotInternal SnapshotKind = iota // inside the qcow2 images, with the RAM when taken while running
+	SnapshotExternal                     // the images are frozen and the writes go to new qcow2 overlays
+)
+
+func (k SnapshotKind) String() string {
+	if k == SnapshotExternal {
+		return "external"
+	}
+	return "internal"
+}
+
+func (k SnapshotKind) MarshalYAML() (any, error) {
+	return k.String(), nil
+}
+
+func (k *SnapshotKind) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Value {
+	default:
+		return fmt.Errorf("snapshot inválido: %s", value.Value)
+	case "internal":
+		*k = SnapshotInternal
+	case "external":
+		*k = SnapshotExternal
+	}
+	return nil
+}
+
+// a disk as seen by a snapshot: the node holding it (internal) or frozen by it (external)
+type SnapshotDisk struct {
+	Node   string
+	File   string
+	Format BlockdevDriver
+}
+
+type Snapshot struct {
+	Name    string
+	Parent  string `yaml:",omitempty"`
+	Kind    SnapshotKind
+	VMState string `yaml:",omitempty"` // node with the RAM state, internal snapshots taken while running
+	Created time.Time
+	Disks   map[string]*SnapshotDisk // by disk name
+}
+
+/*
+the snapshots of a guest, kept in <VmDataPath>/<name>.snapshots.yaml. Each
+snapshot is a child of the one that was current when it was taken.
+
+External snapshots rename the active nodes of a disk ("disk0" becomes
+"disk0-snap1" over the image disk0.snap1.qcow2), Active maps the disk to it.
+*/
+type SnapshotTree struct {
+	Current   string            `yaml:",omitempty"`
+	Active    map[string]string `yaml:",omitempty"` // disk -> active format node
+	Snapshots []*Snapshot       `yaml:",omitempty"`
+}
+
+func (t *SnapshotTree) Get(name string) *Snapshot {
+	for _, s := range t.Snapshots {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (t *SnapshotTree) Children(name string) []*Snapshot {
+	children := []*Snapshot{}
+	for _, s := range t.Snapshots {
+		if s.Parent == name {
+			children = append(children, s)
+		}
+	}
+	return children
+}
+
+// images held by the snapshots: frozen by external ones or holding internal ones
+func (t *SnapshotTree) files() []string {
+	files := []string{}
+	for _, s := range t.Snapshots {
+		for _, d := range s.Disks {
+			if !slices.Contains(files, d.File) {
+				files = append(files, d.File)
+			}
+		}
+	}
+	return files
+}
+
+func (m *Manager) snapshotsPath(name string) string {
+	return path.Join(m.dataPath(), fmt.Sprintf("%s.snapshots.yaml", name))
+}
+
+func (m *Manager) loadSnapshots(name string) (*SnapshotTree, error) {
+	t := &SnapshotTree{}
+	data, err := os.ReadFile(m.snapshotsPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *Manager) saveSnapshots(name string, t *SnapshotTree) error {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.snapshotsPath(name), data)
+}
+
+// a disk of the guest: a qcow2 or raw node over a file node
+type guestDisk struct {
+	name string
+	node *BlockNode // format node
+	file *BlockNode // file node
+}
+
+// the disks of BlockDevices.Nodes, backing and data-file nodes excluded
+func (g *Guest) snapshotDisks(t *SnapshotTree) []guestDisk {
+	if g.BlockDevices == nil {
+		return nil
+	}
+	byName := map[string]*BlockNode{}
+	below := map[string]bool{}
+	for _, n := range g.BlockDevices.Nodes {
+		byName[n.NodeName] = n
+		for _, c := range n.children() {
+			if c.Key != "file" {
+				below[c.Value] = true
+			}
+		}
+	}
+	disks := []guestDisk{}
+	for _, n := range g.BlockDevices.Nodes {
+		if n.Format == nil || below[n.NodeName] || (n.Driver != BlockdevDriverQcow2 && n.Driver != BlockdevDriverRaw) {
+			continue
+		}
+		f := byName[n.Format.File]
+		if f == nil || f.File == nil {
+			continue
+		}
+		name := n.NodeName
+		for disk, active := range t.Active {
+			if active == n.NodeName {
+				name = disk
+			}
+		}
+		disks = append(disks, guestDisk{name: name, node: n, file: f})
+	}
+	return disks
+}
+
+// rename a node and every reference to it, in other nodes and in -device drive=
+func (g *Guest) renameNode(old, name string) {
+	for _, n := range g.BlockDevices.Nodes {
+		if n.NodeName == old {
+			n.NodeName = name
+		}
+		switch d := n.driverOptions(false).(type) {
+		case *BlockFormatOptions:
+			for _, ref := range []*string{&d.File, &d.Backing, &d.DataFile} {
+				if *ref == old {
+					*ref = name
+				}
+			}
+		case *BlockLuksOptions:
+			if d.File == old {
+				d.File = name
+			}
+		case *BlockThrottleOptions:
+			if d.File == old {
+				d.File = name
+			}
+		}
+	}
+	for _, d := range g.Devices {
+		if d.prop("drive") == old {
+			d.setProp("drive", name)
+		}
+	}
+}
+
+// point the disk to a qcow2 overlay: the nodes become "<node>" and "<node>-file"
+func (g *Guest) setOverlay(d guestDisk, node, file string) {
+	g.renameNode(d.file.NodeName, node+"-file")
+	g.renameNode(d.node.NodeName, node)
+	d.file.Driver = BlockdevDriverFile
+	d.file.File.Filename = file
+	d.node.Driver = BlockdevDriverQcow2
+}
+
+// <image without extension>.<tag>.qcow2
+func overlayFile(file, tag string) string {
+	return strings.TrimSuffix(file, path.Ext(file)) + "." + tag + ".qcow2"
+}
+
+// letters, digits, '-', '_' and '.', it goes in node names and file names
+func validSnapshotName(name string) bool {
+	if name == "" || len(name) > 20 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+usage:
+
+	s, err := m.CreateSnapshot(ctx, "web01", "before-upgrade", virt.SnapshotExternal)
+
+snapshot the disks of BlockDevices.Nodes. While the guest runs, internal
+snapshots are taken by a snapshot-save job (with the RAM) and external ones
+by blockdev-snapshot-sync; stopped guests use qemu-img. Internal snapshots
+need qcow2 disks.
+*/
+func (m *Manager) CreateSnapshot(ctx context.Context, name, snap string, kind SnapshotKind) (*Snapshot, error) {
+	if !validSnapshotName(snap) {
+		return nil, fmt.Errorf("%w: nome %q", ErrSnapshot, snap)
+	}
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return nil, err
+	}
+	if t.Get(snap) != nil {
+		return nil, fmt.Errorf("%w: snapshot %s", os.ErrExist, snap)
+	}
+	disks := g.snapshotDisks(t)
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("%w: guest sem discos em BlockDevices.Nodes", ErrSnapshot)
+	}
+
+	s := &Snapshot{Name: snap, Parent: t.Current, Kind: kind, Created: time.Now(), Disks: map[string]*SnapshotDisk{}}
+	for _, d := range disks {
+		s.Disks[d.name] = &SnapshotDisk{Node: d.node.NodeName, File: d.file.File.Filename, Format: d.node.Driver}
+	}
+
+	var c *Client
+	if m.guestRunning(name) {
+		if c, err = dialGuest(ctx, g); err != nil {
+			return nil, err
+		}
+		defer c.Close()
+	}
+
+	switch kind {
+	case SnapshotInternal:
+		nodes := []string{}
+		for _, d := range disks {
+			if d.node.Driver != BlockdevDriverQcow2 {
+				return nil, fmt.Errorf("%w: snapshot interno exige qcow2: %s", ErrSnapshot, d.node.NodeName)
+			}
+			nodes = append(nodes, d.node.NodeName)
+		}
+		if c != nil {
+			s.VMState = nodes[0]
+			id := "snapshot-save-" + snap
+			err = runJob(ctx, c, id, func() error {
+				return c.SnapshotSave(ctx, &SnapshotSaveArguments{JobID: id, Tag: snap, Vmstate: s.VMState, Devices: nodes})
+			})
+		} else {
+			for _, d := range disks {
+				if _, err = qemuImg("snapshot", "-c", snap, d.file.File.Filename); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	case SnapshotExternal:
+		if t.Active == nil {
+			t.Active = map[string]string{}
+		}
+		for _, d := range disks {
+			file, node := overlayFile(d.file.File.Filename, snap), d.name+"-"+snap
+			if c != nil {
+				err = c.BlockdevSnapshotSync(ctx, &BlockdevSnapshotSync{
+					NodeName:         d.node.NodeName,
+					SnapshotFile:     file,
+					SnapshotNodeName: node,
+					Format:           string(BlockdevDriverQcow2),
+					Mode:             NewImageModeAbsolutePaths,
+				})
+			} else {
+				err = CreateImage(file, &ImageOptions{BackingFile: d.file.File.Filename, BackingFormat: d.node.Driver})
+			}
+			if err != nil {
+				// the disks already switched stay on their overlays, the definition must follow them
+				if perr := m.Store().Put(g); perr != nil {
+					return nil, errors.Join(err, perr)
+				}
+				return nil, err
+			}
+			g.setOverlay(d, node, file)
+			t.Active[d.name] = node
+		}
+		if err := m.Store().Put(g); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: tipo %d", ErrSnapshot, kind)
+	}
+
+	t.Snapshots = append(t.Snapshots, s)
+	t.Current = snap
+	if err := m.saveSnapshots(name, t); err != nil {
+		return nil, err
+	}
+	m.log.Info("snapshot created", "guest", name, "snapshot", snap, "kind", kind)
+	return s, nil
+}
+
+/*
+go back to a snapshot. Internal snapshots are loaded by a snapshot-load job
+while the guest runs (only those taken with the RAM) or by qemu-img when it is
+stopped. External snapshots need the guest stopped: each disk gets a new
+overlay over the frozen image, the definition is saved and only then the
+overlays left behind are removed, unless another snapshot holds them or
+another guest (a linked clone) uses them.
+*/
+func (m *Manager) RevertSnapshot(ctx context.Context, name, snap string) error {
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return err
+	}
+	s := t.Get(snap)
+	if s == nil {
+		return fmt.Errorf("%w: snapshot %s", os.ErrNotExist, snap)
+	}
+	running := m.guestRunning(name)
+
+	disks := map[string]guestDisk{}
+	for _, d := range g.snapshotDisks(t) {
+		disks[d.name] = d
+	}
+
+	switch s.Kind {
+	case SnapshotInternal:
+		// an image frozen by a later external snapshot is the backing of the active one
+		nodes := []string{}
+		for disk, sd := range s.Disks {
+			if d, ok := disks[disk]; !ok || d.node.NodeName != sd.Node {
+				return fmt.Errorf("%w: disco %s não está mais em %s", ErrSnapshot, disk, sd.Node)
+			}
+			nodes = append(nodes, sd.Node)
+		}
+		if !running {
+			for _, sd := range s.Disks {
+				if _, err := qemuImg("snapshot", "-a", snap, sd.File); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if s.VMState == "" {
+			return fmt.Errorf("%w: %s foi criado sem a memória, pare o guest para reverter", ErrSnapshot, snap)
+		}
+		c, err := dialGuest(ctx, g)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		id := "snapshot-load-" + snap
+		err = runJob(ctx, c, id, func() error {
+			return c.SnapshotLoad(ctx, &SnapshotLoadArguments{JobID: id, Tag: snap, Vmstate: s.VMState, Devices: nodes})
+		})
+		if err != nil {
+			return err
+		}
+
+	case SnapshotExternal:
+		if running {
+			return fmt.Errorf("%w: reverter snapshot externo exige o guest parado", ErrGuestRunning)
+		}
+		for disk := range s.Disks {
+			if _, ok := disks[disk]; !ok {
+				return fmt.Errorf("%w: disco %s não existe mais", ErrSnapshot, disk)
+			}
+		}
+		if t.Active == nil {
+			t.Active = map[string]string{}
+		}
+		// the new overlays first, the old ones go only once the definition left them
+		old := []string{}
+		for _, disk := range slices.Sorted(maps.Keys(s.Disks)) {
+			sd, d := s.Disks[disk], disks[disk]
+			tag := snap
+			for n := 1; ; n++ {
+				if _, err := os.Stat(overlayFile(sd.File, tag)); errors.Is(err, os.ErrNotExist) {
+					break
+				}
+				tag = fmt.Sprintf("%s-%d", snap, n)
+			}
+			file, node := overlayFile(sd.File, tag), disk+"-"+tag
+			if err = CreateImage(file, &ImageOptions{BackingFile: sd.File, BackingFormat: sd.Format}); err != nil {
+				break
+			}
+			old = append(old, d.file.File.Filename)
+			g.setOverlay(d, node, file)
+			t.Active[disk] = node
+		}
+		if err != nil && len(old) == 0 {
+			return err
+		}
+		// the disks already reverted stay on their new overlays, the definition must follow them
+		if perr := m.Store().Put(g); perr != nil {
+			return errors.Join(err, perr)
+		}
+		if err != nil {
+			if serr := m.saveSnapshots(name, t); serr != nil {
+				err = errors.Join(err, serr)
+			}
+		}
+		m.removeOverlays(name, old, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.Current = snap
+	if err := m.saveSnapshots(name, t); err != nil {
+		return err
+	}
+	m.log.Info("snapshot reverted", "guest", name, "snapshot", snap)
+	return nil
+}
+
+// remove the overlays a revert left, unless a snapshot or a guest (a linked clone) still uses them
+func (m *Manager) removeOverlays(name string, files []string, t *SnapshotTree) {
+	if len(files) == 0 {
+		return
+	}
+	idx, err := m.volumeIndex()
+	if err != nil {
+		m.log.Warn("overlays not removed", "guest", name, "files", files, "err", err)
+		return
+	}
+	held := t.files()
+	for _, f := range files {
+		if slices.ContainsFunc(held, func(h string) bool { return sameFile(h, f) }) || len(idx.users(f)) > 0 {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			m.log.Warn("overlay not removed", "guest", name, "file", f, "err", err)
+		}
+	}
+}
+
+/*
+remove a snapshot from the tree, its children move to its parent. Internal
+snapshots are deleted from the images (snapshot-delete job or qemu-img), the
+images of external ones stay in the backing chain of the disks. Images no
+longer used by a guest or held by another snapshot are removed.
+*/
+func (m *Manager) DeleteSnapshot(ctx context.Context, name, snap string) error {
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return err
+	}
+	s := t.Get(snap)
+	if s == nil {
+		return fmt.Errorf("%w: snapshot %s", os.ErrNotExist, snap)
+	}
+
+	if s.Kind == SnapshotInternal {
+		if m.guestRunning(name) {
+			active := map[string]bool{}
+			for _, d := range g.snapshotDisks(t) {
+				active[d.node.NodeName] = true
+			}
+			nodes := []string{}
+			for _, sd := range s.Disks {
+				if !active[sd.Node] {
+					return fmt.Errorf("%w: %s está em uma imagem de backing, pare o guest para remover", ErrSnapshot, snap)
+				}
+				nodes = append(nodes, sd.Node)
+			}
+			c, err := dialGuest(ctx, g)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			id := "snapshot-delete-" + snap
+			err = runJob(ctx, c, id, func() error {
+				return c.SnapshotDelete(ctx, &SnapshotDeleteArguments{JobID: id, Tag: snap, Devices: nodes})
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			for _, sd := range s.Disks {
+				if _, err := qemuImg("snapshot", "-d", snap, sd.File); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, c := range t.Children(snap) {
+		c.Parent = s.Parent
+	}
+	if t.Current == snap {
+		t.Current = s.Parent
+	}
+	t.Snapshots = slices.DeleteFunc(t.Snapshots, func(x *Snapshot) bool { return x == s })
+	if err := m.saveSnapshots(name, t); err != nil {
+		return err
+	}
+	// an overlay left behind by a revert goes with the last snapshot holding it
+	files := []string{}
+	for _, sd := range s.Disks {
+		files = append(files, sd.File)
+	}
+	m.removeOverlays(name, files, t)
+	m.log.Info("snapshot deleted", "guest", name, "snapshot", snap)
+	return nil
+}
+
+func (m *Manager) Snapshots(name string) (*SnapshotTree, error) {
+	if _, err := m.Store().Get(name); err != nil {
+		return nil, err
+	}
+	return m.loadSnapshots(name)
+}
+
+// snapshot the guest disks, see Manager.CreateSnapshot
+func CreateSnapshot(ctx context.Context, name, snap string, kind SnapshotKind) (*Snapshot, error) {
+	return defaultManager.CreateSnapshot(ctx, name, snap, kind)
+}
+
+// go back to a snapshot, see Manager.RevertSnapshot
+func RevertSnapshot(ctx context.Context, name, snap string) error {
+	return defaultManager.RevertSnapshot(ctx, name, snap)
+}
+
+// remove a snapshot from the tree, see Manager.DeleteSnapshot
+func DeleteSnapshot(ctx context.Context, name, snap string) error {
+	return defaultManager.DeleteSnapshot(ctx, name, snap)
+}
+
+// the snapshot tree of a guest
+func Snapshots(name string) (*SnapshotTree, error) { return defaultManager.Snapshots(name) }
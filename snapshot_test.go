@@ -0,0 +1,125 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// a stopped guest "vm1" with the qcow2 disks disk0 and disk1
+func stoppedGuest(t *testing.T) (*Manager, string) {
+	t.Helper()
+	fakeQemuImg(t, "", "0")
+	dir := t.TempDir()
+	m := NewManager(Config{
+		DataPath:    filepath.Join(dir, "data"),
+		StoragePath: filepath.Join(dir, "disks"),
+		SocketPath:  filepath.Join(dir, "sock"),
+	})
+	for _, d := range []string{"data", "disks", "sock"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := m.NewGuest("vm1")
+	g.BlockDevices = &BlockDevicesOptions{}
+	for _, disk := range []string{"disk0", "disk1"} {
+		file := filepath.Join(dir, "disks", "vm1-"+disk+".qcow2")
+		touch(t, file)
+		fileNode, node := diskNodes(disk, BlockdevDriverQcow2, file)
+		g.BlockDevices.Nodes = append(g.BlockDevices.Nodes, fileNode, node)
+	}
+	if err := m.CreateGuest(g); err != nil {
+		t.Fatal(err)
+	}
+	return m, filepath.Join(dir, "disks")
+}
+
+func touch(t *testing.T, file string) {
+	t.Helper()
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the image files of the stored guest
+func guestFiles(t *testing.T, m *Manager, name string) []string {
+	t.Helper()
+	g, err := m.Store().Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{}
+	for _, n := range g.BlockDevices.Nodes {
+		if n.File != nil {
+			files = append(files, filepath.Base(n.File.Filename))
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestRevertExternalSnapshot(t *testing.T) {
+	m, disks := stoppedGuest(t)
+	ctx := context.Background()
+	if _, err := m.CreateSnapshot(ctx, "vm1", "s1", SnapshotExternal); err != nil {
+		t.Fatal(err)
+	}
+	s1 := []string{filepath.Join(disks, "vm1-disk0.s1.qcow2"), filepath.Join(disks, "vm1-disk1.s1.qcow2")}
+	for _, f := range s1 {
+		touch(t, f)
+	}
+	// a linked clone backed by the overlay of disk1
+	clone := m.NewGuest("vm2")
+	fileNode, node := diskNodes("disk0", BlockdevDriverQcow2, s1[1])
+	clone.BlockDevices = &BlockDevicesOptions{Nodes: []*BlockNode{fileNode, node}}
+	if err := m.CreateGuest(clone); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RevertSnapshot(ctx, "vm1", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := guestFiles(t, m, "vm1"), []string{"vm1-disk0.s1-1.qcow2", "vm1-disk1.s1-1.qcow2"}; !slices.Equal(got, want) {
+		t.Errorf("files %q, want %q", got, want)
+	}
+	if _, err := os.Stat(s1[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("old overlay of disk0 kept: %v", err)
+	}
+	if _, err := os.Stat(s1[1]); err != nil {
+		t.Errorf("overlay used by vm2 removed: %v", err)
+	}
+}
+
+func TestRevertExternalSnapshotPartial(t *testing.T) {
+	m, disks := stoppedGuest(t)
+	ctx := context.Background()
+	if _, err := m.CreateSnapshot(ctx, "vm1", "s1", SnapshotExternal); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"vm1-disk0.s1.qcow2", "vm1-disk1.s1.qcow2"} {
+		touch(t, filepath.Join(disks, f))
+	}
+
+	// the overlay of disk0 is created, the one of disk1 fails
+	t.Setenv("QEMU_IMG_FAIL", "disk1")
+	if err := m.RevertSnapshot(ctx, "vm1", "s1"); err == nil {
+		t.Fatal("no error")
+	}
+	if got, want := guestFiles(t, m, "vm1"), []string{"vm1-disk0.s1-1.qcow2", "vm1-disk1.s1.qcow2"}; !slices.Equal(got, want) {
+		t.Errorf("files %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(disks, "vm1-disk1.s1.qcow2")); err != nil {
+		t.Errorf("overlay of disk1 removed: %v", err)
+	}
+	tree, err := m.loadSnapshots("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Active["disk0"] != "disk0-s1-1" || tree.Active["disk1"] != "disk1-s1" {
+		t.Errorf("active %v", tree.Active)
+	}
+}
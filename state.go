@@ -0,0 +1,200 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+type State int
+
+const (
+	StateDefined      State = iota // defined
+	StateStarting                  // starting
+	StateRunning                   // running
+	StatePaused                    // paused
+	StateShuttingDown              // shutting-down
+	StateCrashed                   // crashed
+	StateStopped                   // stopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateDefined:
+		return "defined"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateShuttingDown:
+		return "shutting-down"
+	case StateCrashed:
+		return "crashed"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// true while there is a QEMU process for the guest
+func (s State) Active() bool {
+	return s == StateStarting || s == StateRunning || s == StatePaused || s == StateShuttingDown
+}
+
+func (s State) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s *State) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Value {
+	default:
+		return fmt.Errorf("status inválido: %s", value.Value)
+	case "defined":
+		*s = StateDefined
+	case "starting":
+		*s = StateStarting
+	case "running":
+		*s = StateRunning
+	case "paused":
+		*s = StatePaused
+	case "shutting-down":
+		*s = StateShuttingDown
+	case "crashed":
+		*s = StateCrashed
+	case "stopped":
+		*s = StateStopped
+	}
+	return nil
+}
+
+// last known state, persisted in <VmDataPath>/<name>.state.yaml
+type Status struct {
+	Name      string
+	State     State
+	Pid       int       `yaml:",omitempty"`
+	RunState  RunState  `yaml:",omitempty"` // from query-status
+	ExitCode  int       `yaml:",omitempty"`
+	Reason    string    `yaml:",omitempty"` // of the SHUTDOWN event, ex: guest-shutdown, guest-panic
+	UpdatedAt time.Time //
+}
+
+func (m *Manager) stateFile(name string) string {
+	return path.Join(m.dataPath(), fmt.Sprintf("%s.state.yaml", name))
+}
+
+func (m *Manager) loadState(name string) (*Status, error) {
+	data, err := os.ReadFile(m.stateFile(name))
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{}
+	if err := yaml.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (m *Manager) saveState(st *Status) error {
+	st.UpdatedAt = time.Now()
+	data, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.stateFile(st.Name), data)
+}
+
+func (m *Manager) setState(name string, s State, pid int) error {
+	return m.saveState(&Status{Name: name, State: s, Pid: pid})
+}
+
+// record how a supervised process ended, see Supervisor.OnExit
+func (m *Manager) recordExit(i *Instance) {
+	last, err := m.loadState(i.Name)
+	if err == nil && last.Pid != 0 && last.Pid != i.Pid {
+		return // restarted meanwhile
+	}
+	st := &Status{Name: i.Name, State: StateCrashed, ExitCode: i.ExitCode(), Reason: i.ShutdownReason()}
+	switch {
+	case st.ExitCode == 0, err == nil && last.State == StateShuttingDown:
+		st.State = StateStopped
+	case st.ExitCode == -1 && st.Reason != "" && st.Reason != "guest-panic":
+		// not a child, QEMU shut down after a SHUTDOWN event
+		st.State = StateStopped
+	}
+	m.log.Info("guest exited", "guest", i.Name, "pid", i.Pid, "state", st.State, "code", st.ExitCode, "reason", st.Reason)
+	m.saveState(st)
+}
+
+func stateFromRunState(r RunState) State {
+	switch r {
+	case RunStateRunning:
+		return StateRunning
+	case RunStateInmigrate, RunStatePrelaunch, RunStateRestoreVM:
+		return StateStarting
+	case RunStateShutdown:
+		return StateShuttingDown
+	case RunStateGuestPanicked, RunStateInternalError:
+		return StateCrashed
+	default: // paused, suspended, io-error, watchdog, debug ...
+		return StatePaused
+	}
+}
+
+/*
+usage:
+
+	st, err := virt.GuestStatus("guestName")
+	fmt.Println(st.State, st.RunState)
+
+combine process liveness, QMP query-status and the last persisted state, the result is persisted again
+*/
+func GuestStatus(name string) (*Status, error) { return defaultManager.GuestStatus(name) }
+
+func (m *Manager) GuestStatus(name string) (*Status, error) {
+	g, err := m.LoadGuest(name)
+	if err != nil {
+		return nil, err
+	}
+	last, err := m.loadState(name)
+	if errors.Is(err, os.ErrNotExist) {
+		last = &Status{Name: name, State: StateDefined}
+	} else if err != nil {
+		return nil, err
+	}
+
+	inst, err := m.Supervisor().Attach(name)
+	if err != nil {
+		if !last.State.Active() {
+			return last, nil
+		}
+		// the process is gone and its exit was not recorded
+		st := &Status{Name: name, State: StateCrashed, ExitCode: last.ExitCode}
+		if last.State == StateShuttingDown {
+			st.State = StateStopped
+		}
+		return st, m.saveState(st)
+	}
+
+	st := &Status{Name: name, State: StateRunning, Pid: inst.Pid}
+	if last.State == StateStarting || last.State == StateShuttingDown {
+		st.State = last.State
+	}
+	if c, err := dialGuest(context.Background(), g); err == nil {
+		defer c.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), QmpDialTimeout)
+		defer cancel()
+		if info, err := c.QueryStatus(ctx); err == nil {
+			st.RunState = info.Status
+			st.State = stateFromRunState(info.Status)
+		}
+	}
+	return st, m.saveState(st)
+}
@@ -0,0 +1,69 @@
+package virt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecordExitShutdownReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   State
+	}{
+		{"guest-shutdown", StateStopped},
+		{"host-signal", StateStopped},
+		{"guest-panic", StateCrashed},
+		{"", StateCrashed}, // no SHUTDOWN event, the process just died
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.String()+"/"+tt.reason, func(t *testing.T) {
+			dir := t.TempDir()
+			m := NewManager(Config{DataPath: dir})
+			f := newFakeQmp(t, m.Supervisor().eventsSocket("vm1"))
+
+			// an attached QEMU, its exit code is unknown
+			cmd := startFakeQemu(t, m.Supervisor(), "vm1")
+			reaped := make(chan struct{})
+			go func() {
+				cmd.Wait()
+				close(reaped)
+			}()
+			defer cmd.Process.Kill()
+			inst, err := m.Supervisor().Attach("vm1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			if tt.reason != "" {
+				ev := fmt.Sprintf(`{"event": "SHUTDOWN", "data": {"guest": true, "reason": %q}, "timestamp": {"seconds": 1, "microseconds": 0}}`, tt.reason)
+				// until the watcher is subscribed
+				for inst.ShutdownReason() == "" {
+					if time.Now().After(deadline) {
+						t.Fatal("SHUTDOWN not seen")
+					}
+					f.emit(ev)
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			cmd.Process.Kill()
+			<-reaped
+			f.hangup()
+
+			for {
+				st, err := m.loadState("vm1")
+				if err == nil {
+					if st.State != tt.want || st.Reason != tt.reason || st.ExitCode != -1 {
+						t.Errorf("status %+v, want %s", st, tt.want)
+					}
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("exit not recorded: %v", err)
+				}
+				time.Sleep(PollInterval)
+			}
+		})
+	}
+}
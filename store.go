@@ -0,0 +1,493 @@
+package virt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	yaml "gopkg.in/yaml.v3"
+)
+
+type StoreEventType int
+
+const (
+	StorePut    StoreEventType = iota // put
+	StoreDelete                       // delete
+)
+
+func (t StoreEventType) String() string {
+	if t == StoreDelete {
+		return "delete"
+	}
+	return "put"
+}
+
+type StoreEvent struct {
+	Type  StoreEventType
+	Name  string
+	Guest *Guest // nil on StoreDelete
+}
+
+/*
+persistence of guest definitions
+
+Get returns os.ErrNotExist for unknown guests, Put creates or replaces and
+Watch streams changes until ctx is done.
+*/
+type Store interface {
+	Get(name string) (*Guest, error)
+	Put(g *Guest) error
+	List() ([]*Guest, error)
+	Delete(name string) error
+	Watch(ctx context.Context) (<-chan StoreEvent, error)
+}
+
+var DefaultStore Store = NewDirStore("")
+
+func marshalGuest(g *Guest) ([]byte, error) { return yaml.Marshal(g) }
+
+func unmarshalGuest(data []byte) (*Guest, error) {
+	g := &Guest{}
+	if err := yaml.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// in-process subscribers of a store
+type watchers struct {
+	mu   sync.Mutex
+	subs map[chan StoreEvent]struct{}
+}
+
+func (w *watchers) add(ctx context.Context) <-chan StoreEvent {
+	ch := make(chan StoreEvent, EventBufferSize)
+	w.mu.Lock()
+	if w.subs == nil {
+		w.subs = map[chan StoreEvent]struct{}{}
+	}
+	w.subs[ch] = struct{}{}
+	w.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		delete(w.subs, ch)
+		close(ch)
+		w.mu.Unlock()
+	})
+	return ch
+}
+
+func (w *watchers) notify(ev StoreEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs {
+		select {
+		case ch <- ev:
+		default: // watcher is full
+		}
+	}
+}
+
+/*
+usage:
+
+	store := virt.NewDirStore("/var/lib/virt/guests")
+
+one <name>.yaml per guest, the layout used by LoadGuest/CreateGuest.
+An empty Dir follows VmDataPath.
+*/
+type DirStore struct {
+	Dir string
+}
+
+func NewDirStore(dir string) *DirStore { return &DirStore{Dir: dir} }
+
+func (s *DirStore) dir() string {
+	if s.Dir == "" {
+		return VmDataPath
+	}
+	return s.Dir
+}
+
+func (s *DirStore) file(name string) string { return path.Join(s.dir(), fmt.Sprintf("%s.yaml", name)) }
+
+func (s *DirStore) Get(name string) (*Guest, error) { return loadGuest(s.file(name)) }
+
+func (s *DirStore) Put(g *Guest) error {
+	data, err := marshalGuest(g)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.file(g.Name), data)
+}
+
+func (s *DirStore) names() (map[string]time.Time, error) {
+	entries, err := os.ReadDir(s.dir())
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]time.Time{}
+	for _, e := range entries {
+		file := e.Name()
+		if e.IsDir() || strings.HasPrefix(file, ".") || !strings.HasSuffix(file, ".yaml") || isGuestMeta(file) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		names[strings.TrimSuffix(file, ".yaml")] = info.ModTime()
+	}
+	return names, nil
+}
+
+func (s *DirStore) List() ([]*Guest, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	guests := []*Guest{}
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		g, err := s.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		guests = append(guests, g)
+	}
+	return guests, nil
+}
+
+func (s *DirStore) Delete(name string) error { return os.Remove(s.file(name)) }
+
+// poll the directory every PollInterval, changes made by other processes are seen too
+func (s *DirStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	last, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StoreEvent, EventBufferSize)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(PollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			cur, err := s.names()
+			if err != nil {
+				continue
+			}
+			events := []StoreEvent{}
+			for name, mod := range cur {
+				if old, ok := last[name]; ok && old.Equal(mod) {
+					continue
+				}
+				g, err := s.Get(name)
+				if err != nil {
+					continue
+				}
+				events = append(events, StoreEvent{Type: StorePut, Name: name, Guest: g})
+			}
+			for name := range last {
+				if _, ok := cur[name]; !ok {
+					events = append(events, StoreEvent{Type: StoreDelete, Name: name})
+				}
+			}
+			last = cur
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+/*
+guests kept in memory, for tests and throwaway managers.
+Guests are copied on Put and Get.
+*/
+type MemoryStore struct {
+	mu     sync.RWMutex
+	guests map[string][]byte
+	w      watchers
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{guests: map[string][]byte{}} }
+
+func (s *MemoryStore) Get(name string) (*Guest, error) {
+	s.mu.RLock()
+	data, ok := s.guests[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return unmarshalGuest(data)
+}
+
+func (s *MemoryStore) Put(g *Guest) error {
+	data, err := marshalGuest(g)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.guests[g.Name] = data
+	s.mu.Unlock()
+
+	cp, _ := unmarshalGuest(data)
+	s.w.notify(StoreEvent{Type: StorePut, Name: g.Name, Guest: cp})
+	return nil
+}
+
+func (s *MemoryStore) List() ([]*Guest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	guests := []*Guest{}
+	for _, name := range slices.Sorted(maps.Keys(s.guests)) {
+		g, err := unmarshalGuest(s.guests[name])
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, g)
+	}
+	return guests, nil
+}
+
+func (s *MemoryStore) Delete(name string) error {
+	s.mu.Lock()
+	_, ok := s.guests[name]
+	delete(s.guests, name)
+	s.mu.Unlock()
+	if !ok {
+		return os.ErrNotExist
+	}
+	s.w.notify(StoreEvent{Type: StoreDelete, Name: name})
+	return nil
+}
+
+func (s *MemoryStore) Watch(ctx context.Context) (<-chan StoreEvent, error) { return s.w.add(ctx), nil }
+
+/*
+usage:
+
+	store, err := virt.OpenBoltStore("/var/lib/virt/guests.db")
+
+bbolt database in a single file, for hosts with hundreds of guests. The file
+is opened for every operation and bbolt holds flock(2) on it meanwhile, so
+managers of other processes share it: reads take a shared lock, Put and
+Delete an exclusive one, both wait up to LockTimeout. Every Put and Delete
+bumps a revision that Watch polls every PollInterval, changes made by other
+processes are seen too.
+*/
+type BoltStore struct {
+	path string
+}
+
+var (
+	boltGuests = []byte("guests") // name -> yaml
+	boltRevs   = []byte("revs")   // name -> revision of the last put, the bucket sequence is the last revision
+)
+
+// create the file and its buckets
+func OpenBoltStore(p string) (*BoltStore, error) {
+	s := &BoltStore{path: p}
+	if err := s.update(func(*bolt.Tx) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0o644, &bolt.Options{Timeout: LockTimeout, ReadOnly: readOnly})
+	if errors.Is(err, bolt.ErrTimeout) {
+		err = ErrGuestBusy
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bolt store %s: %w", s.path, err)
+	}
+	return db, nil
+}
+
+func (s *BoltStore) view(fn func(*bolt.Tx) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (s *BoltStore) update(fn func(*bolt.Tx) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{boltGuests, boltRevs} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+	return errors.Join(err, db.Close())
+}
+
+func (s *BoltStore) Get(name string) (*Guest, error) {
+	var g *Guest
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltGuests).Get([]byte(name))
+		if data == nil {
+			return os.ErrNotExist
+		}
+		var err error
+		g, err = unmarshalGuest(data)
+		return err
+	})
+	return g, err
+}
+
+func (s *BoltStore) Put(g *Guest) error {
+	if g.Name == "" {
+		return fmt.Errorf("nome inválido: %q", g.Name)
+	}
+	data, err := marshalGuest(g)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		revs := tx.Bucket(boltRevs)
+		rev, err := revs.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := revs.Put([]byte(g.Name), binary.BigEndian.AppendUint64(nil, rev)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltGuests).Put([]byte(g.Name), data)
+	})
+}
+
+func (s *BoltStore) List() ([]*Guest, error) {
+	guests := []*Guest{}
+	err := s.view(func(tx *bolt.Tx) error {
+		// keys are sorted
+		return tx.Bucket(boltGuests).ForEach(func(k, v []byte) error {
+			g, err := unmarshalGuest(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+			guests = append(guests, g)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return guests, nil
+}
+
+func (s *BoltStore) Delete(name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		guests, revs := tx.Bucket(boltGuests), tx.Bucket(boltRevs)
+		if guests.Get([]byte(name)) == nil {
+			return os.ErrNotExist
+		}
+		if _, err := revs.NextSequence(); err != nil {
+			return err
+		}
+		if err := revs.Delete([]byte(name)); err != nil {
+			return err
+		}
+		return guests.Delete([]byte(name))
+	})
+}
+
+// the last revision and the revision of every guest
+func boltRevisions(tx *bolt.Tx) (uint64, map[string]uint64) {
+	b := tx.Bucket(boltRevs)
+	revs := map[string]uint64{}
+	b.ForEach(func(k, v []byte) error {
+		revs[string(k)] = binary.BigEndian.Uint64(v)
+		return nil
+	})
+	return b.Sequence(), revs
+}
+
+// poll the revisions every PollInterval, as DirStore.Watch polls the directory
+func (s *BoltStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	var seq uint64
+	var last map[string]uint64
+	err := s.view(func(tx *bolt.Tx) error {
+		seq, last = boltRevisions(tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StoreEvent, EventBufferSize)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(PollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			events := []StoreEvent{}
+			err := s.view(func(tx *bolt.Tx) error {
+				cur, revs := boltRevisions(tx)
+				if cur == seq {
+					return nil
+				}
+				guests := tx.Bucket(boltGuests)
+				for name, rev := range revs {
+					if old, ok := last[name]; ok && old == rev {
+						continue
+					}
+					g, err := unmarshalGuest(guests.Get([]byte(name)))
+					if err != nil {
+						continue
+					}
+					events = append(events, StoreEvent{Type: StorePut, Name: name, Guest: g})
+				}
+				for name := range last {
+					if _, ok := revs[name]; !ok {
+						events = append(events, StoreEvent{Type: StoreDelete, Name: name})
+					}
+				}
+				seq, last = cur, revs
+				return nil
+			})
+			if err != nil {
+				continue
+			}
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,104 @@
+package virt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltStoreShared(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "guests.db")
+	a, err := OpenBoltStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another manager, or another process, on the same file
+	b, err := OpenBoltStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := b.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Put(&Guest{Name: "vm1", K: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := b.Get("vm1"); err != nil || g.K != "1" {
+		t.Fatalf("put of the other store not seen: %+v, %v", g, err)
+	}
+	next := func() StoreEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-ctx.Done():
+			t.Fatal("changes of the other store not watched")
+			return StoreEvent{}
+		}
+	}
+	if ev := next(); ev.Type != StorePut || ev.Name != "vm1" || ev.Guest.K != "1" {
+		t.Errorf("event %+v", ev)
+	}
+
+	if err := a.Delete("vm1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Delete("vm1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("second delete: %v", err)
+	}
+	if ev := next(); ev.Type != StoreDelete || ev.Name != "vm1" {
+		t.Errorf("event %+v", ev)
+	}
+}
+
+// a crash while the last commit wrote its meta page
+func TestBoltStoreTornMeta(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "guests.db")
+	s, err := OpenBoltStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(&Guest{Name: "vm1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(&Guest{Name: "vm2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the two meta pages: page header (16), then txid at 48 and checksum at 56
+	page := os.Getpagesize()
+	txid := func(i int) uint64 { return binary.LittleEndian.Uint64(data[i*page+16+48:]) }
+	last := 0
+	if txid(1) > txid(0) {
+		last = 1
+	}
+	data[last*page+16+56] ^= 0xff
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	guests, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guests) != 1 || guests[0].Name != "vm1" {
+		t.Fatalf("guests %v, want the commit before the torn one", guests)
+	}
+	if err := s.Put(&Guest{Name: "vm3"}); err != nil {
+		t.Fatal(err)
+	}
+	if guests, err := s.List(); err != nil || len(guests) != 2 || guests[1].Name != "vm3" {
+		t.Errorf("guests %v, %v", guests, err)
+	}
+}
@@ -0,0 +1,474 @@
+package virt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	LogMaxSize   int64 = 10 << 20        // rotate <name>.log after 10MB
+	LogBackups         = 3               // <name>.log.1 ... <name>.log.3
+	StartGrace         = 1 * time.Second // a foreground guest that exits before this failed to start
+	PollInterval       = 200 * time.Millisecond
+
+	ErrNotRunning = errors.New("guest não está em execução")
+)
+
+const unixPathMax = 108 // sun_path, with the ending NUL
+
+// returned when QEMU exits before the guest is up
+type StartError struct {
+	Guest    string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *StartError) Error() string {
+	msg := fmt.Sprintf("start %s: %v", e.Guest, e.Err)
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		msg += ": " + s
+	}
+	return msg
+}
+
+func (e *StartError) Unwrap() error { return e.Err }
+
+/*
+a running QEMU process, started by this process or attached from its pidfile
+*/
+type Instance struct {
+	Name string
+	Pid  int
+
+	proc  *os.Process
+	cmd   *exec.Cmd // nil when daemonized or attached
+	done  chan struct{}
+	state *os.ProcessState
+	err   error
+
+	mu      sync.Mutex
+	reason  string        // of the SHUTDOWN event
+	watched chan struct{} // closed when the events monitor is gone
+}
+
+// closed when the process exits
+func (i *Instance) Done() <-chan struct{} { return i.done }
+
+// wait for the process to exit
+func (i *Instance) Wait(ctx context.Context) error {
+	select {
+	case <-i.done:
+		return i.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Instance) Signal(sig os.Signal) error {
+	select {
+	case <-i.done:
+		return ErrNotRunning
+	default:
+	}
+	return i.proc.Signal(sig)
+}
+
+func (i *Instance) Kill() error { return i.Signal(os.Kill) }
+
+func (i *Instance) Running() bool {
+	select {
+	case <-i.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// exit code, -1 while running or when the process is not a child (daemonized/attached)
+func (i *Instance) ExitCode() int {
+	select {
+	case <-i.done:
+	default:
+		return -1
+	}
+	if i.state == nil {
+		return -1
+	}
+	return i.state.ExitCode()
+}
+
+// full exit state, nil while running or when the process is not a child
+func (i *Instance) ProcessState() *os.ProcessState {
+	select {
+	case <-i.done:
+		return i.state
+	default:
+		return nil
+	}
+}
+
+// reason of the SHUTDOWN event seen on the events monitor, ex: guest-shutdown, empty when none
+func (i *Instance) ShutdownReason() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.reason
+}
+
+// follow SHUTDOWN on the events monitor until QEMU closes it
+func (i *Instance) watch(sock string) {
+	defer close(i.watched)
+	ctx, cancel := context.WithTimeout(context.Background(), QmpDialTimeout)
+	c, err := DialQmp(ctx, "unix:"+sock)
+	cancel()
+	if err != nil {
+		return // not started by a Supervisor or already gone
+	}
+	defer c.Close()
+	sub := c.Subscribe(EventShutdown)
+	defer sub.Close()
+	for ev := range sub.C {
+		e := &ShutdownEvent{}
+		if err := json.Unmarshal(ev.Data, e); err == nil {
+			i.mu.Lock()
+			i.reason = e.Reason
+			i.mu.Unlock()
+		}
+	}
+}
+
+// processes that are not our children can't be waited on
+func (i *Instance) poll() {
+	t := time.NewTicker(PollInterval)
+	defer t.Stop()
+	for range t.C {
+		if !pidAlive(i.Pid) {
+			close(i.done)
+			return
+		}
+	}
+}
+
+func pidAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+/*
+the pid of a stale pidfile may belong to another process by now: it must be
+a process started with the -pidfile of the guest. Without /proc only
+pidAlive can be checked.
+*/
+func (s *Supervisor) guestProcess(name string, pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+			return true
+		}
+	}
+	if err != nil {
+		return false
+	}
+	args := strings.Split(string(data), "\x00")
+	pidFile := s.pidFile(name)
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-pidfile" && args[i+1] == pidFile {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+usage:
+
+	inst, err := virt.DefaultSupervisor.Start(g)
+	if err != nil { ... }
+	go inst.Wait(ctx)
+
+keep track of QEMU processes by guest name
+*/
+type Supervisor struct {
+	mu        sync.Mutex
+	instances map[string]*Instance
+
+	DataPath string          // pid and log files, empty follows VmDataPath
+	OnExit   func(*Instance) // called once the process of a tracked instance exits
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{instances: map[string]*Instance{}}
+}
+
+var DefaultSupervisor = &Supervisor{
+	instances: map[string]*Instance{},
+	OnExit:    func(i *Instance) { defaultManager.recordExit(i) },
+}
+
+func (s *Supervisor) dataPath() string {
+	if s.DataPath == "" {
+		return VmDataPath
+	}
+	return s.DataPath
+}
+
+func (s *Supervisor) pidFile(name string) string {
+	return path.Join(s.dataPath(), fmt.Sprintf("%s.pid", name))
+}
+
+func (s *Supervisor) logFile(name string) string {
+	return path.Join(s.dataPath(), fmt.Sprintf("%s.log", name))
+}
+
+/*
+a monitor only for the supervisor, the exit code of a daemonized or attached
+QEMU is unknown and its SHUTDOWN event tells a poweroff from a crash. The
+monitor of the guest can't be used, QEMU serves one client at a time.
+*/
+func (s *Supervisor) eventsSocket(name string) string {
+	return path.Join(s.dataPath(), fmt.Sprintf("%s.events.qmp", name))
+}
+
+func (s *Supervisor) Instance(name string) (*Instance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.instances[name]
+	if ok && !i.Running() {
+		delete(s.instances, name)
+		return nil, false
+	}
+	return i, ok
+}
+
+func (s *Supervisor) track(i *Instance) {
+	s.mu.Lock()
+	s.instances[i.Name] = i
+	s.mu.Unlock()
+	i.watched = make(chan struct{})
+	go i.watch(s.eventsSocket(i.Name))
+	if s.OnExit != nil {
+		go func() {
+			<-i.Done()
+			// the SHUTDOWN event may still be on its way
+			select {
+			case <-i.watched:
+			case <-time.After(StartGrace):
+			}
+			s.OnExit(i)
+		}()
+	}
+}
+
+/*
+start QEMU without blocking, stdout/stderr go to <VmDataPath>/<name>.log and
+the PID is recorded in <VmDataPath>/<name>.pid (also for -daemonize). An
+events monitor is added at <VmDataPath>/<name>.events.qmp
+*/
+func (s *Supervisor) Start(g *Guest) (*Instance, error) {
+	if i, ok := s.Instance(g.Name); ok {
+		return i, os.ErrExist
+	}
+	args, err := g.ToArgs()
+	if err != nil {
+		return nil, &StartError{Guest: g.Name, ExitCode: -1, Err: err}
+	}
+	pidFile := s.pidFile(g.Name)
+	os.Remove(pidFile)
+
+	a := append(args, "-pidfile", pidFile)
+	if sock := s.eventsSocket(g.Name); len(sock) < unixPathMax {
+		os.Remove(sock)
+		events, err := (&QmpOptions{ProtoPath: "unix:" + sock, Serve: true}).ToArgs()
+		if err != nil {
+			return nil, &StartError{Guest: g.Name, ExitCode: -1, Err: err}
+		}
+		a = append(a, events...)
+	}
+
+	logs, err := openLogFile(s.logFile(g.Name))
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: 8 << 10}
+
+	cmd := exec.Command(a[0], a[1:]...)
+	cmd.Stdout = logs
+	cmd.Stderr = io.MultiWriter(logs, stderr)
+
+	if err := cmd.Start(); err != nil {
+		logs.Close()
+		return nil, &StartError{Guest: g.Name, ExitCode: -1, Err: err}
+	}
+
+	if g.Daemonize {
+		// the parent exits once the daemon is initialized
+		err := cmd.Wait()
+		logs.Close()
+		if err != nil {
+			return nil, &StartError{Guest: g.Name, ExitCode: cmd.ProcessState.ExitCode(), Stderr: stderr.String(), Err: err}
+		}
+		i, err := s.Attach(g.Name)
+		if err != nil {
+			return nil, &StartError{Guest: g.Name, ExitCode: -1, Err: err}
+		}
+		return i, nil
+	}
+
+	i := &Instance{Name: g.Name, Pid: cmd.Process.Pid, proc: cmd.Process, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		i.err = cmd.Wait()
+		i.state = cmd.ProcessState
+		logs.Close()
+		os.Remove(pidFile)
+		close(i.done)
+	}()
+
+	select {
+	case <-i.done:
+		err := i.err
+		if err == nil {
+			err = ErrNotRunning // exit status 0
+		}
+		return nil, &StartError{Guest: g.Name, ExitCode: i.ExitCode(), Stderr: stderr.String(), Err: err}
+	case <-time.After(StartGrace):
+	}
+	s.track(i)
+	return i, nil
+}
+
+/*
+attach to a running guest from its pidfile, ex: after the daemon restarts. A
+pidfile left by a QEMU that is gone is removed.
+*/
+func (s *Supervisor) Attach(name string) (*Instance, error) {
+	if i, ok := s.Instance(name); ok {
+		return i, nil
+	}
+	data, err := os.ReadFile(s.pidFile(name))
+	if err != nil {
+		return nil, err
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, err
+	}
+	if !pidAlive(pid) || !s.guestProcess(name, pid) {
+		os.Remove(s.pidFile(name))
+		return nil, ErrNotRunning
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	i := &Instance{Name: name, Pid: pid, proc: proc, done: make(chan struct{})}
+	go i.poll()
+	s.track(i)
+	return i, nil
+}
+
+// keep only the last max bytes written
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// append-only log, renamed to .1, .2 ... when it grows past LogMaxSize
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func openLogFile(p string) (*logFile, error) {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &logFile{path: p, f: f, size: st.Size()}, nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	if LogMaxSize > 0 && l.size+int64(len(p)) > LogMaxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *logFile) rotate() error {
+	l.f.Close()
+	for n := LogBackups; n > 0; n-- {
+		src := l.path
+		if n > 1 {
+			src = fmt.Sprintf("%s.%d", l.path, n-1)
+		}
+		os.Rename(src, fmt.Sprintf("%s.%d", l.path, n))
+	}
+	if LogBackups <= 0 {
+		os.Remove(l.path)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		l.f = nil
+		return err
+	}
+	l.f, l.size = f, 0
+	return nil
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
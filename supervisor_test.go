@@ -0,0 +1,70 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// a process standing for the QEMU of name, started with its -pidfile, which is written
+func startFakeQemu(t *testing.T, s *Supervisor, name string) *exec.Cmd {
+	t.Helper()
+	pidFile := s.pidFile(name)
+	cmd := exec.Command("sh", "-c", "while :; do sleep 1; done", "qemu-system-x86_64", "-name", name, "-pidfile", pidFile)
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	// as QEMU, write the pidfile once running: /proc/<pid>/cmdline is empty until the exec is done
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.guestProcess(name, cmd.Process.Pid) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := os.WriteFile(pidFile, []byte(fmt.Sprint(cmd.Process.Pid)), 0o644); err != nil {
+		cmd.Process.Kill()
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestAttachStalePidfile(t *testing.T) {
+	if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+		t.Skip("no /proc")
+	}
+	s := NewSupervisor()
+	s.DataPath = t.TempDir()
+
+	// the pid was reused by a process that is not the guest
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	if err := os.WriteFile(s.pidFile("vm1"), []byte(fmt.Sprint(cmd.Process.Pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Attach("vm1"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("err = %v, want ErrNotRunning", err)
+	}
+	if _, err := os.Stat(s.pidFile("vm1")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale pidfile kept: %v", err)
+	}
+
+	qemu := startFakeQemu(t, s, "vm1")
+	t.Cleanup(func() {
+		qemu.Process.Kill()
+		qemu.Wait()
+	})
+	inst, err := s.Attach("vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Pid != qemu.Process.Pid {
+		t.Errorf("pid %d, want %d", inst.Pid, qemu.Process.Pid)
+	}
+}
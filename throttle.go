@@ -0,0 +1,324 @@
+package virt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+var ErrThrottle = errors.New("limites de I/O inválidos")
+
+// the largest limit accepted by QEMU (THROTTLE_VALUE_MAX)
+const throttleValueMax = 1_000_000_000_000_000
+
+/*
+I/O limits of a -drive (throttling.*) or of a throttle group (limits.*), 0 is
+no limit. The total and the read/write limits exclude each other. The burst
+limits (*Max) need the matching base limit and last up to *MaxLength seconds
+(default 1). With IOPSSize, a request of n*IOPSSize bytes counts as n
+operations.
+*/
+type ThrottleLimits struct {
+	BPS             int64 `yaml:",omitempty" qemu:"bps-total" json:"bps-total"`                                   // bytes per second
+	BPSRd           int64 `yaml:",omitempty" qemu:"bps-read" json:"bps-read"`                                     //
+	BPSWr           int64 `yaml:",omitempty" qemu:"bps-write" json:"bps-write"`                                   //
+	IOPS            int64 `yaml:",omitempty" qemu:"iops-total" json:"iops-total"`                                 // operations per second
+	IOPSRd          int64 `yaml:",omitempty" qemu:"iops-read" json:"iops-read"`                                   //
+	IOPSWr          int64 `yaml:",omitempty" qemu:"iops-write" json:"iops-write"`                                 //
+	BPSMax          int64 `yaml:",omitempty" qemu:"bps-total-max" json:"bps-total-max"`                           // bursts
+	BPSRdMax        int64 `yaml:",omitempty" qemu:"bps-read-max" json:"bps-read-max"`                             //
+	BPSWrMax        int64 `yaml:",omitempty" qemu:"bps-write-max" json:"bps-write-max"`                           //
+	IOPSMax         int64 `yaml:",omitempty" qemu:"iops-total-max" json:"iops-total-max"`                         //
+	IOPSRdMax       int64 `yaml:",omitempty" qemu:"iops-read-max" json:"iops-read-max"`                           //
+	IOPSWrMax       int64 `yaml:",omitempty" qemu:"iops-write-max" json:"iops-write-max"`                         //
+	BPSMaxLength    int64 `yaml:",omitempty" qemu:"bps-total-max-length" json:"bps-total-max-length,omitempty"`   // seconds
+	BPSRdMaxLength  int64 `yaml:",omitempty" qemu:"bps-read-max-length" json:"bps-read-max-length,omitempty"`     //
+	BPSWrMaxLength  int64 `yaml:",omitempty" qemu:"bps-write-max-length" json:"bps-write-max-length,omitempty"`   //
+	IOPSMaxLength   int64 `yaml:",omitempty" qemu:"iops-total-max-length" json:"iops-total-max-length,omitempty"` //
+	IOPSRdMaxLength int64 `yaml:",omitempty" qemu:"iops-read-max-length" json:"iops-read-max-length,omitempty"`   //
+	IOPSWrMaxLength int64 `yaml:",omitempty" qemu:"iops-write-max-length" json:"iops-write-max-length,omitempty"` //
+	IOPSSize        int64 `yaml:",omitempty" qemu:"iops-size" json:"iops-size"`                                   // bytes
+}
+
+// -drive keys of QEMU before throttling.*, still accepted by ParseArgs
+var legacyThrottleKeys = map[string]string{
+	"bps": "throttling.bps-total", "bps_rd": "throttling.bps-read", "bps_wr": "throttling.bps-write",
+	"iops": "throttling.iops-total", "iops_rd": "throttling.iops-read", "iops_wr": "throttling.iops-write",
+	"bps_max": "throttling.bps-total-max", "bps_rd_max": "throttling.bps-read-max", "bps_wr_max": "throttling.bps-write-max",
+	"iops_max": "throttling.iops-total-max", "iops_rd_max": "throttling.iops-read-max", "iops_wr_max": "throttling.iops-write-max",
+	"bps_max_length": "throttling.bps-total-max-length", "bps_rd_max_length": "throttling.bps-read-max-length",
+	"bps_wr_max_length": "throttling.bps-write-max-length", "iops_max_length": "throttling.iops-total-max-length",
+	"iops_rd_max_length": "throttling.iops-read-max-length", "iops_wr_max_length": "throttling.iops-write-max-length",
+	"iops_size": "throttling.iops-size", "group": "throttling.group",
+}
+
+// a limit with its burst, named as the fields
+type throttleBucket struct {
+	name             string
+	avg, max, length int64
+}
+
+func (l *ThrottleLimits) buckets() []throttleBucket {
+	return []throttleBucket{
+		{"BPS", l.BPS, l.BPSMax, l.BPSMaxLength},
+		{"BPSRd", l.BPSRd, l.BPSRdMax, l.BPSRdMaxLength},
+		{"BPSWr", l.BPSWr, l.BPSWrMax, l.BPSWrMaxLength},
+		{"IOPS", l.IOPS, l.IOPSMax, l.IOPSMaxLength},
+		{"IOPSRd", l.IOPSRd, l.IOPSRdMax, l.IOPSRdMaxLength},
+		{"IOPSWr", l.IOPSWr, l.IOPSWrMax, l.IOPSWrMaxLength},
+	}
+}
+
+// the checks of QEMU (throttle_is_valid), nil when QEMU accepts the limits
+func (l *ThrottleLimits) check() error {
+	for _, b := range l.buckets() {
+		for _, v := range []int64{b.avg, b.max, b.length} {
+			if v < 0 || v > throttleValueMax {
+				return fmt.Errorf("%s: valor fora de 0..%d", b.name, int64(throttleValueMax))
+			}
+		}
+		switch {
+		case b.max != 0 && b.avg == 0:
+			return fmt.Errorf("%sMax sem %s", b.name, b.name)
+		case b.max != 0 && b.max < b.avg:
+			return fmt.Errorf("%sMax menor que %s", b.name, b.name)
+		case b.length != 0 && b.max == 0:
+			return fmt.Errorf("%sMaxLength sem %sMax", b.name, b.name)
+		}
+	}
+	switch {
+	case l.BPS != 0 && (l.BPSRd != 0 || l.BPSWr != 0):
+		return errors.New("BPS junto com BPSRd/BPSWr")
+	case l.IOPS != 0 && (l.IOPSRd != 0 || l.IOPSWr != 0):
+		return errors.New("IOPS junto com IOPSRd/IOPSWr")
+	case l.BPSMax != 0 && (l.BPSRdMax != 0 || l.BPSWrMax != 0):
+		return errors.New("BPSMax junto com BPSRdMax/BPSWrMax")
+	case l.IOPSMax != 0 && (l.IOPSRdMax != 0 || l.IOPSWrMax != 0):
+		return errors.New("IOPSMax junto com IOPSRdMax/IOPSWrMax")
+	case l.IOPSSize < 0 || l.IOPSSize > throttleValueMax:
+		return fmt.Errorf("IOPSSize: valor fora de 0..%d", int64(throttleValueMax))
+	}
+	return nil
+}
+
+func (l *ThrottleLimits) isZero() bool { return l == nil || *l == ThrottleLimits{} }
+
+// block_set_io_throttle arguments, all zero removes the limits of the device
+func (l *ThrottleLimits) ioThrottle(device, group string) *BlockIOThrottle {
+	opt := func(v int64) *int64 {
+		if v == 0 {
+			return nil
+		}
+		return &v
+	}
+	return &BlockIOThrottle{
+		Device: device, Group: group,
+		BPS: l.BPS, BPSRd: l.BPSRd, BPSWr: l.BPSWr,
+		IOPS: l.IOPS, IOPSRd: l.IOPSRd, IOPSWr: l.IOPSWr,
+		BPSMax: opt(l.BPSMax), BPSRdMax: opt(l.BPSRdMax), BPSWrMax: opt(l.BPSWrMax),
+		IOPSMax: opt(l.IOPSMax), IOPSRdMax: opt(l.IOPSRdMax), IOPSWrMax: opt(l.IOPSWrMax),
+		BPSMaxLength: opt(l.BPSMaxLength), BPSRdMaxLength: opt(l.BPSRdMaxLength), BPSWrMaxLength: opt(l.BPSWrMaxLength),
+		IOPSMaxLength: opt(l.IOPSMaxLength), IOPSRdMaxLength: opt(l.IOPSRdMaxLength), IOPSWrMaxLength: opt(l.IOPSWrMaxLength),
+		IOPSSize: opt(l.IOPSSize),
+	}
+}
+
+/*
+-object throttle-group,id=id[,limits.bps-total=b]...
+
+limits shared by the throttle nodes (BlockThrottleOptions.ThrottleGroup) and
+the drives (DriveOptions.Group) that name the group: the I/O of all of them
+together stays within Limits.
+*/
+type ThrottleGroup struct {
+	ID     string          `qemu:"id"`
+	Limits *ThrottleLimits `yaml:",omitempty" qemu:"limits"`
+}
+
+func (t *ThrottleGroup) ToArgs() ([]string, error) {
+	return qemuArg("-object", "throttle-group", t)
+}
+
+// -object throttle-group,... the other objects are left to the caller
+func parseObjectArg(g *Guest, v string) (bool, error) {
+	opts := parseOpts(v, "qom-type")
+	if opts[0].Key != "qom-type" || opts[0].Value != "throttle-group" {
+		return false, nil
+	}
+	var t *ThrottleGroup
+	if ok, err := decodeArg(&t, opts[1:]); !ok {
+		return false, err
+	}
+	b := blockDevices(g)
+	b.ThrottleGroups = append(b.ThrottleGroups, t)
+	return true, nil
+}
+
+// groups with unique ids, valid limits, and groups that exist where they are named
+func (b *BlockDevicesOptions) validateThrottle(v *validator) {
+	ids := map[string]bool{}
+	for i, t := range b.ThrottleGroups {
+		field := fmt.Sprintf("BlockDevices.ThrottleGroups[%d]", i)
+		switch {
+		case t.ID == "":
+			v.add(field+".ID", "vazio")
+		case ids[t.ID]:
+			v.add(field+".ID", "duplicado: %s", t.ID)
+		}
+		ids[t.ID] = true
+		if t.Limits != nil {
+			if err := t.Limits.check(); err != nil {
+				v.add(field+".Limits", "%s", err)
+			}
+		}
+	}
+	for i, d := range b.Drive {
+		field := fmt.Sprintf("BlockDevices.Drive[%d]", i)
+		if d.Throttling != nil {
+			if err := d.Throttling.check(); err != nil {
+				v.add(field+".Throttling", "%s", err)
+			}
+		}
+		// QEMU only puts a drive with limits in a group
+		if d.Group != "" && d.Throttling.isZero() {
+			v.add(field+".Group", "drive sem Throttling não entra no grupo %s", d.Group)
+		}
+	}
+	for i, n := range b.Nodes {
+		if n.Throttle != nil && n.Throttle.ThrottleGroup != "" && !ids[n.Throttle.ThrottleGroup] {
+			v.add(fmt.Sprintf("BlockDevices.Nodes[%d].Throttle.ThrottleGroup", i), "grupo inexistente: %s", n.Throttle.ThrottleGroup)
+		}
+	}
+}
+
+// the throttle group over a disk of BlockDevices.Nodes, nil when there is none
+func (g *Guest) diskThrottleGroup(t *SnapshotTree, disk string) (*ThrottleGroup, bool) {
+	for _, d := range g.snapshotDisks(t) {
+		if d.name != disk {
+			continue
+		}
+		for _, n := range g.BlockDevices.Nodes {
+			if n.Throttle == nil || n.Throttle.File != d.node.NodeName {
+				continue
+			}
+			i := slices.IndexFunc(g.BlockDevices.ThrottleGroups, func(tg *ThrottleGroup) bool { return tg.ID == n.Throttle.ThrottleGroup })
+			if i >= 0 {
+				return g.BlockDevices.ThrottleGroups[i], true
+			}
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+/*
+usage:
+
+	err := m.SetDiskThrottle(ctx, "web01", "disk0", &virt.ThrottleLimits{IOPS: 500, BPS: 50 << 20})
+
+change the I/O limits of a disk, nil or zero limits remove them. disk is one of:
+
+  - the id of a throttle group (BlockDevices.ThrottleGroups), qom-set of its
+    limits, shared by every member
+  - the name of a disk of BlockDevices.Nodes (as in the snapshots) under a
+    throttle node, the limits of the group of that node
+  - the ID or file of a -drive, block_set_io_throttle of the drive (in its
+    Group if set)
+
+While the guest runs the limits apply at once, the definition is saved after.
+*/
+func (m *Manager) SetDiskThrottle(ctx context.Context, name, disk string, limits *ThrottleLimits) error {
+	l := ThrottleLimits{}
+	if limits != nil {
+		l = *limits
+	}
+	if err := l.check(); err != nil {
+		return fmt.Errorf("%w: %s", ErrThrottle, err)
+	}
+	unlock, err := m.lockGuest(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g, err := m.Store().Get(name)
+	if err != nil {
+		return err
+	}
+	if g.BlockDevices == nil {
+		return fmt.Errorf("%w: disco %s", os.ErrNotExist, disk)
+	}
+	t, err := m.loadSnapshots(name)
+	if err != nil {
+		return err
+	}
+
+	var group *ThrottleGroup
+	var drive *DriveOptions
+	if i := slices.IndexFunc(g.BlockDevices.ThrottleGroups, func(tg *ThrottleGroup) bool { return tg.ID == disk }); i >= 0 {
+		group = g.BlockDevices.ThrottleGroups[i]
+	} else if tg, ok := g.diskThrottleGroup(t, disk); ok {
+		if tg == nil {
+			return fmt.Errorf("%w: %s não está sob um nó throttle com grupo", ErrThrottle, disk)
+		}
+		group = tg
+	} else if drive = g.BlockDevices.findDrive(disk); drive != nil {
+		if drive.Group != "" && l.isZero() {
+			return fmt.Errorf("%w: %s está no grupo %s, remova o Group antes", ErrThrottle, disk, drive.Group)
+		}
+	} else {
+		return fmt.Errorf("%w: disco %s", os.ErrNotExist, disk)
+	}
+
+	if m.guestRunning(name) {
+		c, err := dialGuest(ctx, g)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if group != nil {
+			err = c.QomSet(ctx, &QomSetArguments{Path: "/objects/" + group.ID, Property: "limits", Value: &l})
+		} else {
+			err = setDriveThrottle(ctx, c, drive, &l)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var saved *ThrottleLimits
+	if !l.isZero() {
+		saved = &l
+	}
+	if group != nil {
+		group.Limits = saved
+	} else {
+		drive.Throttling = saved
+	}
+	if err := m.Store().Put(g); err != nil {
+		return err
+	}
+	m.log.Info("disk throttle set", "guest", name, "disk", disk)
+	return nil
+}
+
+// block_set_io_throttle of the block backend opened from the -drive
+func setDriveThrottle(ctx context.Context, c *Client, drive *DriveOptions, l *ThrottleLimits) error {
+	blocks, err := c.QueryBlock(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		if b.Inserted != nil && sameFile(b.Inserted.File, drive.File) {
+			return c.BlockSetIOThrottle(ctx, l.ioThrottle(b.Device, drive.Group))
+		}
+	}
+	return fmt.Errorf("%w: %s não está aberto no guest", ErrThrottle, drive.File)
+}
+
+// change the I/O limits of a disk, see Manager.SetDiskThrottle
+func SetDiskThrottle(ctx context.Context, name, disk string, limits *ThrottleLimits) error {
+	return defaultManager.SetDiskThrottle(ctx, name, disk, limits)
+}
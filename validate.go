@@ -0,0 +1,250 @@
+package virt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidGuest = errors.New("configuração do guest inválida")
+
+// an invalid field, Field is the path inside Guest ("BlockDevices.Drive[0].File")
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string { return fmt.Sprintf("%s: %s", e.Field, e.Err) }
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// every invalid field of a guest, errors.Is(err, ErrInvalidGuest) is true
+type ValidationError []*FieldError
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidGuest, strings.Join(msgs, "; "))
+}
+
+func (v ValidationError) Is(target error) bool { return target == ErrInvalidGuest }
+
+func (v ValidationError) Unwrap() []error {
+	errs := make([]error, len(v))
+	for i, e := range v {
+		errs[i] = e
+	}
+	return errs
+}
+
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+// a local path, not a protocol like nbd:host:port or http://...
+func isLocalFile(f string) bool {
+	i := strings.IndexAny(f, ":/")
+	return i < 0 || f[i] == '/'
+}
+
+func (v *validator) file(field, f string) {
+	if f == "" || !isLocalFile(f) {
+		return
+	}
+	if _, err := os.Stat(f); err != nil {
+		v.add(field, "arquivo não encontrado: %s", f)
+	}
+}
+
+/*
+usage:
+
+	if err := guest.Validate(); err != nil {
+		var ve virt.ValidationError
+		if errors.As(err, &ve) {
+			for _, fe := range ve { log.Println(fe.Field, fe.Err) }
+		}
+	}
+
+check the guest before handing it to QEMU, all the invalid fields are reported
+at once. CreateGuest, UpdateGuest and StartGuest refuse invalid guests.
+*/
+func (g *Guest) Validate() error {
+	v := &validator{}
+
+	if g.Name == "" {
+		v.add("Name", "vazio")
+	} else if strings.ContainsAny(g.Name, "/\x00") {
+		v.add("Name", "caractere inválido em %q", g.Name)
+	}
+	if g.UUID != "" {
+		if _, err := uuid.Parse(g.UUID); err != nil {
+			v.add("UUID", "não é um UUID: %s", g.UUID)
+		}
+	}
+	if g.Engine < Qemu_system_x86_64 || g.Engine > Qemu_system_aarch64 {
+		v.add("Engine", "engine desconhecido: %d", g.Engine)
+	}
+
+	if m := g.Memory; m != nil {
+		if m.Size <= 0 {
+			v.add("Memory.Size", "deve ser maior que zero")
+		}
+		if m.Maxmen != 0 && m.Size > m.Maxmen {
+			v.add("Memory.Size", "maior que Maxmen (%d > %d)", m.Size, m.Maxmen)
+		}
+	}
+	if g.Smp != nil {
+		g.validateSmp(v)
+	}
+	g.validateNetwork(v)
+
+	if b := g.BlockDevices; b != nil {
+		v.file("BlockDevices.Fda", b.Fda)
+		v.file("BlockDevices.Fdb", b.Fdb)
+		v.file("BlockDevices.Hda", b.Hda)
+		v.file("BlockDevices.Hdb", b.Hdb)
+		v.file("BlockDevices.Hdc", b.Hdc)
+		v.file("BlockDevices.Hdd", b.Hdd)
+		for i, d := range b.Drive {
+			d.validate(v, fmt.Sprintf("BlockDevices.Drive[%d]", i))
+		}
+		for i, c := range b.Cdrom {
+			v.file(fmt.Sprintf("BlockDevices.Cdrom[%d].File", i), c.File)
+		}
+		b.validateNodes(v)
+		b.validateThrottle(v)
+	}
+
+	if q := g.Qmp; q != nil && strings.ToLower(q.ProtoPath) != "stdio" {
+		if _, _, err := qmpNetwork(q.ProtoPath); err != nil {
+			v.add("Qmp.ProtoPath", "%s", err)
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// sockets*dies*clusters*cores*threads (books, drawers and modules too) must match Maxcpus, or Cpus without it
+func (g *Guest) validateSmp(v *validator) {
+	s := g.Smp
+	for _, n := range []int{s.Cpus, s.Maxcpus, s.Sockets, s.Dies, s.Clusters, s.Cores, s.Threads, s.Books, s.Drawers, s.Modules} {
+		if n < 0 {
+			v.add("Smp", "valores negativos")
+			return
+		}
+	}
+	if s.Maxcpus > 0 && s.Cpus > s.Maxcpus {
+		v.add("Smp.Cpus", "maior que Maxcpus (%d > %d)", s.Cpus, s.Maxcpus)
+	}
+
+	product, topology := 1, false
+	for _, n := range []int{s.Sockets, s.Dies, s.Clusters, s.Cores, s.Threads, s.Books, s.Drawers, s.Modules} {
+		if n > 0 {
+			product *= n
+			topology = true
+		}
+	}
+	total, field := s.Cpus, "Smp.Cpus"
+	if s.Maxcpus > 0 {
+		total, field = s.Maxcpus, "Smp.Maxcpus"
+	}
+	if topology && total > 0 && product != total {
+		v.add(field, "topologia com %d vCPUs, esperado %d", product, total)
+	}
+}
+
+type netdevRef struct {
+	field string  // path inside Guest
+	id    *string // the ID field
+}
+
+// the -netdev backends of the guest
+func (g *Guest) netdevs() []netdevRef {
+	nets := []netdevRef{}
+	if n := g.NetDev_Bridge; n != nil {
+		nets = append(nets, netdevRef{"NetDev_Bridge.ID", &n.ID})
+	}
+	if n := g.Netdev_Hubport; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Hubport.ID", &n.ID})
+	}
+	if n := g.Netdev_Passt; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Passt.ID", &n.ID})
+	}
+	if n := g.Netdev_Tap; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Tap.ID", &n.ID})
+	}
+	if n := g.Netdev_User; n != nil {
+		nets = append(nets, netdevRef{"Netdev_User.ID", &n.ID})
+	}
+	if n := g.Netdev_Vde; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Vde.ID", &n.ID})
+	}
+	if n := g.Netdev_Vhost_user; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Vhost_user.ID", &n.ID})
+	}
+	if n := g.Netdev_Vhost_vdpa; n != nil {
+		nets = append(nets, netdevRef{"Netdev_Vhost_vdpa.ID", &n.ID})
+	}
+	return nets
+}
+
+// value of key in a raw option string ("netdev" in "virtio-net,netdev=n0")
+func optValue(raw, key string) string {
+	for _, o := range parseOpts(raw, "") {
+		if o.Key == key {
+			return o.Value
+		}
+	}
+	return ""
+}
+
+// netdev IDs must be unique and every netdev=<id> must exist
+func (g *Guest) validateNetwork(v *validator) {
+	ids := map[string]string{} // id -> field
+	for _, n := range g.netdevs() {
+		switch id := *n.id; {
+		case id == "":
+			v.add(n.field, "vazio")
+		case ids[id] != "":
+			v.add(n.field, "duplicado, já usado em %s", ids[id])
+		default:
+			ids[id] = n.field
+		}
+	}
+	if g.Nic != nil { // -nic creates its own netdev
+		if id := optValue(g.Nic.Option, "id"); id != "" {
+			if ids[id] != "" {
+				v.add("Nic.Option", "id duplicado, já usado em %s", ids[id])
+			}
+			ids[id] = "Nic.Option"
+		}
+	}
+
+	ref := func(field, id string) {
+		if id != "" && ids[id] == "" {
+			v.add(field, "netdev inexistente: %s", id)
+		}
+	}
+	if g.Netdev_Hubport != nil {
+		ref("Netdev_Hubport.Netdev", g.Netdev_Hubport.Netdev)
+	}
+	if g.Nic != nil {
+		ref("Nic.Option", optValue(g.Nic.Option, "netdev"))
+	}
+	for i, d := range g.Devices {
+		ref(fmt.Sprintf("Devices[%d]", i), d.prop("netdev"))
+	}
+}